@@ -0,0 +1,126 @@
+// Package audit_log records security-relevant auth events (logins, registrations,
+// password resets, role switches, grants, delegations, sign-outs and lockouts) in an append-only log.
+//
+// Every entry carries the hash of the entry before it and an Ed448 signature over its
+// canonical encoding, so deleting, reordering or editing an entry breaks the chain in a
+// way VerifyChain reports, and an entry cannot be re-forged without the signing key.
+package audit_log
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+const EntryVersion = "v1"
+
+// Event names
+const (
+	EventLogin            = "LOGIN"
+	EventRegister         = "REGISTER"
+	EventPasswordReset    = "PASSWORD_RESET"
+	EventUserRoleSwitch   = "USER_ROLE_SWITCH"
+	EventAuthGrantIssue   = "AUTH_GRANT_ISSUE"
+	EventAuthGrantRevoke  = "AUTH_GRANT_REVOKE"
+	EventDelegationIssue  = "DELEGATION_ISSUE"
+	EventDelegationRevoke = "DELEGATION_REVOKE"
+	EventSessionRevoke    = "SESSION_REVOKE"
+	EventSessionRefresh   = "SESSION_REFRESH"
+	EventAccountLock      = "ACCOUNT_LOCK"
+	EventAccountUnlock    = "ACCOUNT_UNLOCK"
+)
+
+const (
+	OutcomeSuccess = "SUCCESS"
+	OutcomeFailure = "FAILURE"
+)
+
+var (
+	ErrSequenceTaken = errors.New("audit log sequence already taken")
+	ErrChainBroken   = errors.New("audit log chain broken")
+	ErrEntryTampered = errors.New("audit log entry tampered")
+	ErrUnknownKey    = errors.New("audit log entry names a signing key the keystore does not hold")
+)
+
+// Event is what happened. Actor is who initiated it (e.g. the staff member whose
+// AuthGrant reset a password) and Target whose account it affected; either may be zero.
+type Event struct {
+	Actor                   uagc.UniqueUser `json:"actor"`
+	Target                  uagc.UniqueUser `json:"target"`
+	Command                 string          `json:"command"` // Event*
+	TraceID                 string          `json:"trace_id"`
+	Outcome                 string          `json:"outcome"`          // Outcome*
+	Detail                  string          `json:"detail,omitempty"` // Status info, grant ID, ...
+	OccurredAtUnixTimestamp int64           `json:"occurred_at_unix_timestamp"`
+}
+
+// Entry is one link of the chain. Sequence starts at 1; the first entry's PreviousHash is "".
+type Entry struct {
+	Version              string `json:"version"`
+	Sequence             int64  `json:"sequence"`
+	PreviousHash         string `json:"previous_hash"`
+	Event                Event  `json:"event"`
+	SigningKeyIdentifier string `json:"signing_key_identifier"`
+	EntryHash            string `json:"entry_hash"` // base64url SHA-256 of CanonicalBytes
+	Signature            string `json:"signature"`  // Ed448 over CanonicalBytes
+}
+
+// OutcomeForStatus maps a handler status code to an Outcome.
+func OutcomeForStatus(status string) string {
+	if status == "200" {
+		return OutcomeSuccess
+	}
+	return OutcomeFailure
+}
+
+// ─── Installed logger ───────────────────────────────────────────
+
+var (
+	mu     sync.RWMutex
+	active *Logger
+)
+
+// Install replaces the logger Record writes to. Persephone installs one at Init.
+func Install(l *Logger) {
+	mu.Lock()
+	defer mu.Unlock()
+	active = l
+}
+
+// Active returns the installed logger, falling back to an in-memory one.
+func Active() *Logger {
+	mu.RLock()
+	l := active
+	mu.RUnlock()
+	if l != nil {
+		return l
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		active = NewLogger(NewMemoryAdapter())
+	}
+	return active
+}
+
+// Record appends ev to the installed logger. A failed append is logged, not returned:
+// the request it describes has already completed.
+func Record(ev Event) {
+	if _, err := Active().Append(ev); err != nil {
+		log.Printf("⚠️ Audit append failed for %s (trace %s): %v", ev.Command, ev.TraceID, err)
+	}
+}
+
+// Reply records ev with the outcome of a PSP handler reply and passes the reply through,
+// so a handler can audit each of its returns once the actor is known.
+func Reply(ev Event, resp any, status, info, extended string) (any, string, string, string) {
+	ev.Outcome = OutcomeForStatus(status)
+	if ev.Detail == "" {
+		ev.Detail = info
+	}
+	Record(ev)
+	return resp, status, info, extended
+}
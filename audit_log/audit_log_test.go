@@ -0,0 +1,111 @@
+package audit_log
+
+import (
+	"testing"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/keystore"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+func appendEvents(t *testing.T, l *Logger, n int) []Entry {
+	var out []Entry
+	for i := 0; i < n; i++ {
+		e, err := l.Append(Event{
+			Actor:   uagc.UniqueUser{TenantID: "dojo-a", UserID: "dima"},
+			Target:  uagc.UniqueUser{TenantID: "dojo-a", UserID: "amanda"},
+			Command: EventPasswordReset,
+			Outcome: OutcomeSuccess,
+		})
+		require.NoError(t, err)
+		out = append(out, *e)
+	}
+	return out
+}
+
+func storeOf(entries []Entry) *MemoryAdapter {
+	return &MemoryAdapter{entries: append([]Entry(nil), entries...)}
+}
+
+func TestAppendAndVerify(t *testing.T) {
+	l := NewLogger(NewMemoryAdapter())
+	entries := appendEvents(t, l, 3)
+	require.Equal(t, int64(3), entries[2].Sequence)
+	require.Equal(t, entries[1].EntryHash, entries[2].PreviousHash)
+
+	head, err := VerifyStore(l.Store(), &entries[1])
+	require.NoError(t, err)
+	require.Equal(t, entries[2], *head)
+
+	targeted, err := l.Store().ListForTarget("dojo-a", "amanda")
+	require.NoError(t, err)
+	require.Len(t, targeted, 3)
+}
+
+func TestTamperingIsDetected(t *testing.T) {
+	entries := appendEvents(t, NewLogger(NewMemoryAdapter()), 3)
+
+	// Deleted entry
+	_, err := VerifyStore(storeOf([]Entry{entries[0], entries[2]}), nil)
+	require.ErrorIs(t, err, ErrChainBroken)
+
+	// Edited entry
+	edited := storeOf(entries)
+	edited.entries[1].Event.Actor.UserID = "someone-else"
+	_, err = VerifyStore(edited, nil)
+	require.ErrorIs(t, err, ErrEntryTampered)
+
+	// Edited and rehashed, but not re-signed
+	rehashed := storeOf(entries)
+	rehashed.entries[1].Event.Outcome = OutcomeFailure
+	msg, err := CanonicalBytes(rehashed.entries[1])
+	require.NoError(t, err)
+	rehashed.entries[1].EntryHash = hashCanonical(msg)
+	_, err = VerifyStore(rehashed, nil)
+	require.ErrorIs(t, err, ErrEntryTampered)
+
+	// Truncated tail only shows against a previously seen head
+	truncated := storeOf(entries[:2])
+	_, err = VerifyStore(truncated, nil)
+	require.NoError(t, err)
+	_, err = VerifyStore(truncated, &entries[2])
+	require.ErrorIs(t, err, ErrChainBroken)
+}
+
+func TestRetiredAuditKeyStillVerifies(t *testing.T) {
+	l := NewLogger(NewMemoryAdapter())
+	before := appendEvents(t, l, 2)
+
+	original := uagc.ActiveKeyStore()
+	t.Cleanup(func() { require.NoError(t, uagc.SetKeyStore(original)) })
+	rotated := keystore.NewMemoryAdapter()
+	for _, name := range keystore.RequiredKeys {
+		for _, k := range original.List(name) {
+			rotated.Put(k)
+		}
+	}
+	priv, pub, err := ed448_api.GenerateKeyPair()
+	require.NoError(t, err)
+	rotated.Rotate(keystore.Key{Name: keystore.Ed448AuditPrivate, ID: "Rotated", Material: priv})
+	rotated.Rotate(keystore.Key{Name: keystore.Ed448AuditPublic, ID: "Rotated", Material: pub})
+	oldID := before[0].SigningKeyIdentifier
+	require.NoError(t, rotated.SetState(keystore.Ed448AuditPrivate, oldID, keystore.KeyStateRetired))
+	require.NoError(t, rotated.SetState(keystore.Ed448AuditPublic, oldID, keystore.KeyStateRetired))
+	require.NoError(t, uagc.SetKeyStore(rotated))
+
+	after := appendEvents(t, l, 1)
+	require.Equal(t, "Rotated", after[0].SigningKeyIdentifier)
+	_, err = VerifyStore(l.Store(), &after[0])
+	require.NoError(t, err)
+
+	// A key the keystore never held is not evidence of tampering
+	unknown := before[0]
+	unknown.SigningKeyIdentifier = "never-issued"
+	msg, err := CanonicalBytes(unknown)
+	require.NoError(t, err)
+	unknown.EntryHash = hashCanonical(msg)
+	err = VerifyEntry(unknown)
+	require.ErrorIs(t, err, ErrUnknownKey)
+	require.NotErrorIs(t, err, ErrEntryTampered)
+}
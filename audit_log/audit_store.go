@@ -0,0 +1,14 @@
+package audit_log
+
+// AuditStore persists entries. Implementations must never update or delete rows;
+// Append is the only write.
+type AuditStore interface {
+	// Append stores e, failing with ErrSequenceTaken if e.Sequence already exists.
+	Append(e Entry) error
+	// Last returns the entry with the highest sequence, or nil if the log is empty.
+	Last() (*Entry, error)
+	// Range returns up to limit entries with Sequence >= fromSeq, in sequence order.
+	Range(fromSeq int64, limit int) ([]Entry, error)
+	// ListForTarget returns the entries whose target is the given CoreUser, in sequence order.
+	ListForTarget(tenantID, userID string) ([]Entry, error)
+}
@@ -0,0 +1,44 @@
+package audit_log
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+)
+
+// CanonicalBytes returns the bytes an Entry hash and signature cover: every field but
+// EntryHash and Signature, encoded per utils/canonical in this order:
+//
+//	version, sequence (int), previous_hash,
+//	event.actor.{tenant_id, user_group_id, user_id, sub_id},
+//	event.target.{tenant_id, user_group_id, user_id, sub_id},
+//	event.command, event.trace_id, event.outcome, event.detail,
+//	event.occurred_at_unix_timestamp (int), signing_key_identifier
+func CanonicalBytes(e Entry) ([]byte, error) {
+	ev := e.Event
+	return canonical.NewEncoder("AuditLogEntry").
+		String(e.Version).
+		Int64(e.Sequence).
+		String(e.PreviousHash).
+		String(ev.Actor.TenantID).
+		String(ev.Actor.UserGroupID).
+		String(ev.Actor.UserID).
+		String(ev.Actor.SubID).
+		String(ev.Target.TenantID).
+		String(ev.Target.UserGroupID).
+		String(ev.Target.UserID).
+		String(ev.Target.SubID).
+		String(ev.Command).
+		String(ev.TraceID).
+		String(ev.Outcome).
+		String(ev.Detail).
+		Int64(ev.OccurredAtUnixTimestamp).
+		String(e.SigningKeyIdentifier).
+		Bytes()
+}
+
+func hashCanonical(msg []byte) string {
+	sum := sha256.Sum256(msg)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,82 @@
+package audit_log
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// maxAppendAttempts bounds retries when another instance appended the same sequence first.
+const maxAppendAttempts = 5
+
+// Logger appends signed, hash-chained entries to an AuditStore.
+type Logger struct {
+	mu    sync.Mutex
+	store AuditStore
+}
+
+func NewLogger(store AuditStore) *Logger {
+	return &Logger{store: store}
+}
+
+func (l *Logger) Store() AuditStore {
+	return l.store
+}
+
+// Append links ev to the current head of the log, signs it, and stores it.
+func (l *Logger) Append(ev Event) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ev.OccurredAtUnixTimestamp == 0 {
+		ev.OccurredAtUnixTimestamp = time.Now().Unix()
+	}
+
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		last, err := l.store.Last()
+		if err != nil {
+			return nil, err
+		}
+		e := Entry{Version: EntryVersion, Sequence: 1, Event: ev}
+		if last != nil {
+			e.Sequence = last.Sequence + 1
+			e.PreviousHash = last.EntryHash
+		}
+		if err := sign(&e); err != nil {
+			return nil, err
+		}
+
+		err = l.store.Append(e)
+		if errors.Is(err, ErrSequenceTaken) {
+			continue // Another instance won the race; relink to the new head
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &e, nil
+	}
+	return nil, fmt.Errorf("%w after %d attempts", ErrSequenceTaken, maxAppendAttempts)
+}
+
+func sign(e *Entry) error {
+	keyID, priv := uagc.Ed448AuditSigningKey()
+	e.SigningKeyIdentifier = keyID
+
+	msg, err := CanonicalBytes(*e)
+	if err != nil {
+		return err
+	}
+	sig, err := ed448_api.SignWithContext(priv, msg, sigctx.AuditLogEntry)
+	if err != nil {
+		return err
+	}
+	e.EntryHash = hashCanonical(msg)
+	e.Signature = base64.RawURLEncoding.EncodeToString(sig)
+	return nil
+}
@@ -0,0 +1,59 @@
+package audit_log
+
+import "sync"
+
+// MemoryAdapter is a process-local AuditStore; its log is lost on restart.
+type MemoryAdapter struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{}
+}
+
+func (a *MemoryAdapter) Append(e Entry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e.Sequence != int64(len(a.entries))+1 {
+		return ErrSequenceTaken
+	}
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+func (a *MemoryAdapter) Last() (*Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.entries) == 0 {
+		return nil, nil
+	}
+	e := a.entries[len(a.entries)-1]
+	return &e, nil
+}
+
+func (a *MemoryAdapter) Range(fromSeq int64, limit int) ([]Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var out []Entry
+	for _, e := range a.entries {
+		if e.Sequence >= fromSeq && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (a *MemoryAdapter) ListForTarget(tenantID, userID string) ([]Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var out []Entry
+	for _, e := range a.entries {
+		if e.Event.Target.TenantID == tenantID && e.Event.Target.UserID == userID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package audit_log
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+/*
+CREATE TABLE auth_audit_log (
+    sequence BIGINT PRIMARY KEY,
+    target_tenant_id TEXT NOT NULL,
+    target_user_id TEXT NOT NULL,
+    entry JSONB NOT NULL,
+    appended_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX auth_audit_log_target ON auth_audit_log (target_tenant_id, target_user_id);
+
+-- The auth server's role only ever appends
+REVOKE UPDATE, DELETE, TRUNCATE ON auth_audit_log FROM PUBLIC;
+*/
+
+type PgAdapter struct {
+	db    *sql.DB
+	table string
+}
+
+func NewPgAdapter(db *sql.DB) *PgAdapter {
+	return &PgAdapter{db: db, table: "auth_audit_log"}
+}
+
+// Append relies on the primary key for atomicity.
+func (a *PgAdapter) Append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO %s (sequence, target_tenant_id, target_user_id, entry)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (sequence) DO NOTHING
+	`, a.table)
+	res, err := a.db.ExecContext(context.Background(), query,
+		e.Sequence, e.Event.Target.TenantID, e.Event.Target.UserID, data)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSequenceTaken
+	}
+	return nil
+}
+
+func (a *PgAdapter) Last() (*Entry, error) {
+	entries, err := a.query(fmt.Sprintf(`SELECT entry FROM %s ORDER BY sequence DESC LIMIT 1`, a.table))
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+func (a *PgAdapter) Range(fromSeq int64, limit int) ([]Entry, error) {
+	return a.query(fmt.Sprintf(`
+		SELECT entry FROM %s WHERE sequence >= $1 ORDER BY sequence LIMIT $2
+	`, a.table), fromSeq, limit)
+}
+
+func (a *PgAdapter) ListForTarget(tenantID, userID string) ([]Entry, error) {
+	return a.query(fmt.Sprintf(`
+		SELECT entry FROM %s WHERE target_tenant_id = $1 AND target_user_id = $2 ORDER BY sequence
+	`, a.table), tenantID, userID)
+}
+
+func (a *PgAdapter) query(query string, args ...any) ([]Entry, error) {
+	rows, err := a.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Entry
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("unmarshal audit entry: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
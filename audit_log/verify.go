@@ -0,0 +1,106 @@
+package audit_log
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/keystore"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// verifyPageSize is how many entries VerifyStore reads at a time.
+const verifyPageSize = 500
+
+// VerifyEntry checks one entry's hash and signature on its own. It proves the entry is
+// authentic, not that no entries around it were removed; use VerifyChain for that.
+// Retired audit keys still verify what they signed. An entry naming a key the keystore
+// never held fails with ErrUnknownKey, e.g. when verifying with an incomplete keystore,
+// rather than ErrEntryTampered.
+func VerifyEntry(e Entry) error {
+	msg, err := CanonicalBytes(e)
+	if err != nil {
+		return err
+	}
+	if hashCanonical(msg) != e.EntryHash {
+		return fmt.Errorf("%w: entry %d hash mismatch", ErrEntryTampered, e.Sequence)
+	}
+
+	pub, err := uagc.Ed448AuditVerificationKey(e.SigningKeyIdentifier, e.Event.OccurredAtUnixTimestamp)
+	if errors.Is(err, keystore.ErrKeyNotFound) {
+		return fmt.Errorf("%w: entry %d: %v", ErrUnknownKey, e.Sequence, err)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: entry %d: %v", ErrEntryTampered, e.Sequence, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(e.Signature)
+	if err != nil || !ed448_api.VerifyWithContext(pub, msg, sig, sigctx.AuditLogEntry) {
+		return fmt.Errorf("%w: entry %d signature", ErrEntryTampered, e.Sequence)
+	}
+	return nil
+}
+
+// VerifyChain checks that entries are authentic and consecutive. prev is the entry
+// just before entries[0], or nil if entries starts the log.
+func VerifyChain(entries []Entry, prev *Entry) error {
+	for i := range entries {
+		e := entries[i]
+		wantSeq, wantPrev := int64(1), ""
+		if prev != nil {
+			wantSeq, wantPrev = prev.Sequence+1, prev.EntryHash
+		}
+		if e.Sequence != wantSeq {
+			return fmt.Errorf("%w: expected entry %d, found %d", ErrChainBroken, wantSeq, e.Sequence)
+		}
+		if e.PreviousHash != wantPrev {
+			return fmt.Errorf("%w: entry %d does not link to its predecessor", ErrChainBroken, e.Sequence)
+		}
+		if err := VerifyEntry(e); err != nil {
+			return err
+		}
+		prev = &e
+	}
+	return nil
+}
+
+// VerifyStore walks the whole log. If expectedHead is not nil (e.g. a head published
+// earlier), the log must still contain it, which also catches entries cut off the end.
+// It returns the verified head.
+func VerifyStore(store AuditStore, expectedHead *Entry) (*Entry, error) {
+	var prev *Entry
+	for {
+		from := int64(1)
+		if prev != nil {
+			from = prev.Sequence + 1
+		}
+		page, err := store.Range(from, verifyPageSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := VerifyChain(page, prev); err != nil {
+			return nil, err
+		}
+		if len(page) > 0 {
+			prev = &page[len(page)-1]
+		}
+		if len(page) < verifyPageSize {
+			break
+		}
+	}
+
+	if expectedHead != nil {
+		if prev == nil || prev.Sequence < expectedHead.Sequence {
+			return nil, fmt.Errorf("%w: log ends before entry %d", ErrChainBroken, expectedHead.Sequence)
+		}
+		page, err := store.Range(expectedHead.Sequence, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 || page[0].EntryHash != expectedHead.EntryHash {
+			return nil, fmt.Errorf("%w: entry %d differs from the expected head", ErrChainBroken, expectedHead.Sequence)
+		}
+	}
+	return prev, nil
+}
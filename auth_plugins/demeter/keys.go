@@ -0,0 +1,90 @@
+package demeter
+
+import (
+	"encoding/base64"
+	"time"
+
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/demeter/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_service_registry"
+	"github.com/drlzh/mng-app-user-auth-prot/internal/context"
+	"github.com/drlzh/mng-app-user-auth-prot/keystore"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// publishedKeys lists which public keys downstream services may fetch, and what they verify.
+// Only public key names belong here.
+var publishedKeys = []struct {
+	use, algorithm, name, signingContext string
+}{
+	{ds.KeyUseAuthTicket, ds.KeyAlgorithmEd448, keystore.Ed448AuthTicketPublic, sigctx.AuthTicket},
+	{ds.KeyUseAuthGrant, ds.KeyAlgorithmEd448, keystore.Ed448AuthGrantPublic, sigctx.AuthGrant},
+	{ds.KeyUseBiscuit, ds.KeyAlgorithmEd25519, keystore.Ed25519BiscuitPublic, ""},
+	{ds.KeyUseAuditLog, ds.KeyAlgorithmEd448, keystore.Ed448AuditPublic, sigctx.AuditLogEntry},
+}
+
+// KeySetHandler publishes the public keys for AuthTickets, AuthGrants, Biscuits and the
+// audit log.
+// See <ProjectRoot>\auth_verifier for the client side.
+type KeySetHandler struct{}
+
+func NewKeySetHandler() auth_service_registry.AuthSubsystemHandler {
+	return &KeySetHandler{}
+}
+
+func (h *KeySetHandler) Init(appCtx *context.AppContext) error {
+	return nil
+}
+
+func (h *KeySetHandler) SetConfig(config any) error {
+	return nil
+}
+
+func (h *KeySetHandler) GetConfig() any {
+	return nil
+}
+
+func (h *KeySetHandler) Routes() []string {
+	return []string{"/keys"}
+}
+
+func (h *KeySetHandler) HandleRequest(path string, payloadIn string, statusIn, infoIn, extendedIn string) (any, string, string, string) {
+	ks := uagc.ActiveKeyStore()
+	if ks == nil {
+		return nil, "500", "Keystore unavailable", ""
+	}
+	return BuildPublicKeySet(ks, time.Now()), "200", "OK", ""
+}
+
+// BuildPublicKeySet lists every active and verify-only public key in ks. Retired keys are
+// left out so verifiers stop accepting them on their next refresh.
+func BuildPublicKeySet(ks keystore.KeyStore, now time.Time) ds.PublicKeySet {
+	set := ds.PublicKeySet{
+		Version:                  ds.PublicKeySetVersion,
+		GeneratedAtUnixTimestamp: now.Unix(),
+		MaxAgeSeconds:            int64(ds.PublicKeySetMaxAge.Seconds()),
+		Keys:                     []ds.PublishedKey{},
+	}
+	for _, p := range publishedKeys {
+		for _, k := range ks.List(p.name) {
+			if k.State == keystore.KeyStateRetired || len(k.Material) == 0 {
+				continue
+			}
+			state := k.State
+			if state == "" {
+				state = keystore.KeyStateActive
+			}
+			set.Keys = append(set.Keys, ds.PublishedKey{
+				KeyID:          k.ID,
+				Algorithm:      p.algorithm,
+				Use:            p.use,
+				SigningContext: p.signingContext,
+				State:          state,
+				NotBefore:      k.NotBefore,
+				NotAfter:       k.NotAfter,
+				PublicKey:      base64.RawURLEncoding.EncodeToString(k.Material),
+			})
+		}
+	}
+	return set
+}
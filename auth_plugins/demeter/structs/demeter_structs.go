@@ -0,0 +1,40 @@
+package structs
+
+import "time"
+
+const (
+	PublicKeySetVersion = "v1"
+	PublicKeySetMaxAge  = 5 * time.Minute // How long verifiers may cache a key set before refetching
+
+	KeyAlgorithmEd448   = "Ed448"
+	KeyAlgorithmEd25519 = "Ed25519"
+
+	// KeyUse* name the artifact a published key verifies.
+	KeyUseAuthTicket = "auth_ticket"
+	KeyUseAuthGrant  = "auth_grant"
+	KeyUseBiscuit    = "biscuit" // Biscuit root key, Ed25519
+	KeyUseAuditLog   = "audit_log"
+)
+
+// PublicKeySet is what DEMETER publishes on /keys. Downstream services fetch it to
+// verify AuthTickets, AuthGrants and Biscuits without calling back into the auth server.
+type PublicKeySet struct {
+	Version                  string         `json:"version"`
+	GeneratedAtUnixTimestamp int64          `json:"generated_at_unix_timestamp"`
+	MaxAgeSeconds            int64          `json:"max_age_seconds"`
+	Keys                     []PublishedKey `json:"keys"`
+}
+
+// PublishedKey is one public key. Verifiers pick it by (Use, KeyID), where KeyID matches
+// the artifact's signing_key_identifier, and reject artifacts whose issuance time falls
+// outside [NotBefore, NotAfter] (unix seconds, 0 = open).
+type PublishedKey struct {
+	KeyID          string `json:"kid"`
+	Algorithm      string `json:"alg"`                       // KeyAlgorithm*
+	Use            string `json:"use"`                       // KeyUse*
+	SigningContext string `json:"signing_context,omitempty"` // Ed448ctx string the signature was made with
+	State          string `json:"state"`                     // keystore.KeyStateActive / KeyStateVerifyOnly
+	NotBefore      int64  `json:"not_before,omitempty"`
+	NotAfter       int64  `json:"not_after,omitempty"`
+	PublicKey      string `json:"public_key"` // base64url, raw public key (57 bytes Ed448, 32 bytes Ed25519)
+}
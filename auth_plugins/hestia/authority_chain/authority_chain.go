@@ -0,0 +1,301 @@
+// Package authority_chain builds and verifies Hestia authority chains: ordered lists of
+// AuthorityNodes, each signed by the acting user's own Ed448 key, tracing who enabled a
+// sensitive action back to a trust anchor (see auth_plugins/hestia/structs for scenarios).
+//
+// A chain is valid when it starts at a trust anchor, every node links to its neighbours
+// through PreviousAuthorityNodeID/NextAuthorityNodeID, every signature verifies, and every
+// intent a node asserts is permitted by an intent of the node before it, its provisioning
+// authority. AdditionalAuthorityNodeID is carried but not interpreted here.
+package authority_chain
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	hs "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/hestia/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+const (
+	// IntentSeparator separates the levels of an intent, e.g.
+	// "Entitlements::Issuances::Grants::PasswordReset".
+	IntentSeparator = "::"
+	// IntentAll, held by a provisioning authority, permits any intent downstream.
+	IntentAll = "*"
+
+	NodeIDSize = 16
+	NonceSize  = 16
+)
+
+var (
+	ErrEmptyChain           = errors.New("authority chain is empty")
+	ErrNotTrustAnchor       = errors.New("authority chain does not start at a trust anchor")
+	ErrBrokenLink           = errors.New("authority chain linkage is broken")
+	ErrInvalidSignature     = errors.New("invalid signature on authority node")
+	ErrIntentNotPermitted   = errors.New("intent not permitted by provisioning authority")
+	ErrUnknownUserKey       = errors.New("unknown user signing key")
+	ErrInvalidatedAuthority = errors.New("authority has been invalidated")
+	ErrActorContextMissing  = errors.New("actor context has no authority chain")
+)
+
+// Signer is the acting user and the key they sign their node with.
+type Signer struct {
+	User  uagc.UniqueUser
+	KeyID string
+	Key   ed448_api.PrivateKey
+}
+
+// IntentPermits reports whether a provisioning authority holding parent may hand child on:
+// parent is IntentAll, equal to child, or one of child's ancestors.
+func IntentPermits(parent, child string) bool {
+	return parent == IntentAll || parent == child || strings.HasPrefix(child, parent+IntentSeparator)
+}
+
+// NewChain starts a chain with the trust anchor's own node. Whether the signer really is
+// a trust anchor is only decided by Verify.
+func NewChain(anchor Signer, intents ...string) ([]hs.AuthorityLinkedListNode, error) {
+	node, err := signNode(anchor, intents, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return []hs.AuthorityLinkedListNode{{
+		ThisAuthorityNode: node,
+		IsTrustAnchor:     true,
+		IsLastAuthority:   true,
+	}}, nil
+}
+
+// Append returns a copy of chain with a node signed by signer added at the end. Each
+// intent must be permitted by the current last node. The existing nodes are not
+// re-verified; run Verify on the result before acting on it.
+func Append(chain []hs.AuthorityLinkedListNode, signer Signer, intents ...string) ([]hs.AuthorityLinkedListNode, error) {
+	if len(chain) == 0 {
+		return nil, ErrEmptyChain
+	}
+	last := chain[len(chain)-1]
+	if !last.IsLastAuthority || last.NextAuthorityNodeID != "" {
+		return nil, fmt.Errorf("%w: last node %s is already followed", ErrBrokenLink, last.ThisAuthorityNode.NodeID)
+	}
+	if err := checkIntents(last.ThisAuthorityNode, intents); err != nil {
+		return nil, err
+	}
+
+	node, err := signNode(signer, intents, last.ThisAuthorityNode.NodeID, last.ThisAuthorityNode.AuthoritySignature)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]hs.AuthorityLinkedListNode, len(chain), len(chain)+1)
+	copy(out, chain)
+	prev := &out[len(out)-1]
+	prev.NextAuthorityNodeID = node.NodeID
+	prev.IsLastAuthority = false
+	prev.IsIntermediateAuthority = !prev.IsTrustAnchor
+
+	return append(out, hs.AuthorityLinkedListNode{
+		PreviousAuthorityNodeID: prev.ThisAuthorityNode.NodeID,
+		ThisAuthorityNode:       node,
+		IsLastAuthority:         true,
+	}), nil
+}
+
+// Verify checks the whole chain: trust anchor first, linkage and role flags, signatures
+// with each acting user's key, and that every node's intents are permitted upstream.
+// If anchors also implements InvalidatedAuthorities, no node may be signed by an
+// invalidated user.
+func Verify(chain []hs.AuthorityLinkedListNode, keys UserKeyResolver, anchors TrustAnchorSet) error {
+	if len(chain) == 0 {
+		return ErrEmptyChain
+	}
+	if !anchors.IsTrustAnchor(chain[0].ThisAuthorityNode.AuthorityUser) {
+		return ErrNotTrustAnchor
+	}
+
+	invalidated, _ := anchors.(InvalidatedAuthorities)
+	seen := make(map[string]bool, len(chain))
+	for i, link := range chain {
+		node := link.ThisAuthorityNode
+		if invalidated != nil && invalidated.IsInvalidated(node.AuthorityUser) {
+			return fmt.Errorf("%w: %s", ErrInvalidatedAuthority, node.AuthorityUser.UserID)
+		}
+		if node.NodeID == "" || seen[node.NodeID] {
+			return fmt.Errorf("%w: missing or repeated node ID at position %d", ErrBrokenLink, i)
+		}
+		seen[node.NodeID] = true
+
+		if err := checkLink(chain, i); err != nil {
+			return err
+		}
+		if node.AuthorityIntentCount != len(node.AuthorityIntents) || len(node.AuthorityIntents) == 0 {
+			return fmt.Errorf("%w: node %s intent count mismatch", ErrBrokenLink, node.NodeID)
+		}
+
+		var prevID, prevSig string
+		if i > 0 {
+			prev := chain[i-1].ThisAuthorityNode
+			prevID, prevSig = prev.NodeID, prev.AuthoritySignature
+			if node.AuthorizingAtUnixTimestamp < prev.AuthorizingAtUnixTimestamp {
+				return fmt.Errorf("%w: node %s predates its provisioning authority", ErrBrokenLink, node.NodeID)
+			}
+			if err := checkIntents(prev, intentStrings(node.AuthorityIntents)); err != nil {
+				return fmt.Errorf("node %s: %w", node.NodeID, err)
+			}
+		}
+		if err := verifyNode(node, prevID, prevSig, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyActorContext verifies ctx.AuthorityChain and the counts ActorContext carries.
+func VerifyActorContext(ctx *hs.ActorContext, keys UserKeyResolver, anchors TrustAnchorSet) error {
+	if len(ctx.AuthorityChain) == 0 {
+		return ErrActorContextMissing
+	}
+	if ctx.AuthorityNodeCount != len(ctx.AuthorityChain) {
+		return errors.New("actor context authority node count mismatch")
+	}
+	if ctx.TargetUserCount != len(ctx.TargetUsers) {
+		return errors.New("actor context target user count mismatch")
+	}
+	return Verify(ctx.AuthorityChain, keys, anchors)
+}
+
+// Intents lists the intents the last node of a verified chain was granted.
+func Intents(chain []hs.AuthorityLinkedListNode) []string {
+	if len(chain) == 0 {
+		return nil
+	}
+	return intentStrings(chain[len(chain)-1].ThisAuthorityNode.AuthorityIntents)
+}
+
+func checkLink(chain []hs.AuthorityLinkedListNode, i int) error {
+	link := chain[i]
+	first, last := i == 0, i == len(chain)-1
+
+	var wantPrev, wantNext string
+	if !first {
+		wantPrev = chain[i-1].ThisAuthorityNode.NodeID
+	}
+	if !last {
+		wantNext = chain[i+1].ThisAuthorityNode.NodeID
+	}
+
+	switch {
+	case link.PreviousAuthorityNodeID != wantPrev:
+		return fmt.Errorf("%w: node %s previous ID", ErrBrokenLink, link.ThisAuthorityNode.NodeID)
+	case link.NextAuthorityNodeID != wantNext:
+		return fmt.Errorf("%w: node %s next ID", ErrBrokenLink, link.ThisAuthorityNode.NodeID)
+	case link.IsTrustAnchor != first,
+		link.IsLastAuthority != last,
+		link.IsIntermediateAuthority != (!first && !last):
+		return fmt.Errorf("%w: node %s role flags", ErrBrokenLink, link.ThisAuthorityNode.NodeID)
+	}
+	return nil
+}
+
+// checkIntents requires every intent to be non-empty and permitted by one of upstream's.
+func checkIntents(upstream hs.AuthorityNode, intents []string) error {
+	for _, child := range intents {
+		if child == "" {
+			return fmt.Errorf("%w: empty intent", ErrIntentNotPermitted)
+		}
+		permitted := false
+		for _, parent := range upstream.AuthorityIntents {
+			if IntentPermits(parent.Intent, child) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return fmt.Errorf("%w: %s", ErrIntentNotPermitted, child)
+		}
+	}
+	return nil
+}
+
+func signNode(signer Signer, intents []string, prevID, prevSig string) (hs.AuthorityNode, error) {
+	if len(intents) == 0 {
+		return hs.AuthorityNode{}, errors.New("authority node needs at least one intent")
+	}
+	for _, intent := range intents {
+		if intent == "" {
+			return hs.AuthorityNode{}, fmt.Errorf("%w: empty intent", ErrIntentNotPermitted)
+		}
+	}
+
+	nodeID, err := randomString(NodeIDSize)
+	if err != nil {
+		return hs.AuthorityNode{}, err
+	}
+	nonce, err := randomString(NonceSize)
+	if err != nil {
+		return hs.AuthorityNode{}, err
+	}
+
+	node := hs.AuthorityNode{
+		NodeID:                     nodeID,
+		AuthorityUser:              signer.User,
+		AuthorityIntentCount:       len(intents),
+		AuthorityIntents:           make([]hs.AuthorityIntent, len(intents)),
+		AuthorizingAtUnixTimestamp: time.Now().Unix(),
+		Nonce:                      nonce,
+		AuthoritySignatureKeyID:    signer.KeyID,
+	}
+	for i, intent := range intents {
+		node.AuthorityIntents[i] = hs.AuthorityIntent{Intent: intent}
+	}
+
+	toSign, err := CanonicalBytes(node, prevID, prevSig)
+	if err != nil {
+		return hs.AuthorityNode{}, err
+	}
+	sig, err := ed448_api.SignWithContext(signer.Key, toSign, sigctx.HestiaAuthorityNode)
+	if err != nil {
+		return hs.AuthorityNode{}, err
+	}
+	node.AuthoritySignature = base64.RawURLEncoding.EncodeToString(sig)
+	return node, nil
+}
+
+func verifyNode(node hs.AuthorityNode, prevID, prevSig string, keys UserKeyResolver) error {
+	pub, err := keys.UserPublicKey(node.AuthorityUser, node.AuthoritySignatureKeyID)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(node.AuthoritySignature)
+	if err != nil {
+		return fmt.Errorf("%w: node %s", ErrInvalidSignature, node.NodeID)
+	}
+	msg, err := CanonicalBytes(node, prevID, prevSig)
+	if err != nil {
+		return err
+	}
+	if !ed448_api.VerifyWithContext(pub, msg, sig, sigctx.HestiaAuthorityNode) {
+		return fmt.Errorf("%w: node %s", ErrInvalidSignature, node.NodeID)
+	}
+	return nil
+}
+
+func intentStrings(intents []hs.AuthorityIntent) []string {
+	out := make([]string, len(intents))
+	for i, intent := range intents {
+		out[i] = intent.Intent
+	}
+	return out
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,104 @@
+package authority_chain
+
+import (
+	"testing"
+
+	hs "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/hestia/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+const passwordReset = "Entitlements::Issuances::Grants::PasswordReset"
+
+func newSigner(t *testing.T, keys *MemoryUserKeys, group, id string) Signer {
+	priv, pub, err := ed448_api.GenerateKeyPair()
+	require.NoError(t, err)
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: group, UserID: id}
+	keys.Add(user, id+"-k1", pub)
+	return Signer{User: user, KeyID: id + "-k1", Key: priv}
+}
+
+// Artem (TA) -> Dima (Coach) -> Amanda (Staff), scenario [b]
+func buildChain(t *testing.T) ([]hs.AuthorityLinkedListNode, *MemoryUserKeys, StaticTrustAnchors, Signer) {
+	keys := NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	dima := newSigner(t, keys, uagc.UserGroupCoach, "dima")
+	amanda := newSigner(t, keys, uagc.UserGroupStaff, "amanda")
+
+	chain, err := NewChain(artem, IntentAll)
+	require.NoError(t, err)
+	chain, err = Append(chain, dima, "Entitlements", "Roles::Coach")
+	require.NoError(t, err)
+	chain, err = Append(chain, amanda, passwordReset)
+	require.NoError(t, err)
+	return chain, keys, StaticTrustAnchors{artem.User: true}, dima
+}
+
+func TestBuildAndVerify(t *testing.T) {
+	chain, keys, anchors, _ := buildChain(t)
+	require.NoError(t, Verify(chain, keys, anchors))
+	require.Equal(t, []string{passwordReset}, Intents(chain))
+
+	require.True(t, chain[0].IsTrustAnchor)
+	require.True(t, chain[1].IsIntermediateAuthority)
+	require.True(t, chain[2].IsLastAuthority)
+	require.Equal(t, chain[1].ThisAuthorityNode.NodeID, chain[0].NextAuthorityNodeID)
+	require.Equal(t, chain[1].ThisAuthorityNode.NodeID, chain[2].PreviousAuthorityNodeID)
+
+	require.NoError(t, VerifyActorContext(&hs.ActorContext{
+		AuthorityNodeCount: len(chain),
+		AuthorityChain:     chain,
+	}, keys, anchors))
+	require.Error(t, VerifyActorContext(&hs.ActorContext{AuthorityNodeCount: 1, AuthorityChain: chain}, keys, anchors))
+}
+
+func TestIntentMustBePermittedUpstream(t *testing.T) {
+	chain, keys, _, dima := buildChain(t)
+	_, err := Append(chain, dima, "Roles::Coach")
+	require.ErrorIs(t, err, ErrIntentNotPermitted) // Amanda only holds PasswordReset
+
+	require.True(t, IntentPermits("Entitlements", passwordReset))
+	require.False(t, IntentPermits("Entitle", passwordReset))
+
+	// A forged escalation signed by the right key still fails the upstream check
+	amanda := chain[2].ThisAuthorityNode.AuthorityUser
+	priv, pub, err := ed448_api.GenerateKeyPair()
+	require.NoError(t, err)
+	keys.Add(amanda, "amanda-k2", pub)
+	forged, err := signNode(Signer{User: amanda, KeyID: "amanda-k2", Key: priv}, []string{"Roles::Developer"},
+		chain[1].ThisAuthorityNode.NodeID, chain[1].ThisAuthorityNode.AuthoritySignature)
+	require.NoError(t, err)
+	chain[2].ThisAuthorityNode = forged
+	chain[1].NextAuthorityNodeID = forged.NodeID
+	require.ErrorIs(t, Verify(chain, keys, StaticTrustAnchors{chain[0].ThisAuthorityNode.AuthorityUser: true}), ErrIntentNotPermitted)
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	t.Run("not a trust anchor", func(t *testing.T) {
+		chain, keys, _, _ := buildChain(t)
+		require.ErrorIs(t, Verify(chain, keys, StaticTrustAnchors{}), ErrNotTrustAnchor)
+	})
+	t.Run("edited intent", func(t *testing.T) {
+		chain, keys, anchors, _ := buildChain(t)
+		chain[2].ThisAuthorityNode.AuthorityIntents[0].Intent = "Entitlements::Issuances"
+		require.ErrorIs(t, Verify(chain, keys, anchors), ErrInvalidSignature)
+	})
+	t.Run("dropped intermediate", func(t *testing.T) {
+		chain, keys, anchors, _ := buildChain(t)
+		spliced := []hs.AuthorityLinkedListNode{chain[0], chain[2]}
+		spliced[0].NextAuthorityNodeID = chain[2].ThisAuthorityNode.NodeID
+		spliced[1].PreviousAuthorityNodeID = chain[0].ThisAuthorityNode.NodeID
+		require.ErrorIs(t, Verify(spliced, keys, anchors), ErrInvalidSignature)
+	})
+	t.Run("broken link", func(t *testing.T) {
+		chain, keys, anchors, _ := buildChain(t)
+		chain[1].NextAuthorityNodeID = "elsewhere"
+		require.ErrorIs(t, Verify(chain, keys, anchors), ErrBrokenLink)
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		chain, keys, anchors, _ := buildChain(t)
+		keys.Remove(chain[1].ThisAuthorityNode.AuthorityUser, chain[1].ThisAuthorityNode.AuthoritySignatureKeyID)
+		require.ErrorIs(t, Verify(chain, keys, anchors), ErrUnknownUserKey)
+	})
+}
@@ -0,0 +1,37 @@
+package authority_chain
+
+import (
+	hs "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/hestia/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+)
+
+// CanonicalBytes returns the bytes an AuthorityNode signature covers: every field but
+// AuthoritySignature, followed by the node ID and signature of the node before it, encoded
+// per utils/canonical in this order:
+//
+//	node_id, authority_user.{tenant_id, user_group_id, user_id, sub_id},
+//	authority_intent_count (int), authority_intents[].intent,
+//	authorizing_at_unix_timestamp (int), nonce, authority_signature_key_id,
+//	previous_node_id, previous_signature
+//
+// Covering the previous signature links every node to the exact chain it was appended to,
+// so nodes cannot be reordered or spliced into another chain. Both are "" for a trust anchor.
+func CanonicalBytes(n hs.AuthorityNode, previousNodeID, previousSignature string) ([]byte, error) {
+	e := canonical.NewEncoder("HestiaAuthorityNode").
+		String(n.NodeID).
+		String(n.AuthorityUser.TenantID).
+		String(n.AuthorityUser.UserGroupID).
+		String(n.AuthorityUser.UserID).
+		String(n.AuthorityUser.SubID).
+		Int64(int64(len(n.AuthorityIntents)))
+	for _, intent := range n.AuthorityIntents {
+		e.String(intent.Intent)
+	}
+	return e.
+		Int64(n.AuthorizingAtUnixTimestamp).
+		String(n.Nonce).
+		String(n.AuthoritySignatureKeyID).
+		String(previousNodeID).
+		String(previousSignature).
+		Bytes()
+}
@@ -0,0 +1,72 @@
+package authority_chain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// UserKeyResolver looks up the Ed448 public key a user signs authority nodes with.
+type UserKeyResolver interface {
+	UserPublicKey(user uagc.UniqueUser, keyID string) (ed448_api.PublicKey, error)
+}
+
+// TrustAnchorSet decides which users may start a chain.
+type TrustAnchorSet interface {
+	IsTrustAnchor(user uagc.UniqueUser) bool
+}
+
+// InvalidatedAuthorities is implemented by TrustAnchorSets that can withdraw a user's
+// authority after the fact (see trust_anchor.Registry). Verify rejects every chain with a
+// node signed by such a user, so whatever they provisioned downstream stops counting.
+type InvalidatedAuthorities interface {
+	IsInvalidated(user uagc.UniqueUser) bool
+}
+
+// StaticTrustAnchors is a fixed set of trust anchors.
+type StaticTrustAnchors map[uagc.UniqueUser]bool
+
+func (s StaticTrustAnchors) IsTrustAnchor(user uagc.UniqueUser) bool {
+	return s[user]
+}
+
+type userKeyID struct {
+	user  uagc.UniqueUser
+	keyID string
+}
+
+// MemoryUserKeys is an in-memory UserKeyResolver.
+type MemoryUserKeys struct {
+	mu   sync.RWMutex
+	keys map[userKeyID]ed448_api.PublicKey
+}
+
+func NewMemoryUserKeys() *MemoryUserKeys {
+	return &MemoryUserKeys{keys: make(map[userKeyID]ed448_api.PublicKey)}
+}
+
+// Add registers (or replaces) one of the user's keys.
+func (m *MemoryUserKeys) Add(user uagc.UniqueUser, keyID string, pub ed448_api.PublicKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[userKeyID{user: user, keyID: keyID}] = pub
+}
+
+// Remove drops a key; nodes signed with it no longer verify.
+func (m *MemoryUserKeys) Remove(user uagc.UniqueUser, keyID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, userKeyID{user: user, keyID: keyID})
+}
+
+func (m *MemoryUserKeys) UserPublicKey(user uagc.UniqueUser, keyID string) (ed448_api.PublicKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pub, ok := m.keys[userKeyID{user: user, keyID: keyID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s for user %s", ErrUnknownUserKey, keyID, user.UserID)
+	}
+	return pub, nil
+}
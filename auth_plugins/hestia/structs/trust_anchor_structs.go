@@ -0,0 +1,64 @@
+package structs
+
+import (
+	"time"
+
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+const (
+	TrustAnchorRecordVersion = "v1"
+
+	TrustAnchorProposalAdmit = "TA_PROPOSAL_ADMIT" // Make Subject a trust anchor
+	TrustAnchorProposalVeto  = "TA_PROPOSAL_VETO"  // Invalidate Subject and everything they provisioned
+
+	TrustAnchorProposalPending  = "PENDING"
+	TrustAnchorProposalPassed   = "PASSED"
+	TrustAnchorProposalRejected = "REJECTED"
+	TrustAnchorProposalExpired  = "EXPIRED"
+
+	TrustAnchorProposalDefaultTTL = 7 * 24 * time.Hour
+	TrustAnchorNonceSize          = 16
+)
+
+// TrustAnchorProposal is a TA's signed motion to admit or veto a trust anchor.
+// Filing it counts as the proposer's own approving vote.
+type TrustAnchorProposal struct {
+	Version                 string          `json:"version"`
+	ProposalID              string          `json:"proposal_id"`
+	Kind                    string          `json:"kind"`
+	Subject                 uagc.UniqueUser `json:"subject"`
+	Proposer                uagc.UniqueUser `json:"proposer"`
+	Reason                  string          `json:"reason,omitempty"`
+	ProposedAtUnixTimestamp int64           `json:"proposed_at_unix_timestamp"`
+	ExpiresAtUnixTimestamp  int64           `json:"expires_at_unix_timestamp"`
+	Nonce                   string          `json:"nonce"`
+	SignatureKeyID          string          `json:"signature_key_id"`
+	Signature               string          `json:"signature"`
+}
+
+// TrustAnchorVote is a TA's signed ballot on one proposal.
+type TrustAnchorVote struct {
+	Version              string          `json:"version"`
+	ProposalID           string          `json:"proposal_id"`
+	Voter                uagc.UniqueUser `json:"voter"`
+	Approve              bool            `json:"approve"`
+	VotedAtUnixTimestamp int64           `json:"voted_at_unix_timestamp"`
+	Nonce                string          `json:"nonce"`
+	SignatureKeyID       string          `json:"signature_key_id"`
+	Signature            string          `json:"signature"`
+}
+
+// TrustAnchorTally is where a proposal stands. Electorate is the set of TAs active when
+// it was filed; a vote stops counting if its voter is vetoed before the proposal closes.
+type TrustAnchorTally struct {
+	ProposalID             string            `json:"proposal_id"`
+	Status                 string            `json:"status"`
+	Electorate             []uagc.UniqueUser `json:"electorate"`
+	Quorum                 int               `json:"quorum"`
+	Approvals              int               `json:"approvals"`
+	Rejections             int               `json:"rejections"`
+	DecidedAtUnixTimestamp int64             `json:"decided_at_unix_timestamp,omitempty"`
+	ApprovingVoters        []uagc.UniqueUser `json:"approving_voters,omitempty"`
+	RejectingVoters        []uagc.UniqueUser `json:"rejecting_voters,omitempty"`
+}
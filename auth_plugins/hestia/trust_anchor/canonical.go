@@ -0,0 +1,54 @@
+package trust_anchor
+
+import (
+	hs "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/hestia/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+)
+
+// ProposalCanonicalBytes returns the bytes a TrustAnchorProposal signature covers: every
+// field but Signature, encoded per utils/canonical in this order:
+//
+//	version, proposal_id, kind, subject.{tenant_id, user_group_id, user_id, sub_id},
+//	proposer.{tenant_id, user_group_id, user_id, sub_id}, reason,
+//	proposed_at_unix_timestamp (int), expires_at_unix_timestamp (int), nonce,
+//	signature_key_id
+func ProposalCanonicalBytes(p hs.TrustAnchorProposal) ([]byte, error) {
+	e := canonical.NewEncoder("HestiaTrustAnchorProposal").
+		String(p.Version).
+		String(p.ProposalID).
+		String(p.Kind)
+	encodeUser(e, p.Subject)
+	encodeUser(e, p.Proposer)
+	return e.
+		String(p.Reason).
+		Int64(p.ProposedAtUnixTimestamp).
+		Int64(p.ExpiresAtUnixTimestamp).
+		String(p.Nonce).
+		String(p.SignatureKeyID).
+		Bytes()
+}
+
+// VoteCanonicalBytes returns the bytes a TrustAnchorVote signature covers. The signature
+// of the proposal voted on is included, so a ballot cannot be replayed onto another
+// proposal that reuses its ID:
+//
+//	version, proposal_id, proposal_signature, voter.{tenant_id, user_group_id, user_id,
+//	sub_id}, approve (bool), voted_at_unix_timestamp (int), nonce, signature_key_id
+func VoteCanonicalBytes(v hs.TrustAnchorVote, proposalSignature string) ([]byte, error) {
+	e := canonical.NewEncoder("HestiaTrustAnchorVote").
+		String(v.Version).
+		String(v.ProposalID).
+		String(proposalSignature)
+	encodeUser(e, v.Voter)
+	return e.
+		Bool(v.Approve).
+		Int64(v.VotedAtUnixTimestamp).
+		String(v.Nonce).
+		String(v.SignatureKeyID).
+		Bytes()
+}
+
+func encodeUser(e *canonical.Encoder, u uagc.UniqueUser) {
+	e.String(u.TenantID).String(u.UserGroupID).String(u.UserID).String(u.SubID)
+}
@@ -0,0 +1,615 @@
+// Package trust_anchor keeps the set of Hestia trust anchors (TAs) and changes it only by
+// majority vote: any TA may propose admitting a new TA or vetoing an existing one, every
+// proposal and ballot is Ed448-signed by the acting TA, and a proposal passes once more
+// than half of the TAs active when it was filed approve it. The subject of a veto neither
+// votes on it nor counts towards its quorum.
+//
+// A passed veto invalidates the TA until a later proposal re-admits them. A Registry opened
+// with OpenRegistry journals every accepted record to a trust_anchor_store and catches up
+// on the journal before every decision, so admissions and vetoes survive a restart and
+// every server sharing the store sees the same TA set. Registry implements
+// authority_chain.TrustAnchorSet and InvalidatedAuthorities, so every chain the vetoed TA
+// started or signed a node in stops verifying, and with it everything they provisioned.
+package trust_anchor
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	ac "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/hestia/authority_chain"
+	hs "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/hestia/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	"github.com/drlzh/mng-app-user-auth-prot/trust_anchor_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// MinAdmitApprovals keeps a lone TA from admitting accounts it controls: admitting a TA
+// always takes at least two approvals, however small the electorate.
+const MinAdmitApprovals = 2
+
+var (
+	ErrNotTrustAnchor    = errors.New("not an active trust anchor")
+	ErrInvalidSignature  = errors.New("invalid signature on trust anchor record")
+	ErrUnknownProposal   = errors.New("unknown trust anchor proposal")
+	ErrProposalClosed    = errors.New("trust anchor proposal is no longer pending")
+	ErrNotInElectorate   = errors.New("voter was not a trust anchor when the proposal was filed")
+	ErrAlreadyVoted      = errors.New("trust anchor has already voted on this proposal")
+	ErrInvalidProposal   = errors.New("invalid trust anchor proposal")
+	ErrDuplicateProposal = errors.New("a proposal for this subject is already pending")
+)
+
+// Quorum is the number of approvals that is strictly more than half of electorate.
+func Quorum(electorate int) int {
+	return electorate/2 + 1
+}
+
+func quorumFor(kind string, electorate int) int {
+	q := Quorum(electorate)
+	if kind == hs.TrustAnchorProposalAdmit && q < MinAdmitApprovals {
+		q = MinAdmitApprovals
+	}
+	return q
+}
+
+type proposalState struct {
+	proposal   hs.TrustAnchorProposal
+	electorate []uagc.UniqueUser
+	votes      map[uagc.UniqueUser]hs.TrustAnchorVote
+	status     string
+	decidedAt  int64
+}
+
+// Registry is a TA registry seeded with a genesis set. Without a store it lives in memory
+// only.
+type Registry struct {
+	mu          sync.Mutex
+	keys        ac.UserKeyResolver
+	store       trust_anchor_store.TrustAnchorStore // nil = not journaled
+	seq         int64                               // Last journal record applied
+	anchors     map[uagc.UniqueUser]bool
+	invalidated map[uagc.UniqueUser]string // Vetoed TA -> veto proposal ID
+	proposals   map[string]*proposalState
+	now         func() time.Time
+}
+
+// NewRegistry starts a registry whose TAs are genesis. Record signatures are checked
+// against keys.
+func NewRegistry(keys ac.UserKeyResolver, genesis ...uagc.UniqueUser) *Registry {
+	r := &Registry{
+		keys:        keys,
+		anchors:     make(map[uagc.UniqueUser]bool, len(genesis)),
+		invalidated: make(map[uagc.UniqueUser]string),
+		proposals:   make(map[string]*proposalState),
+		now:         time.Now,
+	}
+	for _, u := range genesis {
+		r.anchors[u] = true
+	}
+	return r
+}
+
+// OpenRegistry starts a registry from genesis, replays every record in store on top of it
+// and journals new records there. Records were verified when first accepted, so replay
+// does not re-check signatures and a TA rotating their key later leaves history intact.
+// A record the rebuilt state rejects means the journal or genesis set changed, and
+// OpenRegistry fails rather than start with a different TA set.
+func OpenRegistry(store trust_anchor_store.TrustAnchorStore, keys ac.UserKeyResolver, genesis ...uagc.UniqueUser) (*Registry, error) {
+	r := NewRegistry(keys, genesis...)
+	r.store = store
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.syncLocked(); err != nil {
+		return nil, err
+	}
+	r.settleLocked(r.now().Unix())
+	return r, nil
+}
+
+// syncLocked replays the records other servers journaled since this registry last looked.
+// The store only accepts a record appended on top of the latest one, so each was checked
+// against the full history before it and replays the same way everywhere.
+func (r *Registry) syncLocked() error {
+	if r.store == nil {
+		return nil
+	}
+	records, err := r.store.ListSince(r.seq)
+	if err != nil {
+		return fmt.Errorf("load trust anchor journal: %w", err)
+	}
+	for _, rec := range records {
+		if err := r.replayLocked(rec); err != nil {
+			return fmt.Errorf("replay trust anchor record %d: %w", rec.Seq, err)
+		}
+		r.seq = rec.Seq
+	}
+	return nil
+}
+
+func (r *Registry) replayLocked(rec trust_anchor_store.StoredRecord) error {
+	now := rec.AcceptedAtUnixTimestamp
+	switch rec.Kind {
+	case trust_anchor_store.RecordProposal:
+		var p hs.TrustAnchorProposal
+		if err := json.Unmarshal(rec.Encoded, &p); err != nil {
+			return err
+		}
+		r.settleLocked(now)
+		if err := r.checkProposalLocked(&p, now); err != nil {
+			return err
+		}
+		r.fileProposalLocked(&p, now)
+
+	case trust_anchor_store.RecordVote:
+		var v hs.TrustAnchorVote
+		if err := json.Unmarshal(rec.Encoded, &v); err != nil {
+			return err
+		}
+		s, ok := r.proposals[v.ProposalID]
+		if !ok {
+			return ErrUnknownProposal
+		}
+		r.settleLocked(now)
+		if err := r.checkVoteLocked(s, &v); err != nil {
+			return err
+		}
+		r.castVoteLocked(s, &v, now)
+
+	default:
+		return fmt.Errorf("unknown record kind %q", rec.Kind)
+	}
+	return nil
+}
+
+// IsTrustAnchor reports whether user is currently an active TA. It reports false if the
+// journal cannot be read.
+func (r *Registry) IsTrustAnchor(user uagc.UniqueUser) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.syncLocked(); err != nil {
+		return false
+	}
+	return r.anchors[user]
+}
+
+// IsInvalidated reports whether user was vetoed and not re-admitted since. It reports true
+// if the journal cannot be read, so chain verification fails closed.
+func (r *Registry) IsInvalidated(user uagc.UniqueUser) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.syncLocked(); err != nil {
+		return true
+	}
+	_, ok := r.invalidated[user]
+	return ok
+}
+
+// Anchors lists the active TAs.
+func (r *Registry) Anchors() ([]uagc.UniqueUser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.syncLocked(); err != nil {
+		return nil, err
+	}
+	r.settleLocked(r.now().Unix())
+	return r.activeLocked(), nil
+}
+
+// NewProposal builds and signs a proposal of kind about subject, filed by signer.
+func NewProposal(kind string, subject uagc.UniqueUser, reason string, ttl time.Duration, signer ac.Signer) (*hs.TrustAnchorProposal, error) {
+	if ttl <= 0 {
+		ttl = hs.TrustAnchorProposalDefaultTTL
+	}
+	id, err := randomString(hs.TrustAnchorNonceSize)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString(hs.TrustAnchorNonceSize)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	p := hs.TrustAnchorProposal{
+		Version:                 hs.TrustAnchorRecordVersion,
+		ProposalID:              id,
+		Kind:                    kind,
+		Subject:                 subject,
+		Proposer:                signer.User,
+		Reason:                  reason,
+		ProposedAtUnixTimestamp: now.Unix(),
+		ExpiresAtUnixTimestamp:  now.Add(ttl).Unix(),
+		Nonce:                   nonce,
+		SignatureKeyID:          signer.KeyID,
+	}
+	toSign, err := ProposalCanonicalBytes(p)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ed448_api.SignWithContext(signer.Key, toSign, sigctx.HestiaTAProposal)
+	if err != nil {
+		return nil, err
+	}
+	p.Signature = base64.RawURLEncoding.EncodeToString(sig)
+	return &p, nil
+}
+
+// NewVote builds and signs signer's ballot on p.
+func NewVote(p *hs.TrustAnchorProposal, approve bool, signer ac.Signer) (*hs.TrustAnchorVote, error) {
+	nonce, err := randomString(hs.TrustAnchorNonceSize)
+	if err != nil {
+		return nil, err
+	}
+	v := hs.TrustAnchorVote{
+		Version:              hs.TrustAnchorRecordVersion,
+		ProposalID:           p.ProposalID,
+		Voter:                signer.User,
+		Approve:              approve,
+		VotedAtUnixTimestamp: time.Now().Unix(),
+		Nonce:                nonce,
+		SignatureKeyID:       signer.KeyID,
+	}
+	toSign, err := VoteCanonicalBytes(v, p.Signature)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ed448_api.SignWithContext(signer.Key, toSign, sigctx.HestiaTAVote)
+	if err != nil {
+		return nil, err
+	}
+	v.Signature = base64.RawURLEncoding.EncodeToString(sig)
+	return &v, nil
+}
+
+// Propose files p. The proposer must be an active TA; filing counts as their approval,
+// which alone may already decide it.
+func (r *Registry) Propose(p *hs.TrustAnchorProposal) (*hs.TrustAnchorTally, error) {
+	msg, err := ProposalCanonicalBytes(*p)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.verify(p.Proposer, p.SignatureKeyID, p.Signature, msg, sigctx.HestiaTAProposal); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.acceptLocked(trust_anchor_store.RecordProposal, p,
+		func(now int64) error { return r.checkProposalLocked(p, now) },
+		func(now int64) *proposalState { return r.fileProposalLocked(p, now) },
+	)
+}
+
+func (r *Registry) checkProposalLocked(p *hs.TrustAnchorProposal, now int64) error {
+	switch {
+	case p.Version != hs.TrustAnchorRecordVersion:
+		return fmt.Errorf("%w: unsupported version %q", ErrInvalidProposal, p.Version)
+	case !r.anchors[p.Proposer]:
+		return ErrNotTrustAnchor
+	case p.ExpiresAtUnixTimestamp <= now:
+		return fmt.Errorf("%w: already expired", ErrInvalidProposal)
+	case r.proposals[p.ProposalID] != nil:
+		return fmt.Errorf("%w: proposal ID in use", ErrInvalidProposal)
+	case p.Subject == p.Proposer:
+		return fmt.Errorf("%w: proposer is the subject", ErrInvalidProposal)
+	}
+	switch p.Kind {
+	case hs.TrustAnchorProposalAdmit:
+		if r.anchors[p.Subject] {
+			return fmt.Errorf("%w: subject is already a trust anchor", ErrInvalidProposal)
+		}
+	case hs.TrustAnchorProposalVeto:
+		if !r.anchors[p.Subject] {
+			return fmt.Errorf("%w: subject is not a trust anchor", ErrInvalidProposal)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidProposal, p.Kind)
+	}
+	for _, s := range r.proposals {
+		if s.status == hs.TrustAnchorProposalPending && s.proposal.Subject == p.Subject && s.proposal.Kind == p.Kind {
+			return ErrDuplicateProposal
+		}
+	}
+	return nil
+}
+
+// fileProposalLocked opens p to the active TAs, less the subject of a veto.
+func (r *Registry) fileProposalLocked(p *hs.TrustAnchorProposal, now int64) *proposalState {
+	electorate := r.activeLocked()
+	if p.Kind == hs.TrustAnchorProposalVeto {
+		electorate = without(electorate, p.Subject)
+	}
+	s := &proposalState{
+		proposal:   *p,
+		electorate: electorate,
+		votes:      make(map[uagc.UniqueUser]hs.TrustAnchorVote),
+		status:     hs.TrustAnchorProposalPending,
+	}
+	r.proposals[p.ProposalID] = s
+	r.settleLocked(now)
+	return s
+}
+
+// Vote records v. The voter must have been in the proposal's electorate and still be an
+// active TA; the subject of a veto does not vote on it.
+func (r *Registry) Vote(v *hs.TrustAnchorVote) (*hs.TrustAnchorTally, error) {
+	r.mu.Lock()
+	err := r.syncLocked()
+	s, ok := r.proposals[v.ProposalID]
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrUnknownProposal
+	}
+
+	msg, err := VoteCanonicalBytes(*v, s.proposal.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.verify(v.Voter, v.SignatureKeyID, v.Signature, msg, sigctx.HestiaTAVote); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.acceptLocked(trust_anchor_store.RecordVote, v,
+		func(int64) error { return r.checkVoteLocked(s, v) },
+		func(now int64) *proposalState {
+			r.castVoteLocked(s, v, now)
+			return s
+		},
+	)
+}
+
+func (r *Registry) checkVoteLocked(s *proposalState, v *hs.TrustAnchorVote) error {
+	switch {
+	case v.Version != hs.TrustAnchorRecordVersion:
+		return fmt.Errorf("%w: unsupported version %q", ErrInvalidProposal, v.Version)
+	case s.status != hs.TrustAnchorProposalPending:
+		return ErrProposalClosed
+	case s.proposal.Kind == hs.TrustAnchorProposalVeto && v.Voter == s.proposal.Subject:
+		return fmt.Errorf("%w: a trust anchor does not vote on its own veto", ErrInvalidProposal)
+	case !contains(s.electorate, v.Voter):
+		return ErrNotInElectorate
+	case !r.anchors[v.Voter]:
+		return ErrNotTrustAnchor
+	case v.Voter == s.proposal.Proposer:
+		return ErrAlreadyVoted
+	}
+	if _, dup := s.votes[v.Voter]; dup {
+		return ErrAlreadyVoted
+	}
+	return nil
+}
+
+func (r *Registry) castVoteLocked(s *proposalState, v *hs.TrustAnchorVote, now int64) {
+	s.votes[v.Voter] = *v
+	r.settleLocked(now)
+}
+
+// acceptLocked catches up on the journal, checks record against the result and journals
+// it before apply changes any state. If another server appended first, the store refuses
+// the record and it is checked again on top of what that server wrote.
+func (r *Registry) acceptLocked(kind string, record any, check func(now int64) error, apply func(now int64) *proposalState) (*hs.TrustAnchorTally, error) {
+	for {
+		if err := r.syncLocked(); err != nil {
+			return nil, err
+		}
+		now := r.now().Unix()
+		r.settleLocked(now)
+		if err := check(now); err != nil {
+			return nil, err
+		}
+		err := r.journalLocked(kind, record, now)
+		if errors.Is(err, trust_anchor_store.ErrSeqConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return r.tallyLocked(apply(now)), nil
+	}
+}
+
+// journalLocked persists an accepted record before it changes any state, so the journal
+// never lags behind what callers were told.
+func (r *Registry) journalLocked(kind string, record any, now int64) error {
+	if r.store == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := r.store.Append(trust_anchor_store.StoredRecord{
+		Seq:                     r.seq + 1,
+		Kind:                    kind,
+		AcceptedAtUnixTimestamp: now,
+		Encoded:                 encoded,
+	}); err != nil {
+		return fmt.Errorf("journal trust anchor %s: %w", kind, err)
+	}
+	r.seq++
+	return nil
+}
+
+// Tally reports where a proposal stands.
+func (r *Registry) Tally(proposalID string) (*hs.TrustAnchorTally, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.syncLocked(); err != nil {
+		return nil, err
+	}
+	s, ok := r.proposals[proposalID]
+	if !ok {
+		return nil, ErrUnknownProposal
+	}
+	r.settleLocked(r.now().Unix())
+	return r.tallyLocked(s), nil
+}
+
+// settleLocked decides every pending proposal it can. A passed veto changes who counts
+// on the others, so it repeats until nothing changes.
+func (r *Registry) settleLocked(now int64) {
+	for changed := true; changed; {
+		changed = false
+		for _, s := range r.proposals {
+			if s.status != hs.TrustAnchorProposalPending {
+				continue
+			}
+			if now >= s.proposal.ExpiresAtUnixTimestamp {
+				s.status, s.decidedAt = hs.TrustAnchorProposalExpired, now
+				changed = true
+				continue
+			}
+
+			t := r.tallyLocked(s)
+			switch {
+			case !r.anchors[s.proposal.Proposer]:
+				// A vetoed TA's open proposals fall with them
+				s.status, s.decidedAt = hs.TrustAnchorProposalRejected, now
+				changed = true
+			case t.Approvals >= t.Quorum:
+				s.status, s.decidedAt = hs.TrustAnchorProposalPassed, now
+				r.applyLocked(s.proposal)
+				changed = true
+			case t.Approvals+r.outstandingLocked(s) < t.Quorum:
+				s.status, s.decidedAt = hs.TrustAnchorProposalRejected, now
+				changed = true
+			}
+		}
+	}
+}
+
+func (r *Registry) applyLocked(p hs.TrustAnchorProposal) {
+	switch p.Kind {
+	case hs.TrustAnchorProposalAdmit:
+		r.anchors[p.Subject] = true
+		delete(r.invalidated, p.Subject)
+	case hs.TrustAnchorProposalVeto:
+		delete(r.anchors, p.Subject)
+		r.invalidated[p.Subject] = p.ProposalID
+	}
+}
+
+// tallyLocked counts the proposer and each voter only while they remain active TAs.
+func (r *Registry) tallyLocked(s *proposalState) *hs.TrustAnchorTally {
+	t := &hs.TrustAnchorTally{
+		ProposalID:             s.proposal.ProposalID,
+		Status:                 s.status,
+		Electorate:             s.electorate,
+		Quorum:                 quorumFor(s.proposal.Kind, len(s.electorate)),
+		DecidedAtUnixTimestamp: s.decidedAt,
+	}
+	if r.anchors[s.proposal.Proposer] {
+		t.Approvals++
+		t.ApprovingVoters = append(t.ApprovingVoters, s.proposal.Proposer)
+	}
+	for _, voter := range sortedVoters(s.votes) {
+		if !r.anchors[voter] {
+			continue
+		}
+		if s.votes[voter].Approve {
+			t.Approvals++
+			t.ApprovingVoters = append(t.ApprovingVoters, voter)
+		} else {
+			t.Rejections++
+			t.RejectingVoters = append(t.RejectingVoters, voter)
+		}
+	}
+	return t
+}
+
+// outstandingLocked counts electorate members who are still active and may yet vote.
+func (r *Registry) outstandingLocked(s *proposalState) int {
+	n := 0
+	for _, u := range s.electorate {
+		if _, voted := s.votes[u]; voted || u == s.proposal.Proposer || !r.anchors[u] {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func (r *Registry) activeLocked() []uagc.UniqueUser {
+	out := make([]uagc.UniqueUser, 0, len(r.anchors))
+	for u := range r.anchors {
+		out = append(out, u)
+	}
+	sortUsers(out)
+	return out
+}
+
+func (r *Registry) verify(user uagc.UniqueUser, keyID, signature string, msg []byte, ctx string) error {
+	pub, err := r.keys.UserPublicKey(user, keyID)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !ed448_api.VerifyWithContext(pub, msg, sig, ctx) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func sortedVoters(votes map[uagc.UniqueUser]hs.TrustAnchorVote) []uagc.UniqueUser {
+	out := make([]uagc.UniqueUser, 0, len(votes))
+	for u := range votes {
+		out = append(out, u)
+	}
+	sortUsers(out)
+	return out
+}
+
+func sortUsers(users []uagc.UniqueUser) {
+	sort.Slice(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+		if a.TenantID != b.TenantID {
+			return a.TenantID < b.TenantID
+		}
+		if a.UserGroupID != b.UserGroupID {
+			return a.UserGroupID < b.UserGroupID
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.SubID < b.SubID
+	})
+}
+
+func contains(users []uagc.UniqueUser, u uagc.UniqueUser) bool {
+	for _, x := range users {
+		if x == u {
+			return true
+		}
+	}
+	return false
+}
+
+func without(users []uagc.UniqueUser, u uagc.UniqueUser) []uagc.UniqueUser {
+	out := make([]uagc.UniqueUser, 0, len(users))
+	for _, x := range users {
+		if x != u {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,292 @@
+package trust_anchor
+
+import (
+	"testing"
+	"time"
+
+	ac "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/hestia/authority_chain"
+	hs "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/hestia/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/trust_anchor_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+	"github.com/stretchr/testify/require"
+)
+
+func newSigner(t *testing.T, keys *ac.MemoryUserKeys, group, id string) ac.Signer {
+	priv, pub, err := ed448_api.GenerateKeyPair()
+	require.NoError(t, err)
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: group, UserID: id}
+	keys.Add(user, id+"-k1", pub)
+	return ac.Signer{User: user, KeyID: id + "-k1", Key: priv}
+}
+
+func propose(t *testing.T, r *Registry, kind string, subject uagc.UniqueUser, signer ac.Signer) (*hs.TrustAnchorProposal, *hs.TrustAnchorTally) {
+	p, err := NewProposal(kind, subject, "", 0, signer)
+	require.NoError(t, err)
+	tally, err := r.Propose(p)
+	require.NoError(t, err)
+	return p, tally
+}
+
+func vote(t *testing.T, r *Registry, p *hs.TrustAnchorProposal, approve bool, signer ac.Signer) *hs.TrustAnchorTally {
+	v, err := NewVote(p, approve, signer)
+	require.NoError(t, err)
+	tally, err := r.Vote(v)
+	require.NoError(t, err)
+	return tally
+}
+
+func anchors(t *testing.T, r *Registry) []uagc.UniqueUser {
+	out, err := r.Anchors()
+	require.NoError(t, err)
+	return out
+}
+
+func TestQuorum(t *testing.T) {
+	require.Equal(t, 1, Quorum(1))
+	require.Equal(t, 2, Quorum(2))
+	require.Equal(t, 2, Quorum(3))
+	require.Equal(t, 3, Quorum(4))
+	require.Equal(t, 2, quorumFor(hs.TrustAnchorProposalAdmit, 1))
+}
+
+func TestLoneTrustAnchorCannotEscalate(t *testing.T) {
+	keys := ac.NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	sock := newSigner(t, keys, uagc.UserGroupDeveloper, "sock-puppet")
+	r := NewRegistry(keys, artem.User)
+
+	_, tally := propose(t, r, hs.TrustAnchorProposalAdmit, sock.User, artem)
+	require.Equal(t, hs.TrustAnchorProposalRejected, tally.Status)
+	require.False(t, r.IsTrustAnchor(sock.User))
+
+	// Non-TAs cannot propose at all
+	p, err := NewProposal(hs.TrustAnchorProposalAdmit, sock.User, "", 0, sock)
+	require.NoError(t, err)
+	_, err = r.Propose(p)
+	require.ErrorIs(t, err, ErrNotTrustAnchor)
+}
+
+func TestAdmitByMajority(t *testing.T) {
+	keys := ac.NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	zhenhai := newSigner(t, keys, uagc.UserGroupDeveloper, "zhenhai")
+	dima := newSigner(t, keys, uagc.UserGroupCoach, "dima")
+	r := NewRegistry(keys, artem.User, zhenhai.User)
+
+	p, tally := propose(t, r, hs.TrustAnchorProposalAdmit, dima.User, zhenhai)
+	require.Equal(t, hs.TrustAnchorProposalPending, tally.Status)
+	require.Equal(t, 2, tally.Quorum)
+
+	// A ballot edited after signing does not count
+	v, err := NewVote(p, false, artem)
+	require.NoError(t, err)
+	v.Approve = true
+	_, err = r.Vote(v)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	tally = vote(t, r, p, true, artem)
+	require.Equal(t, hs.TrustAnchorProposalPassed, tally.Status)
+	require.True(t, r.IsTrustAnchor(dima.User))
+
+	_, err = r.Vote(v)
+	require.Error(t, err)
+}
+
+func TestVetoInvalidatesChains(t *testing.T) {
+	keys := ac.NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	zhenhai := newSigner(t, keys, uagc.UserGroupDeveloper, "zhenhai")
+	dima := newSigner(t, keys, uagc.UserGroupCoach, "dima")
+	amanda := newSigner(t, keys, uagc.UserGroupStaff, "amanda")
+	r := NewRegistry(keys, artem.User, zhenhai.User, dima.User)
+
+	own, err := ac.NewChain(zhenhai, ac.IntentAll)
+	require.NoError(t, err)
+	own, err = ac.Append(own, amanda, "Entitlements::AssignBeltRank")
+	require.NoError(t, err)
+	through, err := ac.NewChain(artem, ac.IntentAll)
+	require.NoError(t, err)
+	through, err = ac.Append(through, zhenhai, "Entitlements")
+	require.NoError(t, err)
+	through, err = ac.Append(through, amanda, "Entitlements::AssignBeltRank")
+	require.NoError(t, err)
+	require.NoError(t, ac.Verify(own, keys, r))
+	require.NoError(t, ac.Verify(through, keys, r))
+
+	pending, _ := propose(t, r, hs.TrustAnchorProposalAdmit, amanda.User, zhenhai)
+
+	veto, tally := propose(t, r, hs.TrustAnchorProposalVeto, zhenhai.User, artem)
+	require.Equal(t, hs.TrustAnchorProposalPending, tally.Status)
+	v, err := NewVote(veto, false, zhenhai)
+	require.NoError(t, err)
+	_, err = r.Vote(v)
+	require.ErrorIs(t, err, ErrInvalidProposal)
+
+	tally = vote(t, r, veto, true, dima)
+	require.Equal(t, hs.TrustAnchorProposalPassed, tally.Status)
+	require.True(t, r.IsInvalidated(zhenhai.User))
+	require.ElementsMatch(t, []uagc.UniqueUser{artem.User, dima.User}, anchors(t, r))
+
+	require.ErrorIs(t, ac.Verify(own, keys, r), ac.ErrNotTrustAnchor)
+	require.ErrorIs(t, ac.Verify(through, keys, r), ac.ErrInvalidatedAuthority)
+
+	tally, err = r.Tally(pending.ProposalID)
+	require.NoError(t, err)
+	require.Equal(t, hs.TrustAnchorProposalRejected, tally.Status)
+}
+
+func TestVetoAmongTwoAnchors(t *testing.T) {
+	keys := ac.NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	zhenhai := newSigner(t, keys, uagc.UserGroupDeveloper, "zhenhai")
+	r := NewRegistry(keys, artem.User, zhenhai.User)
+
+	// The subject is not in the electorate, so the other TA alone is a majority
+	_, tally := propose(t, r, hs.TrustAnchorProposalVeto, zhenhai.User, artem)
+	require.Equal(t, []uagc.UniqueUser{artem.User}, tally.Electorate)
+	require.Equal(t, 1, tally.Quorum)
+	require.Equal(t, hs.TrustAnchorProposalPassed, tally.Status)
+	require.True(t, r.IsInvalidated(zhenhai.User))
+	require.Equal(t, []uagc.UniqueUser{artem.User}, anchors(t, r))
+}
+
+func TestProposalExpires(t *testing.T) {
+	keys := ac.NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	zhenhai := newSigner(t, keys, uagc.UserGroupDeveloper, "zhenhai")
+	dima := newSigner(t, keys, uagc.UserGroupCoach, "dima")
+	r := NewRegistry(keys, artem.User, zhenhai.User)
+
+	p, _ := propose(t, r, hs.TrustAnchorProposalAdmit, dima.User, zhenhai)
+	r.now = func() time.Time { return time.Now().Add(hs.TrustAnchorProposalDefaultTTL + time.Minute) }
+
+	v, err := NewVote(p, true, artem)
+	require.NoError(t, err)
+	_, err = r.Vote(v)
+	require.ErrorIs(t, err, ErrProposalClosed)
+	require.False(t, r.IsTrustAnchor(dima.User))
+}
+
+func TestRegistryReplaysJournal(t *testing.T) {
+	keys := ac.NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	zhenhai := newSigner(t, keys, uagc.UserGroupDeveloper, "zhenhai")
+	dima := newSigner(t, keys, uagc.UserGroupCoach, "dima")
+	amanda := newSigner(t, keys, uagc.UserGroupStaff, "amanda")
+	store := trust_anchor_store.NewGhettoAdapter(ghetto_db.New())
+	genesis := []uagc.UniqueUser{artem.User, zhenhai.User}
+
+	r, err := OpenRegistry(store, keys, genesis...)
+	require.NoError(t, err)
+	admit, _ := propose(t, r, hs.TrustAnchorProposalAdmit, dima.User, artem)
+	require.Equal(t, hs.TrustAnchorProposalPassed, vote(t, r, admit, true, zhenhai).Status)
+	veto, _ := propose(t, r, hs.TrustAnchorProposalVeto, zhenhai.User, artem)
+	require.Equal(t, hs.TrustAnchorProposalPassed, vote(t, r, veto, true, dima).Status)
+	pending, _ := propose(t, r, hs.TrustAnchorProposalAdmit, amanda.User, dima)
+
+	// A restarted server sees the same anchors, vetoes and open proposals
+	reopened, err := OpenRegistry(store, keys, genesis...)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uagc.UniqueUser{artem.User, dima.User}, anchors(t, reopened))
+	require.True(t, reopened.IsInvalidated(zhenhai.User))
+	tally, err := reopened.Tally(pending.ProposalID)
+	require.NoError(t, err)
+	require.Equal(t, hs.TrustAnchorProposalPending, tally.Status)
+	require.Equal(t, hs.TrustAnchorProposalPassed, vote(t, reopened, pending, true, artem).Status)
+	require.True(t, reopened.IsTrustAnchor(amanda.User))
+
+	// The first server never restarted, but reads what the second one journaled
+	require.True(t, r.IsTrustAnchor(amanda.User))
+
+	// The journal only replays onto the genesis set it was written against
+	_, err = OpenRegistry(store, keys, artem.User)
+	require.Error(t, err)
+}
+
+func TestRegistriesShareJournal(t *testing.T) {
+	keys := ac.NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	zhenhai := newSigner(t, keys, uagc.UserGroupDeveloper, "zhenhai")
+	dima := newSigner(t, keys, uagc.UserGroupCoach, "dima")
+	store := trust_anchor_store.NewGhettoAdapter(ghetto_db.New())
+	genesis := []uagc.UniqueUser{artem.User, zhenhai.User, dima.User}
+
+	a, err := OpenRegistry(store, keys, genesis...)
+	require.NoError(t, err)
+	b, err := OpenRegistry(store, keys, genesis...)
+	require.NoError(t, err)
+
+	// A veto filed on one server is voted through on the other and holds on both
+	veto, _ := propose(t, a, hs.TrustAnchorProposalVeto, zhenhai.User, artem)
+	require.Equal(t, hs.TrustAnchorProposalPassed, vote(t, b, veto, true, dima).Status)
+	require.True(t, a.IsInvalidated(zhenhai.User))
+	require.False(t, a.IsTrustAnchor(zhenhai.User))
+
+	// The vetoed TA can no longer propose through the server that has not seen a vote yet
+	p, err := NewProposal(hs.TrustAnchorProposalVeto, artem.User, "", 0, zhenhai)
+	require.NoError(t, err)
+	_, err = a.Propose(p)
+	require.ErrorIs(t, err, ErrNotTrustAnchor)
+
+	// Each server checks against the proposals the other has open
+	first, err := NewProposal(hs.TrustAnchorProposalAdmit, zhenhai.User, "", 0, artem)
+	require.NoError(t, err)
+	second, err := NewProposal(hs.TrustAnchorProposalAdmit, zhenhai.User, "", 0, dima)
+	require.NoError(t, err)
+	_, err = a.Propose(first)
+	require.NoError(t, err)
+	_, err = b.Propose(second)
+	require.ErrorIs(t, err, ErrDuplicateProposal)
+}
+
+// racingStore lets another server append just before the next Append, as if both had
+// checked their record against the same journal.
+type racingStore struct {
+	trust_anchor_store.TrustAnchorStore
+	before func()
+}
+
+func (s *racingStore) Append(rec trust_anchor_store.StoredRecord) error {
+	if before := s.before; before != nil {
+		s.before = nil
+		before()
+	}
+	return s.TrustAnchorStore.Append(rec)
+}
+
+func TestRegistryRechecksAfterLosingAppend(t *testing.T) {
+	keys := ac.NewMemoryUserKeys()
+	artem := newSigner(t, keys, uagc.UserGroupDeveloper, "artem")
+	zhenhai := newSigner(t, keys, uagc.UserGroupDeveloper, "zhenhai")
+	dima := newSigner(t, keys, uagc.UserGroupCoach, "dima")
+	shared := trust_anchor_store.NewGhettoAdapter(ghetto_db.New())
+	racing := &racingStore{TrustAnchorStore: shared}
+	genesis := []uagc.UniqueUser{artem.User, zhenhai.User}
+
+	a, err := OpenRegistry(racing, keys, genesis...)
+	require.NoError(t, err)
+	b, err := OpenRegistry(shared, keys, genesis...)
+	require.NoError(t, err)
+
+	first, err := NewProposal(hs.TrustAnchorProposalAdmit, dima.User, "", 0, artem)
+	require.NoError(t, err)
+	second, err := NewProposal(hs.TrustAnchorProposalAdmit, dima.User, "", 0, zhenhai)
+	require.NoError(t, err)
+	racing.before = func() {
+		_, err := b.Propose(second)
+		require.NoError(t, err)
+	}
+
+	// a's append loses the race, and on the journal b wrote its proposal is a duplicate
+	_, err = a.Propose(first)
+	require.ErrorIs(t, err, ErrDuplicateProposal)
+	records, err := shared.ListSince(0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	tally, err := a.Tally(second.ProposalID)
+	require.NoError(t, err)
+	require.Equal(t, hs.TrustAnchorProposalPending, tally.Status)
+}
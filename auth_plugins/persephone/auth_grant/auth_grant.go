@@ -5,8 +5,12 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"log"
+
 	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/grant_store"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
 	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 	"time"
 )
@@ -28,6 +32,7 @@ func CreateAuthGrant(
 	now := time.Now().Unix()
 	expiry := now + int64(ttl.Seconds())
 
+	keyID, priv := uagc.Ed448AuthGrantSigningKey()
 	grant := ag.AuthGrant{
 		Version:                ag.AuthGrantVersion,
 		GrantID:                grantID,
@@ -38,16 +43,15 @@ func CreateAuthGrant(
 		Scope:                  scope,
 		Nonce:                  nonceB64,
 		Payload:                payload,
-		SigningKeyIdentifier:   ag.AuthGrantCurrentSigningKeyIdentifier,
+		SigningKeyIdentifier:   keyID,
 	}
 
-	// Serialize without signature
-	toSign, err := json.Marshal(withoutSig(grant))
+	toSign, err := ag.CanonicalBytes(grant)
 	if err != nil {
 		return nil, err
 	}
 
-	sig, err := ed448_api.Sign(uagc.Ed448AuthTicketPrivateKey(), toSign)
+	sig, err := ed448_api.SignWithContext(priv, toSign, sigctx.AuthGrant)
 	if err != nil {
 		return nil, err
 	}
@@ -55,19 +59,17 @@ func CreateAuthGrant(
 	return &grant, nil
 }
 
-func withoutSig(g ag.AuthGrant) ag.AuthGrant {
-	g.Signature = ""
-	return g
-}
-
 func VerifyAuthGrant(grant *ag.AuthGrant) error {
 	if time.Now().Unix() > grant.ExpiresAtUnixTimestamp {
 		return errors.New("auth grant has expired")
 	}
 
-	toVerify := withoutSig(*grant)
+	pub, err := uagc.Ed448AuthGrantVerificationKey(grant.SigningKeyIdentifier, grant.IssuedAtUnixTimestamp)
+	if err != nil {
+		return err
+	}
 
-	data, err := json.Marshal(toVerify)
+	data, err := ag.CanonicalBytes(*grant)
 	if err != nil {
 		return err
 	}
@@ -77,9 +79,92 @@ func VerifyAuthGrant(grant *ag.AuthGrant) error {
 		return err
 	}
 
-	if !ed448_api.Verify(sig, data, uagc.Ed448AuthTicketPublicKey()) {
+	if !ed448_api.VerifyWithContext(pub, data, sig, sigctx.AuthGrant) {
 		return errors.New("invalid signature on AuthGrant")
 	}
 
 	return nil
 }
+
+// VerifyAuthGrantForUser verifies the grant and additionally checks that it was issued
+// for the given purpose and is bound to the given CoreUser. Returns the decoded subject.
+func VerifyAuthGrantForUser(grant *ag.AuthGrant, purpose string, user uagc.CoreUser) (*ag.AuthGrantSubject, error) {
+	if grant == nil {
+		return nil, errors.New("missing auth grant")
+	}
+	if grant.Version != ag.AuthGrantVersion {
+		return nil, errors.New("unsupported auth grant version")
+	}
+	if err := VerifyAuthGrant(grant); err != nil {
+		return nil, err
+	}
+	if grant.GrantType != purpose {
+		return nil, errors.New("auth grant purpose mismatch")
+	}
+
+	var subject ag.AuthGrantSubject
+	if err := json.Unmarshal(grant.Payload, &subject); err != nil {
+		return nil, errors.New("invalid auth grant subject")
+	}
+	if subject.TargetUser != user {
+		return nil, errors.New("auth grant is bound to a different user")
+	}
+
+	return &subject, nil
+}
+
+// CheckAuthGrantUnspent fails early if the grant was already consumed or revoked.
+// It does not consume the grant; see ConsumeAuthGrant.
+func CheckAuthGrantUnspent(ledger grant_store.AuthGrantLedger, grant *ag.AuthGrant) error {
+	revoked, err := ledger.IsRevoked(grant.GrantID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return grant_store.ErrGrantRevoked
+	}
+
+	consumed, err := ledger.IsConsumed(grant.GrantID, grant.Nonce)
+	if err != nil {
+		return err
+	}
+	if consumed {
+		return grant_store.ErrGrantAlreadyConsumed
+	}
+	return nil
+}
+
+// ConsumeAuthGrant marks the grant as spent. Only the first call for a given grant succeeds.
+func ConsumeAuthGrant(ledger grant_store.AuthGrantLedger, grant *ag.AuthGrant) error {
+	return ledger.Consume(grant.GrantID, grant.Nonce, grant.ExpiresAtUnixTimestamp)
+}
+
+// ReleaseAuthGrant hands a consumed grant back after the write it guarded failed,
+// so the same magic link can be retried.
+func ReleaseAuthGrant(ledger grant_store.AuthGrantLedger, grant *ag.AuthGrant) {
+	if err := ledger.Release(grant.GrantID, grant.Nonce); err != nil {
+		log.Printf("⚠️ Failed to release auth grant %s: %v", grant.GrantID, err)
+	}
+}
+
+// EncodeAuthGrant returns a compact, URL-safe form of the grant for magic links and QR codes.
+func EncodeAuthGrant(grant *ag.AuthGrant) (string, error) {
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeAuthGrant reverses EncodeAuthGrant. The result still needs to be verified.
+func DecodeAuthGrant(encoded string) (*ag.AuthGrant, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("invalid base64 in encoded auth grant")
+	}
+	var grant ag.AuthGrant
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return nil, errors.New("invalid encoded auth grant")
+	}
+	return &grant, nil
+}
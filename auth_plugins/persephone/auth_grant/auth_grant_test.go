@@ -0,0 +1,194 @@
+package auth_grant
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/keystore"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAuthGrantForUser(t *testing.T) {
+	target := uagc.CoreUser{TenantID: "dojo-a", UserID: "akira"}
+	subject, err := json.Marshal(ag.AuthGrantSubject{TargetUser: target})
+	require.NoError(t, err)
+
+	grant, err := CreateAuthGrant("grant-1", ag.AuthGrantPurposePasswordReset, "", "", subject, time.Minute)
+	require.NoError(t, err)
+
+	got, err := VerifyAuthGrantForUser(grant, ag.AuthGrantPurposePasswordReset, target)
+	require.NoError(t, err)
+	require.Equal(t, target, got.TargetUser)
+
+	// Wrong purpose
+	_, err = VerifyAuthGrantForUser(grant, ag.AuthGrantPurposeRegister, target)
+	require.Error(t, err)
+
+	// Wrong user
+	_, err = VerifyAuthGrantForUser(grant, ag.AuthGrantPurposePasswordReset, uagc.CoreUser{TenantID: "dojo-a", UserID: "mallory"})
+	require.Error(t, err)
+
+	// Tampered binding
+	tampered := *grant
+	tampered.Payload, _ = json.Marshal(ag.AuthGrantSubject{TargetUser: uagc.CoreUser{TenantID: "dojo-a", UserID: "mallory"}})
+	_, err = VerifyAuthGrantForUser(&tampered, ag.AuthGrantPurposePasswordReset, uagc.CoreUser{TenantID: "dojo-a", UserID: "mallory"})
+	require.Error(t, err)
+}
+
+func TestCheckIssuance(t *testing.T) {
+	coach := uagc.UniqueUser{TenantID: "dojo-a", UserID: "dima", UserGroupID: uagc.UserGroupCoach}
+	staff := uagc.UniqueUser{TenantID: "dojo-a", UserID: "amanda", UserGroupID: uagc.UserGroupStaff}
+	child := uagc.UniqueUser{TenantID: "dojo-a", UserID: "x", UserGroupID: uagc.UserGroupChild}
+	target := uagc.CoreUser{TenantID: "dojo-a", UserID: "new-student"}
+
+	ttl, err := CheckIssuance(coach, ag.AuthGrantPurposeRegister, target, []string{uagc.UserGroupStaff}, 0)
+	require.NoError(t, err)
+	require.Equal(t, ag.AuthGrantDefaultTTL, ttl)
+
+	// Staff may register students but not reset passwords or create staff
+	_, err = CheckIssuance(staff, ag.AuthGrantPurposeRegister, target, []string{uagc.UserGroupChild}, time.Hour)
+	require.NoError(t, err)
+	_, err = CheckIssuance(staff, ag.AuthGrantPurposePasswordReset, target, nil, time.Hour)
+	require.Error(t, err)
+	_, err = CheckIssuance(staff, ag.AuthGrantPurposeRegister, target, []string{uagc.UserGroupStaff}, time.Hour)
+	require.Error(t, err)
+
+	// Coaches reset the students below them, never a peer coach or a staff account
+	_, err = CheckIssuance(coach, ag.AuthGrantPurposePasswordReset, target, []string{uagc.UserGroupChild, uagc.UserGroupParent}, time.Hour)
+	require.NoError(t, err)
+	_, err = CheckIssuance(coach, ag.AuthGrantPurposePasswordReset, target, []string{uagc.UserGroupCoach}, time.Hour)
+	require.Error(t, err)
+	_, err = CheckIssuance(coach, ag.AuthGrantPurposePasswordReset, target, []string{uagc.UserGroupStaff}, time.Hour)
+	require.Error(t, err)
+	_, err = CheckIssuance(coach, ag.AuthGrantPurposePasswordReset, target, []string{uagc.UserGroupChild, uagc.UserGroupStaff}, time.Hour)
+	require.Error(t, err)
+
+	// An account with no bindings cannot be reset by anyone
+	_, err = CheckIssuance(coach, ag.AuthGrantPurposePasswordReset, target, nil, time.Hour)
+	require.Error(t, err)
+
+	// No cross-tenant issuance, no issuance by plain members
+	_, err = CheckIssuance(coach, ag.AuthGrantPurposeRegister, uagc.CoreUser{TenantID: "dojo-b", UserID: "y"}, []string{uagc.UserGroupChild}, 0)
+	require.Error(t, err)
+	_, err = CheckIssuance(child, ag.AuthGrantPurposeRegister, target, []string{uagc.UserGroupChild}, 0)
+	require.Error(t, err)
+}
+
+func TestCheckIssuanceFollowsTenantPolicy(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dojo-b.datalog"), []byte(`
+		group_right("USER_GROUP_COACH", "own_profile", "read");
+		right($r, $op) <- user_group($g), group_right($g, $r, $op);
+	`), 0o600))
+	e, err := policy.LoadDir(dir)
+	require.NoError(t, err)
+	prev := policy.Active()
+	policy.Install(e)
+	t.Cleanup(func() { policy.Install(prev) })
+
+	// dojo-b's policy withholds right(auth_grant, issue), so its IssuanceRule never applies
+	coachA := uagc.UniqueUser{TenantID: "dojo-a", UserID: "dima", UserGroupID: uagc.UserGroupCoach}
+	coachB := uagc.UniqueUser{TenantID: "dojo-b", UserID: "dima", UserGroupID: uagc.UserGroupCoach}
+	_, err = CheckIssuance(coachA, ag.AuthGrantPurposeRegister, uagc.CoreUser{TenantID: "dojo-a", UserID: "x"}, []string{uagc.UserGroupChild}, 0)
+	require.NoError(t, err)
+	_, err = CheckIssuance(coachB, ag.AuthGrantPurposeRegister, uagc.CoreUser{TenantID: "dojo-b", UserID: "x"}, []string{uagc.UserGroupChild}, 0)
+	require.ErrorIs(t, err, policy.ErrDenied)
+}
+
+func TestEncodeDecodeAuthGrant(t *testing.T) {
+	grant, err := CreateAuthGrant("grant-1", ag.AuthGrantPurposeRegister, "", "", json.RawMessage(`{}`), time.Minute)
+	require.NoError(t, err)
+
+	encoded, err := EncodeAuthGrant(grant)
+	require.NoError(t, err)
+
+	decoded, err := DecodeAuthGrant(encoded)
+	require.NoError(t, err)
+	require.NoError(t, VerifyAuthGrant(decoded))
+}
+
+// rotateSigningKeys installs a copy of the current keystore with a fresh Ed448 pair
+// rotated in under privName/pubName, restoring the original keystore afterwards.
+func rotateSigningKeys(t *testing.T, privName, pubName, newID string) *keystore.MemoryAdapter {
+	original := uagc.ActiveKeyStore()
+	t.Cleanup(func() { require.NoError(t, uagc.SetKeyStore(original)) })
+
+	rotated := keystore.NewMemoryAdapter()
+	for _, name := range keystore.RequiredKeys {
+		for _, k := range original.List(name) {
+			rotated.Put(k)
+		}
+	}
+	priv, pub, err := ed448_api.GenerateKeyPair()
+	require.NoError(t, err)
+	rotated.Rotate(keystore.Key{Name: privName, ID: newID, Material: priv})
+	rotated.Rotate(keystore.Key{Name: pubName, ID: newID, Material: pub})
+	require.NoError(t, uagc.SetKeyStore(rotated))
+	return rotated
+}
+
+func TestAuthGrantSurvivesKeyRotation(t *testing.T) {
+	before, err := CreateAuthGrant("grant-1", ag.AuthGrantPurposeRegister, "", "", nil, time.Minute)
+	require.NoError(t, err)
+
+	rotated := rotateSigningKeys(t, keystore.Ed448AuthGrantPrivate, keystore.Ed448AuthGrantPublic, "Rotated")
+
+	after, err := CreateAuthGrant("grant-2", ag.AuthGrantPurposeRegister, "", "", nil, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "Rotated", after.SigningKeyIdentifier)
+	require.NotEqual(t, before.SigningKeyIdentifier, after.SigningKeyIdentifier)
+
+	// The old key is verify-only now
+	require.NoError(t, VerifyAuthGrant(before))
+	require.NoError(t, VerifyAuthGrant(after))
+
+	// Retiring it invalidates what it signed
+	require.NoError(t, rotated.SetState(keystore.Ed448AuthGrantPublic, before.SigningKeyIdentifier, keystore.KeyStateRetired))
+	require.Error(t, VerifyAuthGrant(before))
+	require.NoError(t, VerifyAuthGrant(after))
+
+	// Claiming another key ID doesn't help
+	forged := *before
+	forged.SigningKeyIdentifier = "Rotated"
+	require.Error(t, VerifyAuthGrant(&forged))
+}
+
+func TestAuthGrantKeyValidityWindow(t *testing.T) {
+	rotated := rotateSigningKeys(t, keystore.Ed448AuthGrantPrivate, keystore.Ed448AuthGrantPublic, "Rotated")
+	grant, err := CreateAuthGrant("grant-1", ag.AuthGrantPurposeRegister, "", "", nil, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, VerifyAuthGrant(grant))
+
+	// A key only vouches for what was issued inside its window
+	pub, err := rotated.GetByID(keystore.Ed448AuthGrantPublic, "Rotated")
+	require.NoError(t, err)
+	pub.NotBefore = grant.IssuedAtUnixTimestamp + 1
+	rotated.Put(pub)
+	require.ErrorIs(t, VerifyAuthGrant(grant), keystore.ErrKeyOutsideWindow)
+}
+
+func TestAuthGrantSignatureIsDomainSeparated(t *testing.T) {
+	grant, err := CreateAuthGrant("grant-1", ag.AuthGrantPurposeRegister, "", "", nil, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "Artemis", grant.SigningKeyIdentifier)
+
+	body, err := ag.CanonicalBytes(*grant)
+	require.NoError(t, err)
+	sig, err := base64.RawURLEncoding.DecodeString(grant.Signature)
+	require.NoError(t, err)
+
+	pub, err := uagc.Ed448AuthGrantVerificationKey(grant.SigningKeyIdentifier, grant.IssuedAtUnixTimestamp)
+	require.NoError(t, err)
+	require.True(t, ed448_api.VerifyWithContext(pub, body, sig, sigctx.AuthGrant))
+	require.False(t, ed448_api.VerifyWithContext(pub, body, sig, sigctx.AuthTicket))
+	require.False(t, ed448_api.Verify(sig, body, pub))
+}
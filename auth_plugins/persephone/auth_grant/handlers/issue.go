@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant"
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// HandleAuthGrantIssue processes PSP_AUTH_GRANT_ISSUE: an authenticated Coach/Staff/... issues a
+// registration or password reset grant for one CoreUser, subject to auth_grant.IssuanceRules.
+func HandleAuthGrantIssue(
+	payload string,
+	traceID string,
+	svc *opaque_api.DefaultOpaqueService,
+) (any, string, string, string) {
+	var req ag.ClientAuthGrantIssuePayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid AuthGrant issue payload", err.Error()
+	}
+
+	issuer, err := auth_ticket.RequireFreshLogin(&req.AuthTicket)
+	if err != nil {
+		return nil, "403", "AuthTicket rejected", err.Error()
+	}
+
+	ev := audit_log.Event{
+		Actor:   issuer,
+		Target:  uagc.UniqueUser{TenantID: req.TargetUser.TenantID, UserID: req.TargetUser.UserID},
+		Command: audit_log.EventAuthGrantIssue,
+		TraceID: traceID,
+	}
+
+	grant, err := issueAuthGrant(svc, issuer, req)
+	if err != nil {
+		return audit_log.Reply(ev, nil, "403", "AuthGrant issuance denied", err.Error())
+	}
+
+	encoded, err := auth_grant.EncodeAuthGrant(grant)
+	if err != nil {
+		return audit_log.Reply(ev, nil, "500", "Failed to encode AuthGrant", err.Error())
+	}
+
+	ev.Detail = fmt.Sprintf("%s grant %s", req.Purpose, grant.GrantID)
+	return audit_log.Reply(ev, ag.AuthGrantIssueSuccessResponse{
+		Version:                ag.AuthGrantIssueResponseVersion,
+		Success:                true,
+		GrantID:                grant.GrantID,
+		ExpiresAtUnixTimestamp: grant.ExpiresAtUnixTimestamp,
+		EncodedAuthGrant:       encoded,
+	}, "200", "AuthGrant issued", "")
+}
+
+func issueAuthGrant(
+	svc *opaque_api.DefaultOpaqueService,
+	issuer uagc.UniqueUser,
+	req ag.ClientAuthGrantIssuePayload,
+) (*ag.AuthGrant, error) {
+	var targetGroups []string
+	switch req.Purpose {
+	case ag.AuthGrantPurposeRegister:
+		if len(req.UserGroupIDs) == 0 {
+			return nil, fmt.Errorf("registration grants must bind at least one user group")
+		}
+		targetGroups = req.UserGroupIDs
+
+	case ag.AuthGrantPurposePasswordReset:
+		// Can only reset users who hold no group above what the issuer could assign
+		bindings, err := svc.Store().GetUserGroupsForUser(req.TargetUser)
+		if err != nil {
+			return nil, fmt.Errorf("target user lookup failed: %w", err)
+		}
+		targetGroups = groupIDs(bindings)
+
+	default:
+		return nil, fmt.Errorf("unknown grant purpose: %s", req.Purpose)
+	}
+
+	ttl, err := auth_grant.CheckIssuance(issuer, req.Purpose, req.TargetUser, targetGroups, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := ag.AuthGrantSubject{
+		TargetUser: req.TargetUser,
+		IssuedBy:   issuer,
+	}
+	if req.Purpose == ag.AuthGrantPurposeRegister {
+		for _, g := range req.UserGroupIDs {
+			subject.UserGroups = append(subject.UserGroups, uagc.UserGroupBinding{
+				CoreUser:    req.TargetUser,
+				UserGroupID: g,
+			})
+		}
+	}
+	subjectBytes, err := json.Marshal(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	grantID, err := newGrantID()
+	if err != nil {
+		return nil, err
+	}
+
+	return auth_grant.CreateAuthGrant(grantID, req.Purpose, "", "", subjectBytes, ttl)
+}
+
+func groupIDs(bindings []uagc.UserGroupBinding) []string {
+	out := make([]string, len(bindings))
+	for i, b := range bindings {
+		out[i] = b.UserGroupID
+	}
+	return out
+}
+
+func newGrantID() (string, error) {
+	buf := make([]byte, ag.AuthGrantIDSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant"
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	"github.com/drlzh/mng-app-user-auth-prot/grant_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// HandleAuthGrantRevoke processes PSP_AUTH_GRANT_REVOKE. The grant's own issuer may revoke
+// it before it is used, as may anyone who could have issued it for the groups it covers.
+func HandleAuthGrantRevoke(
+	payload string,
+	traceID string,
+	svc *opaque_api.DefaultOpaqueService,
+	ledger grant_store.AuthGrantLedger,
+) (any, string, string, string) {
+	var req ag.ClientAuthGrantRevokePayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid AuthGrant revoke payload", err.Error()
+	}
+
+	issuer, err := auth_ticket.RequireFreshLogin(&req.AuthTicket)
+	if err != nil {
+		return nil, "403", "AuthTicket rejected", err.Error()
+	}
+
+	grant, err := auth_grant.DecodeAuthGrant(req.EncodedAuthGrant)
+	if err != nil {
+		return nil, "400", "Invalid AuthGrant", err.Error()
+	}
+
+	ev := audit_log.Event{
+		Actor:   issuer,
+		Command: audit_log.EventAuthGrantRevoke,
+		TraceID: traceID,
+		Detail:  fmt.Sprintf("grant %s", grant.GrantID),
+	}
+
+	target, err := checkRevocation(svc, issuer, grant)
+	ev.Target = uagc.UniqueUser{TenantID: target.TenantID, UserID: target.UserID}
+	if err != nil {
+		return audit_log.Reply(ev, nil, "403", "AuthGrant revocation denied", err.Error())
+	}
+
+	if err := ledger.Revoke(grant.GrantID); err != nil {
+		return audit_log.Reply(ev, nil, "500", "AuthGrant revocation failed", err.Error())
+	}
+
+	return audit_log.Reply(ev, ag.AuthGrantRevokeSuccessResponse{
+		Version: ag.AuthGrantRevokeResponseVersion,
+		Success: true,
+		GrantID: grant.GrantID,
+	}, "200", "AuthGrant revoked", "")
+}
+
+// checkRevocation returns the grant's target user once issuer is allowed to revoke it.
+func checkRevocation(
+	svc *opaque_api.DefaultOpaqueService,
+	issuer uagc.UniqueUser,
+	grant *ag.AuthGrant,
+) (uagc.CoreUser, error) {
+	// Expired grants are already harmless; nothing to revoke
+	if err := auth_grant.VerifyAuthGrant(grant); err != nil {
+		return uagc.CoreUser{}, err
+	}
+
+	var subject ag.AuthGrantSubject
+	if err := json.Unmarshal(grant.Payload, &subject); err != nil {
+		return uagc.CoreUser{}, fmt.Errorf("invalid auth grant subject")
+	}
+	if subject.IssuedBy == issuer {
+		return subject.TargetUser, nil
+	}
+
+	// Otherwise the revoker must be able to issue this grant themselves
+	var groups []string
+	switch grant.GrantType {
+	case ag.AuthGrantPurposeRegister:
+		for _, b := range subject.UserGroups {
+			groups = append(groups, b.UserGroupID)
+		}
+	case ag.AuthGrantPurposePasswordReset:
+		bindings, err := svc.Store().GetUserGroupsForUser(subject.TargetUser)
+		if err != nil {
+			return subject.TargetUser, fmt.Errorf("target user lookup failed: %w", err)
+		}
+		groups = groupIDs(bindings)
+	}
+
+	_, err := auth_grant.CheckIssuance(issuer, grant.GrantType, subject.TargetUser, groups, 0)
+	return subject.TargetUser, err
+}
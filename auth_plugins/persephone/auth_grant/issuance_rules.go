@@ -0,0 +1,101 @@
+package auth_grant
+
+import (
+	"fmt"
+	"time"
+
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// IssuanceRule describes what a holder of a given UserGroup may hand out as AuthGrants.
+type IssuanceRule struct {
+	AllowedPurposes  []string      // AuthGrantPurpose*
+	AssignableGroups []string      // Groups a registration grant may bind
+	ResettableGroups []string      // Groups whose passwords may be reset; only those strictly below the issuer
+	MaxTTL           time.Duration // Upper bound for grant lifetime
+}
+
+// IssuanceRules is keyed by the issuer's UserGroupID. Groups not listed cannot issue grants.
+// Staff cannot issue password resets by default (see Hestia scenario [d]), and no one may
+// reset a peer's password, since a reset grant is a takeover of the account it covers.
+// Whether a user may issue at all is the policy's right(auth_grant, issue); these rules
+// only bound what a permitted issuer hands out.
+var IssuanceRules = map[string]IssuanceRule{
+	uagc.UserGroupDeveloper: {
+		AllowedPurposes: []string{ag.AuthGrantPurposeRegister, ag.AuthGrantPurposePasswordReset},
+		AssignableGroups: []string{
+			uagc.UserGroupAdult, uagc.UserGroupCoach, uagc.UserGroupDeveloper,
+			uagc.UserGroupStaff, uagc.UserGroupParent, uagc.UserGroupChild,
+		},
+		ResettableGroups: []string{
+			uagc.UserGroupAdult, uagc.UserGroupCoach, uagc.UserGroupStaff,
+			uagc.UserGroupParent, uagc.UserGroupChild,
+		},
+		MaxTTL: 7 * 24 * time.Hour,
+	},
+	uagc.UserGroupCoach: {
+		AllowedPurposes: []string{ag.AuthGrantPurposeRegister, ag.AuthGrantPurposePasswordReset},
+		AssignableGroups: []string{
+			uagc.UserGroupAdult, uagc.UserGroupCoach, uagc.UserGroupStaff,
+			uagc.UserGroupParent, uagc.UserGroupChild,
+		},
+		ResettableGroups: []string{uagc.UserGroupAdult, uagc.UserGroupParent, uagc.UserGroupChild},
+		MaxTTL:           72 * time.Hour,
+	},
+	uagc.UserGroupStaff: {
+		AllowedPurposes:  []string{ag.AuthGrantPurposeRegister},
+		AssignableGroups: []string{uagc.UserGroupAdult, uagc.UserGroupParent, uagc.UserGroupChild},
+		MaxTTL:           24 * time.Hour,
+	},
+}
+
+// CheckIssuance validates a grant request against the issuer's tenant policy and
+// IssuanceRule and returns the effective TTL. targetGroups are the groups to bind
+// (register) or currently bound (reset); an empty list is refused, since it would pass the
+// AssignableGroups check vacuously. Issuers always hold a fresh, non-rehydrated ticket.
+func CheckIssuance(issuer uagc.UniqueUser, purpose string, target uagc.CoreUser, targetGroups []string, ttl time.Duration) (time.Duration, error) {
+	if err := policy.Active().AuthorizeUser(issuer, false, policy.ResourceAuthGrant, policy.OperationIssue); err != nil {
+		return 0, fmt.Errorf("user group %s may not issue auth grants: %w", issuer.UserGroupID, err)
+	}
+	rule, ok := IssuanceRules[issuer.UserGroupID]
+	if !ok {
+		return 0, fmt.Errorf("user group %s may not issue auth grants", issuer.UserGroupID)
+	}
+	if !contains(rule.AllowedPurposes, purpose) {
+		return 0, fmt.Errorf("user group %s may not issue %s", issuer.UserGroupID, purpose)
+	}
+	if issuer.TenantID != target.TenantID {
+		return 0, fmt.Errorf("cannot issue auth grants outside own tenant")
+	}
+	if len(targetGroups) == 0 {
+		return 0, fmt.Errorf("auth grant must cover at least one user group")
+	}
+	covered := rule.AssignableGroups
+	if purpose == ag.AuthGrantPurposePasswordReset {
+		covered = rule.ResettableGroups
+	}
+	for _, g := range targetGroups {
+		if !contains(covered, g) {
+			return 0, fmt.Errorf("user group %s may not issue grants covering %s", issuer.UserGroupID, g)
+		}
+	}
+
+	if ttl <= 0 {
+		ttl = ag.AuthGrantDefaultTTL
+	}
+	if ttl > rule.MaxTTL {
+		ttl = rule.MaxTTL
+	}
+	return ttl, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package structs
+
+import "github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+
+// CanonicalBytes returns the bytes an AuthGrant signature covers: every field but
+// Signature, encoded per utils/canonical in this order:
+//
+//	version, grant_id, grant_type, issued_at_unix_timestamp (int),
+//	expires_at_unix_timestamp (int), associated_id, scope, nonce, payload (json),
+//	signing_key_identifier
+//
+// See testdata/canonical_vectors.json for vectors clients can check against.
+func CanonicalBytes(g AuthGrant) ([]byte, error) {
+	return canonical.NewEncoder("AuthGrant").
+		String(g.Version).
+		String(g.GrantID).
+		String(g.GrantType).
+		Int64(g.IssuedAtUnixTimestamp).
+		Int64(g.ExpiresAtUnixTimestamp).
+		String(g.AssociatedID).
+		String(g.Scope).
+		String(g.Nonce).
+		JSON(g.Payload).
+		String(g.SigningKeyIdentifier).
+		Bytes()
+}
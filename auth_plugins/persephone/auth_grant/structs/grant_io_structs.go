@@ -0,0 +1,33 @@
+package structs
+
+import (
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/user_identity"
+)
+
+type ClientAuthGrantIssuePayload struct {
+	AuthTicket   at.AuthTicket          `json:"auth_ticket"`              // Issuer's fresh login ticket
+	Purpose      string                 `json:"purpose"`                  // AuthGrantPurposeRegister / AuthGrantPurposePasswordReset
+	TargetUser   user_identity.CoreUser `json:"target_user"`              // Must be in the issuer's tenant
+	UserGroupIDs []string               `json:"user_group_ids,omitempty"` // Registration only
+	TTLSeconds   int64                  `json:"ttl_seconds,omitempty"`    // 0 = AuthGrantDefaultTTL
+}
+
+type AuthGrantIssueSuccessResponse struct {
+	Version                string `json:"version"`
+	Success                bool   `json:"success"`
+	GrantID                string `json:"grant_id"`
+	ExpiresAtUnixTimestamp int64  `json:"expires_at_unix_timestamp"`
+	EncodedAuthGrant       string `json:"encoded_auth_grant"` // base64url, drop into a magic link or QR code
+}
+
+type ClientAuthGrantRevokePayload struct {
+	AuthTicket       at.AuthTicket `json:"auth_ticket"`
+	EncodedAuthGrant string        `json:"encoded_auth_grant"`
+}
+
+type AuthGrantRevokeSuccessResponse struct {
+	Version string `json:"version"`
+	Success bool   `json:"success"`
+	GrantID string `json:"grant_id"`
+}
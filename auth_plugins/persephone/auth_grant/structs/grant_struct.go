@@ -1,6 +1,9 @@
 package structs
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"github.com/drlzh/mng-app-user-auth-prot/user_identity"
+)
 
 type AuthGrant struct {
 	Version                string          `json:"version"`
@@ -15,3 +18,10 @@ type AuthGrant struct {
 	SigningKeyIdentifier   string          `json:"signing_key_identifier"`    // Signed by the last authority
 	Signature              string          `json:"signature"`
 }
+
+// AuthGrantSubject is carried in AuthGrant.Payload and binds the grant to one CoreUser
+type AuthGrantSubject struct {
+	TargetUser user_identity.CoreUser           `json:"target_user"`           // Who may register / reset
+	UserGroups []user_identity.UserGroupBinding `json:"user_groups,omitempty"` // Groups the grant allows to bind on registration
+	IssuedBy   user_identity.UniqueUser         `json:"issued_by"`             // Authenticated issuer (zero for server-side grants)
+}
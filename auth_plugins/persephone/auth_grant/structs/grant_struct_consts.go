@@ -1,9 +1,17 @@
 package structs
 
+import "time"
+
+const (
+	AuthGrantVersion              string = "v1"
+	AuthGrantPurposeRegister      string = "AUTH_GRANT_PURPOSE_REGISTER"
+	AuthGrantPurposePasswordReset string = "AUTH_GRANT_PURPOSE_PASSWORD_RESET"
+	AuthGrantNonceSize                   = 64
+)
+
 const (
-	AuthGrantVersion                     string = "v1"
-	AuthGrantPurposeRegister             string = "AUTH_GRANT_PURPOSE_REGISTER"
-	AuthGrantPurposePasswordReset        string = "AUTH_GRANT_PURPOSE_PASSWORD_RESET"
-	AuthGrantCurrentSigningKeyIdentifier string = "Artemis"
-	AuthGrantNonceSize                          = 64
+	AuthGrantIDSize                = 16
+	AuthGrantDefaultTTL            = 24 * time.Hour
+	AuthGrantIssueResponseVersion  = "v1"
+	AuthGrantRevokeResponseVersion = "v1"
 )
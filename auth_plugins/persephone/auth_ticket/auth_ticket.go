@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
 	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 	"time"
 )
@@ -24,6 +26,7 @@ func CreateAuthTicket(
 	}
 	nonceB64 := base64.RawURLEncoding.EncodeToString(nonceBuf)
 
+	keyID, priv := uagc.Ed448AuthTicketSigningKey()
 	ticket := at.AuthTicket{
 		Version:               at.AuthTicketVersion,
 		AuthenticatedUser:     user,
@@ -33,15 +36,15 @@ func CreateAuthTicket(
 		Nonce:                 nonceB64,
 		IsRehydrated:          isRehydrated,
 		Payload:               payload,
-		SigningKeyIdentifier:  at.AuthTicketCurrentSigningKeyIdentifier,
+		SigningKeyIdentifier:  keyID,
 	}
 
-	toSign, err := json.Marshal(withoutSig(ticket))
+	toSign, err := at.CanonicalBytes(ticket)
 	if err != nil {
 		return nil, err
 	}
 
-	sig, err := ed448_api.Sign(uagc.Ed448AuthTicketPrivateKey(), toSign)
+	sig, err := ed448_api.SignWithContext(priv, toSign, sigctx.AuthTicket)
 	if err != nil {
 		return nil, err
 	}
@@ -50,31 +53,74 @@ func CreateAuthTicket(
 	return &ticket, nil
 }
 
-func withoutSig(t at.AuthTicket) at.AuthTicket {
-	t.Signature = ""
-	return t
+// VerifyAuthTicket checks the signature, the ticket TTL, and that the login session the
+// ticket belongs to (if any) has not been revoked.
+func VerifyAuthTicket(ticket *at.AuthTicket) error {
+	if err := VerifyAuthTicketSignature(ticket); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if now-ticket.IssuedAtUnixTimestamp > int64(at.AuthTicketTTL.Seconds()) {
+		return errors.New("auth ticket expired")
+	}
+
+	return session.Active().Check(SessionID(ticket))
 }
 
-func VerifyAuthTicket(ticket *at.AuthTicket) error {
-	toVerify := withoutSig(*ticket)
+// RequireFreshLogin verifies ticket and returns its user only if it comes from a full
+// OPAQUE login. Sensitive operations (issuing grants or delegations, unlocking accounts)
+// use it, since a Remember-Me (rehydrated) session is not sufficient for them.
+func RequireFreshLogin(ticket *at.AuthTicket) (uagc.UniqueUser, error) {
+	if err := VerifyAuthTicket(ticket); err != nil {
+		return uagc.UniqueUser{}, err
+	}
+	if ticket.Purpose != at.AuthTicketPurposeLogin || ticket.IsRehydrated {
+		return uagc.UniqueUser{}, errors.New("a fresh login ticket is required")
+	}
+	return ticket.AuthenticatedUser, nil
+}
+
+// SessionID returns the login session a ticket belongs to, or "" for tickets minted
+// outside one. The ticket's signature covers it, so clients cannot move it.
+func SessionID(ticket *at.AuthTicket) string {
+	if len(ticket.Payload) == 0 {
+		return ""
+	}
+	var p at.SessionTicketPayload
+	if err := json.Unmarshal(ticket.Payload, &p); err != nil {
+		return ""
+	}
+	return p.SessionID
+}
 
-	bytes, err := json.Marshal(toVerify)
+// SessionPayload is the ticket Payload for a ticket of sessionID.
+func SessionPayload(sessionID string) (json.RawMessage, error) {
+	return json.Marshal(at.SessionTicketPayload{SessionID: sessionID})
+}
+
+// VerifyAuthTicketSignature checks only the signature, ignoring the ticket TTL.
+// Used where the ticket is embedded in a longer-lived signed object (e.g. Hydrate).
+// The key is selected by SigningKeyIdentifier, so tickets signed before a rotation
+// keep validating until their key is retired.
+func VerifyAuthTicketSignature(ticket *at.AuthTicket) error {
+	pub, err := uagc.Ed448AuthTicketVerificationKey(ticket.SigningKeyIdentifier, ticket.IssuedAtUnixTimestamp)
 	if err != nil {
 		return err
 	}
 
-	sig, err := base64.RawURLEncoding.DecodeString(ticket.Signature)
+	bytes, err := at.CanonicalBytes(*ticket)
 	if err != nil {
 		return err
 	}
 
-	if !ed448_api.Verify(sig, bytes, uagc.Ed448AuthTicketPublicKey()) {
-		return errors.New("invalid signature on AuthTicket")
+	sig, err := base64.RawURLEncoding.DecodeString(ticket.Signature)
+	if err != nil {
+		return err
 	}
 
-	now := time.Now().Unix()
-	if now-ticket.IssuedAtUnixTimestamp > int64(at.AuthTicketTTL.Seconds()) {
-		return errors.New("auth ticket expired")
+	if !ed448_api.VerifyWithContext(pub, bytes, sig, sigctx.AuthTicket) {
+		return errors.New("invalid signature on AuthTicket")
 	}
 
 	return nil
@@ -0,0 +1,46 @@
+package auth_ticket
+
+import (
+	"encoding/json"
+	"testing"
+
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthTicketRoundTrip(t *testing.T) {
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: "ADULT", UserID: "akira"}
+	ticket, err := CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, json.RawMessage(`{"k":"v"}`))
+	require.NoError(t, err)
+	require.NoError(t, VerifyAuthTicket(ticket))
+
+	// What a client in another language would send back
+	wire, err := json.MarshalIndent(ticket, "", "\t")
+	require.NoError(t, err)
+	var received at.AuthTicket
+	require.NoError(t, json.Unmarshal(wire, &received))
+	require.NoError(t, VerifyAuthTicket(&received))
+
+	received.AuthenticatedUser.UserGroupID = "COACH"
+	require.Error(t, VerifyAuthTicket(&received))
+}
+
+func TestRequireFreshLogin(t *testing.T) {
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: "COACH", UserID: "dima"}
+	fresh, err := CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, nil)
+	require.NoError(t, err)
+	got, err := RequireFreshLogin(fresh)
+	require.NoError(t, err)
+	require.Equal(t, user, got)
+
+	rehydrated, err := CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", true, nil)
+	require.NoError(t, err)
+	_, err = RequireFreshLogin(rehydrated)
+	require.Error(t, err)
+
+	switched, err := CreateAuthTicket(user, at.AuthTicketPurposeUserRoleSwitch, "", false, nil)
+	require.NoError(t, err)
+	_, err = RequireFreshLogin(switched)
+	require.Error(t, err)
+}
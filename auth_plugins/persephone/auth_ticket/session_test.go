@@ -0,0 +1,68 @@
+package auth_ticket
+
+import (
+	"testing"
+
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
+	"github.com/drlzh/mng-app-user-auth-prot/session_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+func sessionTicket(t *testing.T, r *session.Registry, key string, user uagc.UniqueUser) *at.AuthTicket {
+	core := uagc.CoreUser{TenantID: user.TenantID, UserID: user.UserID}
+	id, err := r.Start([]byte(key), core, []string{user.UserGroupID}, "device-"+key, "")
+	require.NoError(t, err)
+	payload, err := SessionPayload(id)
+	require.NoError(t, err)
+	ticket, err := CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, payload)
+	require.NoError(t, err)
+	require.Equal(t, id, SessionID(ticket))
+	return ticket
+}
+
+func TestRevokedSessionRejectsTickets(t *testing.T) {
+	r := session.NewRegistry(session_store.NewMemoryAdapter())
+	session.Install(r)
+	defer session.Install(nil)
+
+	akira := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: "ADULT", UserID: "akira"}
+	phone := sessionTicket(t, r, "phone-session-key", akira)
+	laptop := sessionTicket(t, r, "laptop-session-key", akira)
+	require.NoError(t, VerifyAuthTicket(phone))
+	require.NoError(t, VerifyAuthTicket(laptop))
+
+	// Another user cannot end akira's session
+	other := uagc.CoreUser{TenantID: "dojo-a", UserID: "mallory"}
+	require.ErrorIs(t, r.Revoke(other, SessionID(phone)), session_store.ErrSessionNotFound)
+
+	core := uagc.CoreUser{TenantID: akira.TenantID, UserID: akira.UserID}
+	require.NoError(t, r.Revoke(core, SessionID(phone)))
+	require.ErrorIs(t, VerifyAuthTicket(phone), session.ErrSessionRevoked)
+	require.NoError(t, VerifyAuthTicket(laptop))
+
+	// Sign out everywhere else
+	tablet := sessionTicket(t, r, "tablet-session-key", akira)
+	n, err := r.RevokeAll(core, SessionID(laptop))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.ErrorIs(t, VerifyAuthTicket(tablet), session.ErrSessionRevoked)
+	require.NoError(t, VerifyAuthTicket(laptop))
+
+	sessions, err := r.List(core)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+}
+
+func TestUnknownSessionRejectsTickets(t *testing.T) {
+	session.Install(session.NewRegistry(session_store.NewMemoryAdapter()))
+	defer session.Install(nil)
+
+	payload, err := SessionPayload(session.IDFromSessionKey([]byte("never-recorded")))
+	require.NoError(t, err)
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: "ADULT", UserID: "akira"}
+	ticket, err := CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, payload)
+	require.NoError(t, err)
+	require.ErrorIs(t, VerifyAuthTicket(ticket), session.ErrSessionUnknown)
+}
@@ -0,0 +1,28 @@
+package structs
+
+import "github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+
+// CanonicalBytes returns the bytes an AuthTicket signature covers: every field but
+// Signature, encoded per utils/canonical in this order:
+//
+//	version, authenticated_user.{tenant_id, user_group_id, user_id, sub_id},
+//	issued_at_unix_timestamp (int), purpose, scope, nonce, is_rehydrated (bool),
+//	payload (json), signing_key_identifier
+//
+// See testdata/canonical_vectors.json for vectors clients can check against.
+func CanonicalBytes(t AuthTicket) ([]byte, error) {
+	return canonical.NewEncoder("AuthTicket").
+		String(t.Version).
+		String(t.AuthenticatedUser.TenantID).
+		String(t.AuthenticatedUser.UserGroupID).
+		String(t.AuthenticatedUser.UserID).
+		String(t.AuthenticatedUser.SubID).
+		Int64(t.IssuedAtUnixTimestamp).
+		String(t.Purpose).
+		String(t.Scope).
+		String(t.Nonce).
+		Bool(t.IsRehydrated).
+		JSON(t.Payload).
+		String(t.SigningKeyIdentifier).
+		Bytes()
+}
@@ -0,0 +1,46 @@
+package structs
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalVectors(t *testing.T) {
+	f, err := canonical.ReadVectorFile("testdata/canonical_vectors.json")
+	require.NoError(t, err)
+	require.Equal(t, sigctx.AuthTicket, f.SigningContext)
+	pub, err := hex.DecodeString(f.PublicKeyHex)
+	require.NoError(t, err)
+
+	for _, v := range f.Vectors {
+		var ticket AuthTicket
+		require.NoError(t, json.Unmarshal(v.Object, &ticket), v.Name)
+
+		got, err := CanonicalBytes(ticket)
+		require.NoError(t, err, v.Name)
+		require.Equal(t, v.CanonicalHex, hex.EncodeToString(got), v.Name)
+
+		sig, err := base64.RawURLEncoding.DecodeString(ticket.Signature)
+		require.NoError(t, err, v.Name)
+		require.True(t, ed448_api.VerifyWithContext(ed448_api.PublicKey(pub), got, sig, f.SigningContext), v.Name)
+	}
+}
+
+func TestCanonicalBytesIgnorePayloadFormatting(t *testing.T) {
+	ticket := AuthTicket{Version: AuthTicketVersion, Payload: json.RawMessage(`{"b":1,"a":[1.0, "x"]}`)}
+	reencoded := ticket
+	reencoded.Payload = json.RawMessage("{\n  \"a\": [1, \"x\"],\n  \"b\": 1\n}")
+
+	a, err := CanonicalBytes(ticket)
+	require.NoError(t, err)
+	b, err := CanonicalBytes(reencoded)
+	require.NoError(t, err)
+	require.Equal(t, a, b)
+}
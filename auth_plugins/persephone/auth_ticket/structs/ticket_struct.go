@@ -2,20 +2,26 @@ package structs
 
 import (
 	"encoding/json"
-	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/user_identity"
 )
 
 type AuthTicket struct {
-	Version               string          `json:"version"`
-	AuthenticatedUser     uagc.UniqueUser `json:"authenticated_user"`       // Who is being authenticated
-	IssuedAtUnixTimestamp int64           `json:"issued_at_unix_timestamp"` // When?
-	Purpose               string          `json:"purpose"`                  // Why? E.g., login
-	Scope                 string          `json:"scope,omitempty"`          // Reserved for now
-	Nonce                 string          `json:"nonce"`                    // random string
-	IsRehydrated          bool            `json:"is_rehydrated"`            // derived from RememberMe offline storage
-	Payload               json.RawMessage `json:"payload"`                  // Payload
-	SigningKeyIdentifier  string          `json:"signing_key_identifier"`   // allowing key rotation
-	Signature             string          `json:"signature"`                // Ed448 for now
+	Version               string                   `json:"version"`
+	AuthenticatedUser     user_identity.UniqueUser `json:"authenticated_user"`       // Who is being authenticated
+	IssuedAtUnixTimestamp int64                    `json:"issued_at_unix_timestamp"` // When?
+	Purpose               string                   `json:"purpose"`                  // Why? E.g., login
+	Scope                 string                   `json:"scope,omitempty"`          // Reserved for now
+	Nonce                 string                   `json:"nonce"`                    // random string
+	IsRehydrated          bool                     `json:"is_rehydrated"`            // derived from RememberMe offline storage
+	Payload               json.RawMessage          `json:"payload"`                  // Payload
+	SigningKeyIdentifier  string                   `json:"signing_key_identifier"`   // allowing key rotation
+	Signature             string                   `json:"signature"`                // Ed448 for now
+}
+
+// SessionTicketPayload is the Payload of tickets minted by an OPAQUE login, and of the
+// rehydrated and role-switch tickets derived from them. It ties them to their session.
+type SessionTicketPayload struct {
+	SessionID string `json:"session_id"`
 }
 
 type RehydratedTicketPayload struct {
@@ -29,6 +35,7 @@ type RehydratedTicketPayload struct {
 // UserRoleSwitchPayload UserRole switch will be initiated from the offline-cached Hydrate data to
 // facilitate OPAQUE-less 'signing-in-as-another-role' without necessitating a fresh AT
 type UserRoleSwitchPayload struct {
-	RehydratedTicketPayload RehydratedTicketPayload `json:"hydrate_associated_data"`
-	SwitchFrom              uagc.UniqueUser         `json:"switch_from"`
+	RehydratedTicketPayload RehydratedTicketPayload  `json:"hydrate_associated_data"`
+	SwitchFrom              user_identity.UniqueUser `json:"switch_from"`
+	SwitchTo                user_identity.UniqueUser `json:"switch_to"` // Must share the CoreUser of SwitchFrom
 } // TODO: add local identity remembering of the last used role as default to frontend
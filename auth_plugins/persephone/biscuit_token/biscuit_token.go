@@ -0,0 +1,67 @@
+package biscuit_token
+
+import (
+	"time"
+
+	"github.com/biscuit-auth/biscuit-go/v2"
+	"github.com/biscuit-auth/biscuit-go/v2/parser"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	bt "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/biscuit_token/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/biscuit/biscuit_api"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// authorityCode is the authority block every token starts with. Identity comes in as
+// parameters so nothing user-supplied is ever parsed as Datalog. Rights are appended as
+// right(resource, operation) facts derived from the tenant's policy.
+const authorityCode = `
+	tenant({tenant});
+	user({user});
+	user_group({user_group});
+	sub_id({sub_id});
+	rehydrated({rehydrated});
+	check if time($time), $time <= {expires_at};
+`
+
+// Mint issues a Biscuit for the user an already verified AuthTicket authenticated.
+// Returns the encoded token, the root key ID and the expiry.
+func Mint(ticket *at.AuthTicket, ttl time.Duration) (string, string, int64, error) {
+	if ttl <= 0 {
+		ttl = bt.BiscuitDefaultTTL
+	}
+	if ttl > bt.BiscuitMaxTTL {
+		ttl = bt.BiscuitMaxTTL
+	}
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+
+	user := ticket.AuthenticatedUser
+	authority, err := parser.FromStringBlockWithParams(authorityCode, biscuit_api.Params{
+		"tenant":     biscuit.String(user.TenantID),
+		"user":       biscuit.String(user.UserID),
+		"user_group": biscuit.String(user.UserGroupID),
+		"sub_id":     biscuit.String(user.SubID),
+		"rehydrated": biscuit.Bool(ticket.IsRehydrated),
+		"expires_at": biscuit.Date(expiresAt),
+	})
+	if err != nil {
+		return "", "", 0, err
+	}
+	rights, err := policy.Active().RightsFor(user, ticket.IsRehydrated)
+	if err != nil {
+		return "", "", 0, err
+	}
+	for _, r := range rights {
+		authority.Facts = append(authority.Facts, biscuit.Fact{Predicate: biscuit.Predicate{
+			Name: "right",
+			IDs:  []biscuit.Term{biscuit.String(r.Resource), biscuit.String(r.Operation)},
+		}})
+	}
+
+	keyID, root := uagc.BiscuitRootSigningKey()
+	token, err := biscuit_api.Mint(root, authority)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return biscuit_api.Encode(token), keyID, expiresAt.Unix(), nil
+}
@@ -0,0 +1,67 @@
+package biscuit_token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/biscuit-auth/biscuit-go/v2"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	bt "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/biscuit_token/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/biscuit/biscuit_api"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+const requestAuthorizer = `
+	resource({resource});
+	operation({operation});
+	time({now});
+	allow if tenant("dojo-a"), resource($r), operation($op), right($r, $op);
+`
+
+func authorize(t *testing.T, encoded, keyID, resource, operation string, now time.Time) error {
+	token, err := biscuit_api.Decode(encoded)
+	require.NoError(t, err)
+	root, err := uagc.BiscuitRootVerificationKey(keyID)
+	require.NoError(t, err)
+	return biscuit_api.Authorize(token, root, requestAuthorizer, biscuit_api.Params{
+		"resource":  biscuit.String(resource),
+		"operation": biscuit.String(operation),
+		"now":       biscuit.Date(now),
+	})
+}
+
+func TestMintAndAttenuate(t *testing.T) {
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupCoach, UserID: "sensei"}
+	ticket, err := auth_ticket.CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, nil)
+	require.NoError(t, err)
+
+	encoded, keyID, expiresAt, err := Mint(ticket, 0)
+	require.NoError(t, err)
+	require.InDelta(t, time.Now().Add(bt.BiscuitDefaultTTL).Unix(), expiresAt, 1)
+
+	now := time.Now()
+	require.NoError(t, authorize(t, encoded, keyID, policy.ResourceBeltRank, policy.OperationAssign, now))
+	require.Error(t, authorize(t, encoded, keyID, policy.ResourceOwnProfile, policy.OperationIssue, now))
+	require.Error(t, authorize(t, encoded, keyID, policy.ResourceBeltRank, policy.OperationRead, now.Add(time.Hour)))
+
+	// Client hands a read-only copy to a third party
+	token, err := biscuit_api.Decode(encoded)
+	require.NoError(t, err)
+	readOnly, err := biscuit_api.Attenuate(token, `check if operation("read");`, nil)
+	require.NoError(t, err)
+	require.NoError(t, authorize(t, biscuit_api.Encode(readOnly), keyID, policy.ResourceBeltRank, policy.OperationRead, now))
+	require.Error(t, authorize(t, biscuit_api.Encode(readOnly), keyID, policy.ResourceBeltRank, policy.OperationAssign, now))
+}
+
+func TestMintDoesNotParseIdentity(t *testing.T) {
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupChild, UserID: `kid"); right("belt_rank", "assign`}
+	ticket, err := auth_ticket.CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, nil)
+	require.NoError(t, err)
+
+	encoded, keyID, _, err := Mint(ticket, time.Minute)
+	require.NoError(t, err)
+	require.Error(t, authorize(t, encoded, keyID, policy.ResourceBeltRank, policy.OperationAssign, time.Now()))
+}
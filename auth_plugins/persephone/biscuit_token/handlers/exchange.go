@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/biscuit_token"
+	bt "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/biscuit_token/structs"
+)
+
+// HandleBiscuitExchange processes PSP_BISCUIT_EXCHANGE: a verified AuthTicket is traded for
+// a Biscuit that downstream services authorize offline against DEMETER's published root key.
+func HandleBiscuitExchange(
+	payload string,
+	traceID string,
+) (any, string, string, string) {
+	var req bt.ClientBiscuitExchangePayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid Biscuit exchange payload", err.Error()
+	}
+
+	if err := requireSessionTicket(&req.AuthTicket); err != nil {
+		return nil, "403", "AuthTicket rejected", err.Error()
+	}
+
+	token, keyID, expiresAt, err := biscuit_token.Mint(&req.AuthTicket, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		return nil, "500", "Failed to mint Biscuit", err.Error()
+	}
+
+	return bt.BiscuitExchangeSuccessResponse{
+		Version:                bt.BiscuitExchangeResponseVersion,
+		Success:                true,
+		Biscuit:                token,
+		RootKeyIdentifier:      keyID,
+		ExpiresAtUnixTimestamp: expiresAt,
+	}, "200", "Biscuit issued", ""
+}
+
+// requireSessionTicket accepts tickets that represent a signed-in user: fresh logins,
+// Remember-Me rehydrations and role switches. The token records which one it was.
+func requireSessionTicket(ticket *at.AuthTicket) error {
+	if err := auth_ticket.VerifyAuthTicket(ticket); err != nil {
+		return err
+	}
+	switch ticket.Purpose {
+	case at.AuthTicketPurposeLogin, at.AuthTicketPurposeUserRoleSwitch:
+		return nil
+	default:
+		return fmt.Errorf("tickets issued for %s cannot be exchanged", ticket.Purpose)
+	}
+}
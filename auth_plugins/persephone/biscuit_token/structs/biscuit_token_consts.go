@@ -0,0 +1,9 @@
+package structs
+
+import "time"
+
+const (
+	BiscuitExchangeResponseVersion = "v1"
+	BiscuitDefaultTTL              = 15 * time.Minute
+	BiscuitMaxTTL                  = time.Hour
+)
@@ -0,0 +1,18 @@
+package structs
+
+import (
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+)
+
+type ClientBiscuitExchangePayload struct {
+	AuthTicket at.AuthTicket `json:"auth_ticket"`
+	TTLSeconds int64         `json:"ttl_seconds,omitempty"` // 0 = BiscuitDefaultTTL, capped at BiscuitMaxTTL
+}
+
+type BiscuitExchangeSuccessResponse struct {
+	Version                string `json:"version"`
+	Success                bool   `json:"success"`
+	Biscuit                string `json:"biscuit"`             // URL-safe base64, as biscuit libraries expect
+	RootKeyIdentifier      string `json:"root_key_identifier"` // kid of the "biscuit" key on DEMETER's /keys
+	ExpiresAtUnixTimestamp int64  `json:"expires_at_unix_timestamp"`
+}
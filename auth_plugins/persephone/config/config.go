@@ -1,17 +1,116 @@
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/lockout"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_api"
+)
 
 type Config struct {
+	PoWScheme     string // pow_api.SchemeHashcash or pow_api.SchemeArgon2id
 	PoWSubject    string
-	PoWDifficulty int
+	PoWDifficulty int // Hashcash baseline (minimum) difficulty when things are quiet
 	PoWTTL        time.Duration
+	PoWMaxUses    int // How many times one solved PoW may be spent per subcommand
+
+	// Adaptive difficulty, see pow_control.DifficultyController
+	PoWMaxDifficulty  int // Hashcash ceiling, at most pow_api.MaxDifficulty(SchemeHashcash)
+	PoWHalfLife       time.Duration
+	PoWRequestsPerBit float64
+	PoWFailuresPerBit float64
+
+	// Argon2id scheme only. Each bit doubles the expected number of Argon2id runs, so
+	// these are far lower than the hashcash bounds above, which they replace.
+	PoWArgon2Difficulty    int
+	PoWArgon2MaxDifficulty int    // At most pow_api.MaxDifficulty(SchemeArgon2id)
+	PoWArgon2Memory        uint32 // KiB
+	PoWArgon2Iterations    uint32
+
+	// Failed-login throttling per CoreUser, see lockout.Controller. Failures across a
+	// tenant only raise the tenant's PoW difficulty: a tenant-wide wait would let anyone
+	// hold back every user of a dojo by guessing
+	LoginFreeAttempts  int
+	LoginBackoffBase   time.Duration
+	LoginBackoffMax    time.Duration
+	LoginLockAfter     int // Failures that lock the account for LoginLockDuration
+	LoginLockDuration  time.Duration
+	LoginFailureWindow time.Duration
 }
 
 func DefaultConfig() *Config {
 	return &Config{
+		PoWScheme:     pow_api.SchemeHashcash,
 		PoWSubject:    "OPAQUE_INIT",
 		PoWDifficulty: 10,
 		PoWTTL:        5 * time.Minute,
+		PoWMaxUses:    1,
+
+		PoWMaxDifficulty:  20,
+		PoWHalfLife:       5 * time.Minute,
+		PoWRequestsPerBit: 20,
+		PoWFailuresPerBit: 3,
+
+		PoWArgon2Difficulty:    2, // ~4 runs at 16 MiB, well under a second on a cheap phone
+		PoWArgon2MaxDifficulty: 8,
+		PoWArgon2Memory:        16 * 1024,
+		PoWArgon2Iterations:    1,
+
+		LoginFreeAttempts:  3,
+		LoginBackoffBase:   2 * time.Second,
+		LoginBackoffMax:    5 * time.Minute,
+		LoginLockAfter:     10,
+		LoginLockDuration:  30 * time.Minute,
+		LoginFailureWindow: time.Hour,
+	}
+}
+
+// PoWDifficultyBounds returns the baseline and ceiling difficulty for the configured scheme.
+func (c *Config) PoWDifficultyBounds() (int, int) {
+	if c.PoWScheme == pow_api.SchemeArgon2id {
+		return c.PoWArgon2Difficulty, c.PoWArgon2MaxDifficulty
+	}
+	return c.PoWDifficulty, c.PoWMaxDifficulty
+}
+
+// Validate rejects PoW settings the configured scheme cannot honour, rather than
+// letting them be clamped silently at startup.
+func (c *Config) Validate() error {
+	switch c.PoWScheme {
+	case pow_api.SchemeHashcash, pow_api.SchemeArgon2id:
+	default:
+		return fmt.Errorf("unknown PoW scheme %q", c.PoWScheme)
+	}
+	minBits, maxBits := c.PoWDifficultyBounds()
+	if limit := pow_api.MaxDifficulty(c.PoWScheme); minBits < 1 || minBits > maxBits || maxBits > limit {
+		return fmt.Errorf("%s PoW difficulty must satisfy 1 <= %d <= %d <= %d", c.PoWScheme, minBits, maxBits, limit)
+	}
+	return nil
+}
+
+// LockoutConfig assembles the lockout schedule.
+func (c *Config) LockoutConfig() lockout.Config {
+	return lockout.Config{
+		User: lockout.Schedule{
+			FreeAttempts: c.LoginFreeAttempts,
+			BaseDelay:    c.LoginBackoffBase,
+			MaxDelay:     c.LoginBackoffMax,
+			LockAfter:    c.LoginLockAfter,
+			LockDuration: c.LoginLockDuration,
+			Window:       c.LoginFailureWindow,
+		},
+	}
+}
+
+// PoWChallengeConfig assembles the challenge parameters for the configured scheme.
+func (c *Config) PoWChallengeConfig(subject string, difficulty int) pow_api.Config {
+	return pow_api.Config{
+		Scheme:           c.PoWScheme,
+		Subject:          subject,
+		Difficulty:       difficulty,
+		TTL:              c.PoWTTL,
+		Argon2Memory:     c.PoWArgon2Memory,
+		Argon2Iterations: c.PoWArgon2Iterations,
 	}
 }
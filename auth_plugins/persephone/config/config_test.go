@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePoWBounds(t *testing.T) {
+	c := DefaultConfig()
+	require.NoError(t, c.Validate())
+
+	c.PoWScheme = pow_api.SchemeArgon2id
+	require.NoError(t, c.Validate())
+	minBits, maxBits := c.PoWDifficultyBounds()
+	require.Equal(t, c.PoWArgon2Difficulty, minBits)
+	require.Equal(t, c.PoWArgon2MaxDifficulty, maxBits)
+
+	// Argon2id bounds are checked against the Argon2id limit, not clamped to it
+	c.PoWArgon2MaxDifficulty = pow_api.MaxDifficulty(pow_api.SchemeArgon2id) + 1
+	require.Error(t, c.Validate())
+
+	c = DefaultConfig()
+	c.PoWDifficulty = c.PoWMaxDifficulty + 1
+	require.Error(t, c.Validate())
+
+	c = DefaultConfig()
+	c.PoWScheme = "sha1"
+	require.Error(t, c.Validate())
+}
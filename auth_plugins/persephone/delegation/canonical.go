@@ -0,0 +1,40 @@
+package delegation
+
+import (
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+)
+
+// CanonicalBytes returns the bytes a DelegatedEntitlement signature covers: every field
+// but Signature, encoded per utils/canonical in this order:
+//
+//	version, entitlement_id, issuer.{tenant_id, user_group_id, user_id, sub_id},
+//	delegate.{tenant_id, user_group_id, user_id, sub_id}, scope count (int),
+//	scope[].{resource, operation}, reason, issued_at_unix_timestamp (int),
+//	not_before_unix_timestamp (int), not_after_unix_timestamp (int), nonce,
+//	signing_key_identifier
+func CanonicalBytes(d ds.DelegatedEntitlement) ([]byte, error) {
+	e := canonical.NewEncoder("DelegatedEntitlement").
+		String(d.Version).
+		String(d.EntitlementID).
+		String(d.Issuer.TenantID).
+		String(d.Issuer.UserGroupID).
+		String(d.Issuer.UserID).
+		String(d.Issuer.SubID).
+		String(d.Delegate.TenantID).
+		String(d.Delegate.UserGroupID).
+		String(d.Delegate.UserID).
+		String(d.Delegate.SubID).
+		Int64(int64(len(d.Scope)))
+	for _, s := range d.Scope {
+		e.String(s.Resource).String(s.Operation)
+	}
+	return e.
+		String(d.Reason).
+		Int64(d.IssuedAtUnixTimestamp).
+		Int64(d.NotBeforeUnixTimestamp).
+		Int64(d.NotAfterUnixTimestamp).
+		String(d.Nonce).
+		String(d.SigningKeyIdentifier).
+		Bytes()
+}
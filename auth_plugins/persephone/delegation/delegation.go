@@ -0,0 +1,239 @@
+// Package delegation lets a user lend some of their policy rights to another user of the
+// same tenant for a bounded window (Hestia scenario [e]). Entitlements are signed,
+// stored, and consulted by the policy engine at authorization time, so they lapse on
+// their own; the issuer may also revoke them early.
+package delegation
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/delegation_store"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+var (
+	ErrDelegationDenied = errors.New("delegation denied")
+	ErrNotIssuer        = errors.New("only the issuer may revoke a delegated entitlement")
+)
+
+// Service issues, revokes and looks up delegated entitlements. It implements
+// policy.DelegationSource.
+type Service struct {
+	store delegation_store.DelegationStore
+}
+
+func NewService(store delegation_store.DelegationStore) *Service {
+	return &Service{store: store}
+}
+
+// Issue lends req.Scope to req.Delegate. The issuer must hold every right in scope under
+// their own tenant policy; delegated rights cannot be passed on.
+func (s *Service) Issue(issuer uagc.UniqueUser, req ds.ClientDelegationIssuePayload) (*ds.DelegatedEntitlement, error) {
+	now := time.Now()
+	notBefore, notAfter, err := checkWindow(now, req.NotBeforeUnixTimestamp, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDelegation(issuer, req.Delegate, req.Scope); err != nil {
+		return nil, err
+	}
+
+	id, err := randomString(ds.DelegatedEntitlementIDSize)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString(ds.DelegatedEntitlementNonceSize)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, priv := uagc.Ed448DelegationSigningKey()
+	d := ds.DelegatedEntitlement{
+		Version:                ds.DelegatedEntitlementVersion,
+		EntitlementID:          id,
+		Issuer:                 issuer,
+		Delegate:               req.Delegate,
+		Scope:                  req.Scope,
+		Reason:                 req.Reason,
+		IssuedAtUnixTimestamp:  now.Unix(),
+		NotBeforeUnixTimestamp: notBefore,
+		NotAfterUnixTimestamp:  notAfter,
+		Nonce:                  nonce,
+		SigningKeyIdentifier:   keyID,
+	}
+	toSign, err := CanonicalBytes(d)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ed448_api.SignWithContext(priv, toSign, sigctx.DelegatedEntitlement)
+	if err != nil {
+		return nil, err
+	}
+	d.Signature = base64.RawURLEncoding.EncodeToString(sig)
+
+	encoded, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Put(delegation_store.StoredDelegation{
+		EntitlementID:         d.EntitlementID,
+		IssuerKey:             delegation_store.UserKey(issuer),
+		DelegateKey:           delegation_store.UserKey(d.Delegate),
+		NotAfterUnixTimestamp: d.NotAfterUnixTimestamp,
+		Encoded:               encoded,
+	}); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Revoke ends an entitlement before its window closes. Only its issuer may do so.
+func (s *Service) Revoke(issuer uagc.UniqueUser, entitlementID string) error {
+	rec, err := s.store.Get(entitlementID)
+	if err != nil {
+		return err
+	}
+	d, err := decodeStored(*rec)
+	if err != nil {
+		return err
+	}
+	if d.Issuer != issuer {
+		return ErrNotIssuer
+	}
+	return s.store.Revoke(entitlementID, time.Now().Unix())
+}
+
+// ActiveDelegations implements policy.DelegationSource. Records that fail verification
+// are skipped, so a tampered store can only withhold rights, never add them.
+func (s *Service) ActiveDelegations(delegate uagc.UniqueUser, now time.Time) ([]policy.DelegatedRights, error) {
+	recs, err := s.store.ListForDelegate(delegation_store.UserKey(delegate), now.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	var out []policy.DelegatedRights
+	for _, rec := range recs {
+		d, err := decodeStored(rec)
+		if err != nil {
+			log.Printf("⚠️ Ignoring delegated entitlement %s: %v", rec.EntitlementID, err)
+			continue
+		}
+		if d.Delegate != delegate || !InForce(d, now) {
+			continue
+		}
+		rights := make([]policy.Right, len(d.Scope))
+		for i, sc := range d.Scope {
+			rights[i] = policy.Right{Resource: sc.Resource, Operation: sc.Operation}
+		}
+		out = append(out, policy.DelegatedRights{Issuer: d.Issuer, Rights: rights})
+	}
+	return out, nil
+}
+
+// InForce reports whether now falls inside the entitlement's window.
+func InForce(d *ds.DelegatedEntitlement, now time.Time) bool {
+	t := now.Unix()
+	return t >= d.NotBeforeUnixTimestamp && t < d.NotAfterUnixTimestamp
+}
+
+// VerifyDelegatedEntitlement checks the signature only; the window is checked by InForce.
+func VerifyDelegatedEntitlement(d *ds.DelegatedEntitlement) error {
+	pub, err := uagc.Ed448DelegationVerificationKey(d.SigningKeyIdentifier, d.IssuedAtUnixTimestamp)
+	if err != nil {
+		return err
+	}
+	msg, err := CanonicalBytes(*d)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(d.Signature)
+	if err != nil {
+		return err
+	}
+	if !ed448_api.VerifyWithContext(pub, msg, sig, sigctx.DelegatedEntitlement) {
+		return errors.New("invalid signature on DelegatedEntitlement")
+	}
+	return nil
+}
+
+func decodeStored(rec delegation_store.StoredDelegation) (*ds.DelegatedEntitlement, error) {
+	var d ds.DelegatedEntitlement
+	if err := json.Unmarshal(rec.Encoded, &d); err != nil {
+		return nil, err
+	}
+	if d.EntitlementID != rec.EntitlementID {
+		return nil, errors.New("stored delegated entitlement does not match its ID")
+	}
+	if err := VerifyDelegatedEntitlement(&d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func checkDelegation(issuer, delegate uagc.UniqueUser, scope []ds.EntitlementScope) error {
+	switch {
+	case delegate.UserID == "":
+		return fmt.Errorf("%w: missing delegate", ErrDelegationDenied)
+	case delegate.TenantID != issuer.TenantID:
+		return fmt.Errorf("%w: cannot delegate outside own tenant", ErrDelegationDenied)
+	case delegate == issuer:
+		return fmt.Errorf("%w: cannot delegate to oneself", ErrDelegationDenied)
+	case len(scope) == 0:
+		return fmt.Errorf("%w: empty scope", ErrDelegationDenied)
+	}
+
+	held, err := policy.Active().RightsFor(issuer, false)
+	if err != nil {
+		return err
+	}
+	for _, sc := range scope {
+		if !holds(held, sc) {
+			return fmt.Errorf("%w: issuer does not hold %s/%s", ErrDelegationDenied, sc.Resource, sc.Operation)
+		}
+	}
+	return nil
+}
+
+// checkWindow resolves the requested window to [notBefore, notAfter) in unix seconds.
+func checkWindow(now time.Time, notBefore int64, ttl time.Duration) (int64, int64, error) {
+	if notBefore == 0 || notBefore < now.Unix() {
+		notBefore = now.Unix()
+	}
+	if notBefore > now.Add(ds.DelegationMaxLead).Unix() {
+		return 0, 0, fmt.Errorf("%w: window starts too far ahead", ErrDelegationDenied)
+	}
+	if ttl <= 0 {
+		ttl = ds.DelegationDefaultTTL
+	}
+	if ttl > ds.DelegationMaxTTL {
+		ttl = ds.DelegationMaxTTL
+	}
+	return notBefore, notBefore + int64(ttl.Seconds()), nil
+}
+
+func holds(rights []policy.Right, sc ds.EntitlementScope) bool {
+	for _, r := range rights {
+		if r.Resource == sc.Resource && r.Operation == sc.Operation {
+			return true
+		}
+	}
+	return false
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,95 @@
+package delegation
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/delegation_store"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	dima   = uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupCoach, UserID: "dima"}
+	amanda = uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupStaff, UserID: "amanda"}
+)
+
+var beltRank = []ds.EntitlementScope{{Resource: policy.ResourceBeltRank, Operation: policy.OperationAssign}}
+
+func TestExamDayDelegation(t *testing.T) {
+	store := delegation_store.NewMemoryAdapter()
+	svc := NewService(store)
+	engine := policy.Active().WithDelegations(svc)
+
+	require.ErrorIs(t, engine.AuthorizeUser(amanda, false, policy.ResourceBeltRank, policy.OperationAssign), policy.ErrDenied)
+
+	d, err := svc.Issue(dima, ds.ClientDelegationIssuePayload{Delegate: amanda, Scope: beltRank, Reason: "Belt exam"})
+	require.NoError(t, err)
+	require.Equal(t, int64(ds.DelegationDefaultTTL.Seconds()), d.NotAfterUnixTimestamp-d.NotBeforeUnixTimestamp)
+
+	require.NoError(t, engine.AuthorizeUser(amanda, false, policy.ResourceBeltRank, policy.OperationAssign))
+	require.ErrorIs(t, engine.AuthorizeUser(amanda, false, policy.ResourceBeltRank, policy.OperationRead), policy.ErrDenied)
+	// Biscuits never carry delegated rights
+	rights, err := engine.RightsFor(amanda, false)
+	require.NoError(t, err)
+	require.NotContains(t, rights, policy.Right{Resource: policy.ResourceBeltRank, Operation: policy.OperationAssign})
+
+	// Expires by itself
+	later, err := svc.ActiveDelegations(amanda, time.Now().Add(ds.DelegationDefaultTTL+time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, later)
+
+	// Only Dima may end it early
+	require.ErrorIs(t, svc.Revoke(amanda, d.EntitlementID), ErrNotIssuer)
+	require.NoError(t, svc.Revoke(dima, d.EntitlementID))
+	require.ErrorIs(t, engine.AuthorizeUser(amanda, false, policy.ResourceBeltRank, policy.OperationAssign), policy.ErrDenied)
+}
+
+func TestIssueRules(t *testing.T) {
+	svc := NewService(delegation_store.NewMemoryAdapter())
+
+	// Amanda cannot lend what she does not hold
+	_, err := svc.Issue(amanda, ds.ClientDelegationIssuePayload{Delegate: dima, Scope: beltRank})
+	require.ErrorIs(t, err, ErrDelegationDenied)
+
+	other := amanda
+	other.TenantID = "dojo-b"
+	_, err = svc.Issue(dima, ds.ClientDelegationIssuePayload{Delegate: other, Scope: beltRank})
+	require.ErrorIs(t, err, ErrDelegationDenied)
+
+	_, err = svc.Issue(dima, ds.ClientDelegationIssuePayload{Delegate: amanda})
+	require.ErrorIs(t, err, ErrDelegationDenied)
+
+	d, err := svc.Issue(dima, ds.ClientDelegationIssuePayload{Delegate: amanda, Scope: beltRank, TTLSeconds: int64((30 * 24 * time.Hour).Seconds())})
+	require.NoError(t, err)
+	require.Equal(t, int64(ds.DelegationMaxTTL.Seconds()), d.NotAfterUnixTimestamp-d.NotBeforeUnixTimestamp)
+
+	// Not in force before its window opens
+	future, err := svc.Issue(dima, ds.ClientDelegationIssuePayload{Delegate: amanda, Scope: beltRank, NotBeforeUnixTimestamp: time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+	require.False(t, InForce(future, time.Now()))
+}
+
+func TestTamperedRecordIsIgnored(t *testing.T) {
+	store := delegation_store.NewMemoryAdapter()
+	svc := NewService(store)
+	d, err := svc.Issue(dima, ds.ClientDelegationIssuePayload{Delegate: amanda, Scope: beltRank})
+	require.NoError(t, err)
+
+	rec, err := store.Get(d.EntitlementID)
+	require.NoError(t, err)
+	d.Scope = append(d.Scope, ds.EntitlementScope{Resource: policy.ResourceAuthGrant, Operation: policy.OperationIssue})
+	forged, err := json.Marshal(d)
+	require.NoError(t, err)
+	rec.Encoded = forged
+
+	// Someone with write access to the table swaps the record
+	tampered := delegation_store.NewMemoryAdapter()
+	require.NoError(t, tampered.Put(*rec))
+	active, err := NewService(tampered).ActiveDelegations(amanda, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, active)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation"
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation/structs"
+)
+
+// HandleDelegationIssue processes PSP_DELEGATION_ISSUE: an authenticated user lends some of
+// their own rights to another user of their tenant for a bounded window.
+func HandleDelegationIssue(
+	payload string,
+	traceID string,
+	svc *delegation.Service,
+) (any, string, string, string) {
+	var req ds.ClientDelegationIssuePayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid delegation issue payload", err.Error()
+	}
+
+	issuer, err := auth_ticket.RequireFreshLogin(&req.AuthTicket)
+	if err != nil {
+		return nil, "403", "AuthTicket rejected", err.Error()
+	}
+
+	ev := audit_log.Event{
+		Actor:   issuer,
+		Target:  req.Delegate,
+		Command: audit_log.EventDelegationIssue,
+		TraceID: traceID,
+	}
+
+	d, err := svc.Issue(issuer, req)
+	if errors.Is(err, delegation.ErrDelegationDenied) {
+		return audit_log.Reply(ev, nil, "403", "Delegation denied", err.Error())
+	}
+	if err != nil {
+		return audit_log.Reply(ev, nil, "500", "Delegation failed", err.Error())
+	}
+
+	ev.Detail = fmt.Sprintf("entitlement %s", d.EntitlementID)
+	return audit_log.Reply(ev, ds.DelegationIssueSuccessResponse{
+		Version:     ds.DelegationIssueResponseVersion,
+		Success:     true,
+		Entitlement: *d,
+	}, "200", "Entitlement delegated", "")
+}
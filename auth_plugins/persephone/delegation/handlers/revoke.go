@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation"
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/delegation_store"
+)
+
+// HandleDelegationRevoke processes PSP_DELEGATION_REVOKE: the issuer ends an entitlement
+// before its window closes.
+func HandleDelegationRevoke(
+	payload string,
+	traceID string,
+	svc *delegation.Service,
+) (any, string, string, string) {
+	var req ds.ClientDelegationRevokePayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid delegation revoke payload", err.Error()
+	}
+
+	issuer, err := auth_ticket.RequireFreshLogin(&req.AuthTicket)
+	if err != nil {
+		return nil, "403", "AuthTicket rejected", err.Error()
+	}
+
+	ev := audit_log.Event{
+		Actor:   issuer,
+		Command: audit_log.EventDelegationRevoke,
+		TraceID: traceID,
+		Detail:  fmt.Sprintf("entitlement %s", req.EntitlementID),
+	}
+
+	err = svc.Revoke(issuer, req.EntitlementID)
+	switch {
+	case errors.Is(err, delegation_store.ErrDelegationNotFound):
+		return nil, "400", "Unknown delegated entitlement", err.Error()
+	case errors.Is(err, delegation.ErrNotIssuer):
+		return audit_log.Reply(ev, nil, "403", "Delegation revocation denied", err.Error())
+	case err != nil:
+		return audit_log.Reply(ev, nil, "500", "Delegation revocation failed", err.Error())
+	}
+
+	return audit_log.Reply(ev, ds.DelegationRevokeSuccessResponse{
+		Version:       ds.DelegationRevokeResponseVersion,
+		Success:       true,
+		EntitlementID: req.EntitlementID,
+	}, "200", "Delegated entitlement revoked", "")
+}
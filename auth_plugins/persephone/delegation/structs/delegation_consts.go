@@ -0,0 +1,17 @@
+package structs
+
+import "time"
+
+const (
+	DelegatedEntitlementVersion     = "v1"
+	DelegatedEntitlementIDSize      = 16
+	DelegatedEntitlementNonceSize   = 32
+	DelegationIssueResponseVersion  = "v1"
+	DelegationRevokeResponseVersion = "v1"
+)
+
+const (
+	DelegationDefaultTTL = 6 * time.Hour  // Hestia scenario [e]: exam-day access
+	DelegationMaxTTL     = 72 * time.Hour // Longer needs belong in the tenant policy
+	DelegationMaxLead    = 7 * 24 * time.Hour
+)
@@ -0,0 +1,56 @@
+package structs
+
+import (
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// EntitlementScope is one policy right (see <ProjectRoot>\policy) being lent.
+type EntitlementScope struct {
+	Resource  string `json:"resource"`
+	Operation string `json:"operation"`
+}
+
+// DelegatedEntitlement lends Delegate some of Issuer's rights for
+// [NotBeforeUnixTimestamp, NotAfterUnixTimestamp). Signed by the auth server on the
+// issuer's behalf, with its own delegation key.
+type DelegatedEntitlement struct {
+	Version                string             `json:"version"`
+	EntitlementID          string             `json:"entitlement_id"`
+	Issuer                 uagc.UniqueUser    `json:"issuer"`
+	Delegate               uagc.UniqueUser    `json:"delegate"`
+	Scope                  []EntitlementScope `json:"scope"`
+	Reason                 string             `json:"reason,omitempty"` // Shown to the delegate and in audits
+	IssuedAtUnixTimestamp  int64              `json:"issued_at_unix_timestamp"`
+	NotBeforeUnixTimestamp int64              `json:"not_before_unix_timestamp"`
+	NotAfterUnixTimestamp  int64              `json:"not_after_unix_timestamp"`
+	Nonce                  string             `json:"nonce"`
+	SigningKeyIdentifier   string             `json:"signing_key_identifier"`
+	Signature              string             `json:"signature"`
+}
+
+type ClientDelegationIssuePayload struct {
+	AuthTicket             at.AuthTicket      `json:"auth_ticket"` // Issuer's fresh login ticket
+	Delegate               uagc.UniqueUser    `json:"delegate"`    // Must be in the issuer's tenant
+	Scope                  []EntitlementScope `json:"scope"`
+	Reason                 string             `json:"reason,omitempty"`
+	NotBeforeUnixTimestamp int64              `json:"not_before_unix_timestamp,omitempty"` // 0 = now
+	TTLSeconds             int64              `json:"ttl_seconds,omitempty"`               // 0 = DelegationDefaultTTL
+}
+
+type DelegationIssueSuccessResponse struct {
+	Version     string               `json:"version"`
+	Success     bool                 `json:"success"`
+	Entitlement DelegatedEntitlement `json:"entitlement"`
+}
+
+type ClientDelegationRevokePayload struct {
+	AuthTicket    at.AuthTicket `json:"auth_ticket"`
+	EntitlementID string        `json:"entitlement_id"`
+}
+
+type DelegationRevokeSuccessResponse struct {
+	Version       string `json:"version"`
+	Success       bool   `json:"success"`
+	EntitlementID string `json:"entitlement_id"`
+}
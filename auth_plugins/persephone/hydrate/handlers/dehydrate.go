@@ -1 +1,63 @@
 package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/secure_state"
+	hd "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/structs"
+)
+
+// HandleDehydrate turns a fresh login AuthTicket into a device-bound HydrateStateEnvelope
+// the client can keep offline and later exchange for a new ticket without re-running OPAQUE.
+func HandleDehydrate(req hd.HydrateClientReply) (any, string, string, string) {
+	var clientPayload hd.ClientDehydratePayload
+	if err := json.Unmarshal([]byte(req.ClientPayload), &clientPayload); err != nil {
+		return nil, "400", "Invalid dehydrate payload", err.Error()
+	}
+
+	env, err := dehydrate(clientPayload)
+	if err != nil {
+		return nil, "403", "Dehydrate failed", err.Error()
+	}
+
+	resp := hd.DehydrateSuccessResponse{
+		Version:              hd.DehydrateResponseVersion,
+		Success:              true,
+		HydrateStateEnvelope: env,
+	}
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return nil, "500", "Failed to encode dehydrate response", err.Error()
+	}
+
+	return hd.HydrateServerReply{
+		CommandType:   hd.HydrateCmdDehydrate,
+		ServerPayload: string(respBytes),
+	}, "200", "Dehydrate successful", ""
+}
+
+func dehydrate(p hd.ClientDehydratePayload) (hd.HydrateStateEnvelope, error) {
+	if p.DeviceIdentifier == "" {
+		return hd.HydrateStateEnvelope{}, fmt.Errorf("missing device identifier")
+	}
+
+	ticket := p.AuthTicket
+	if err := auth_ticket.VerifyAuthTicket(&ticket); err != nil {
+		return hd.HydrateStateEnvelope{}, fmt.Errorf("auth ticket rejected: %w", err)
+	}
+
+	// Only tickets minted by a real OPAQUE login may seed a Hydrate state,
+	// otherwise a rehydrated ticket could be used to extend itself forever.
+	if ticket.Purpose != at.AuthTicketPurposeLogin || ticket.IsRehydrated {
+		return hd.HydrateStateEnvelope{}, fmt.Errorf("only fresh login tickets can be dehydrated")
+	}
+
+	env, err := ss.CreateHydrateStateEnvelope(ticket, p.DeviceIdentifier)
+	if err != nil {
+		return hd.HydrateStateEnvelope{}, fmt.Errorf("failed to seal hydrate state: %w", err)
+	}
+	return env, nil
+}
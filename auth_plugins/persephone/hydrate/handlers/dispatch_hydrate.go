@@ -1 +1,96 @@
 package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	config "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/config"
+	hd "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/pow_control"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_api"
+	"github.com/drlzh/mng-app-user-auth-prot/pow_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+func DispatchHydrate(
+	payload string,
+	traceID string,
+	remoteAddr string,
+	svc *opaque_api.DefaultOpaqueService,
+	seen pow_store.SeenNonceStore,
+	pow *pow_control.DifficultyController,
+	conf *config.Config,
+) (any, string, string, string) {
+	var msg hd.HydrateClientReply
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return nil, "400", "Invalid Hydrate message", err.Error()
+	}
+
+	keys := hydratePoWKeys(msg, remoteAddr)
+	if err := pow.RequireDifficulty(conf.PoWScheme, msg.PoWSolution, keys...); err != nil {
+		return nil, "403", "PoW verification failed", err.Error()
+	}
+
+	// PoW check — issued by HandleHydrateInit for this trace
+	subject := pow_api.BindSubject(conf.PoWSubject, traceID, hd.HydratePoWIntent)
+	if err := pow_api.VerifyAndSpendToken(conf.PoWScheme, msg.PoWSolution, subject, msg.CommandType, seen, conf.PoWMaxUses); err != nil {
+		return nil, "403", "PoW verification failed", err.Error()
+	}
+
+	switch msg.CommandType {
+	case hd.HydrateCmdDehydrate:
+		return HandleDehydrate(msg)
+
+	case hd.HydrateCmdRehydrate:
+		resp, status, info, extended := HandleRehydrate(svc, msg)
+		if status != "200" {
+			pow.RecordFailure(keys...)
+		}
+		return resp, status, info, extended
+
+	case hd.HydrateCmdUserRoleSwitch:
+		resp, status, info, extended := HandleUserRoleSwitch(svc, msg)
+		if status != "200" {
+			pow.RecordFailure(keys...)
+		}
+		auditUserRoleSwitch(msg, traceID, status, info)
+		return resp, status, info, extended
+
+	default:
+		return nil, "400", "Unknown Hydrate subcommand", msg.CommandType
+	}
+}
+
+// hydratePoWKeys picks the difficulty keys for a Hydrate request. Only a role switch
+// names its user before the envelope is opened; other requests are keyed by IP alone.
+func hydratePoWKeys(msg hd.HydrateClientReply, remoteAddr string) []string {
+	keys := []string{pow_control.IPKey(remoteAddr)}
+	if msg.CommandType == hd.HydrateCmdUserRoleSwitch {
+		var p hd.ClientUserRoleSwitchPayload
+		if err := json.Unmarshal([]byte(msg.ClientPayload), &p); err == nil && p.SwitchFrom.UserID != "" {
+			user := uagc.CoreUser{TenantID: p.SwitchFrom.TenantID, UserID: p.SwitchFrom.UserID}
+			keys = append(keys, pow_control.TenantKey(user.TenantID), pow_control.UserKey(user))
+		}
+	}
+	return keys
+}
+
+// auditUserRoleSwitch records role switches. SwitchFrom is only trusted once the switch
+// succeeded (it was checked against the Hydrate state), so failures carry no actor.
+func auditUserRoleSwitch(msg hd.HydrateClientReply, traceID, status, info string) {
+	var p hd.ClientUserRoleSwitchPayload
+	_ = json.Unmarshal([]byte(msg.ClientPayload), &p)
+
+	ev := audit_log.Event{
+		Target:  p.SwitchTo,
+		Command: audit_log.EventUserRoleSwitch,
+		TraceID: traceID,
+		Outcome: audit_log.OutcomeForStatus(status),
+		Detail:  info,
+	}
+	if ev.Outcome == audit_log.OutcomeSuccess {
+		ev.Actor = p.SwitchFrom
+	}
+	audit_log.Record(ev)
+}
@@ -4,12 +4,13 @@ import (
 	"encoding/json"
 	config "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/config"
 	hd "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/structs"
-	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_api"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/pow_control"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_api"
 	"time"
 )
 
 // HandleHydrateInit processes PSP_CMD_HYDRATE_INITIATE_HYDRATE using raw JSON payload + trace
-func HandleHydrateInit(payload string, traceID string, conf *config.Config) (any, string, string, string) {
+func HandleHydrateInit(payload string, traceID string, remoteAddr string, pow *pow_control.DifficultyController, conf *config.Config) (any, string, string, string) {
 	var init hd.HydrateInit
 	if err := json.Unmarshal([]byte(payload), &init); err != nil {
 		return nil, "400", "Invalid HydrateInit JSON", err.Error()
@@ -17,29 +18,32 @@ func HandleHydrateInit(payload string, traceID string, conf *config.Config) (any
 
 	switch init.InitStep {
 	case hd.HydrateCmdInitiateStepOne:
-		return handleInitStepOne(init.InitPayload, conf)
+		return handleInitStepOne(init.InitPayload, traceID, remoteAddr, pow, conf)
 
 	case hd.HydrateCmdInitiateStepThree:
-		return handleInitStepThree(init.InitPayload, conf)
+		return handleInitStepThree(init.InitPayload, traceID, conf)
 
 	default:
 		return nil, "400", "Unknown init_step", init.InitStep
 	}
 }
 
-func handleInitStepOne(payload string, conf *config.Config) (any, string, string, string) {
+func handleInitStepOne(payload string, traceID string, remoteAddr string, pow *pow_control.DifficultyController, conf *config.Config) (any, string, string, string) {
 	var step1 hd.ClientHydrateInitStepOnePayload
 	if err := json.Unmarshal([]byte(payload), &step1); err != nil {
 		return nil, "400", "Invalid StepOne payload", err.Error()
 	}
 
-	cfg := hashcash_api.Config{
-		Subject:    conf.PoWSubject,
-		Difficulty: conf.PoWDifficulty,
-		TTL:        conf.PoWTTL,
+	// User hint is optional; DispatchOpaque and DispatchHydrate re-check difficulty when the token is spent
+	keys := []string{
+		pow_control.IPKey(remoteAddr),
+		pow_control.TenantKey(step1.User.TenantID),
+		pow_control.UserKey(step1.User),
 	}
+	pow.RecordRequest(keys...)
 
-	chal, err := hashcash_api.CreateChallenge(cfg)
+	subject := pow_api.BindSubject(conf.PoWSubject, traceID, hd.HydratePoWIntent)
+	chal, err := pow_api.CreateChallenge(conf.PoWChallengeConfig(subject, pow.Difficulty(keys...)))
 	if err != nil {
 		return nil, "500", "PoW challenge creation failed", err.Error()
 	}
@@ -47,17 +51,19 @@ func handleInitStepOne(payload string, conf *config.Config) (any, string, string
 	resp := hd.ServerHydrateInitStepTwoPayload{
 		UnixTimestamp: time.Now().Unix(),
 		PoWChallenge:  chal.Token,
+		PoWScheme:     chal.Scheme,
 	}
 	return resp, "200", "PoW challenge issued", ""
 }
 
-func handleInitStepThree(payload string, conf *config.Config) (any, string, string, string) {
+func handleInitStepThree(payload string, traceID string, conf *config.Config) (any, string, string, string) {
 	var step3 hd.ClientHydrateInitStepThreePayload
 	if err := json.Unmarshal([]byte(payload), &step3); err != nil {
 		return nil, "400", "Invalid StepThree payload", err.Error()
 	}
 
-	if err := hashcash_api.VerifyToken(step3.PoWSolution, conf.PoWSubject); err != nil {
+	subject := pow_api.BindSubject(conf.PoWSubject, traceID, hd.HydratePoWIntent)
+	if err := pow_api.VerifyToken(conf.PoWScheme, step3.PoWSolution, subject); err != nil {
 		return hd.ServerHydrateInitStepFourPayload{
 			UnixTimestamp: time.Now().Unix(),
 			Success:       false,
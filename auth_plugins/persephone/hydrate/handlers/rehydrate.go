@@ -1 +1,111 @@
 package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/secure_state"
+	hd "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// HandleRehydrate opens a HydrateStateEnvelope and issues a new AuthTicket
+// for the same UniqueUser with IsRehydrated = true.
+func HandleRehydrate(
+	svc *opaque_api.DefaultOpaqueService,
+	req hd.HydrateClientReply,
+) (any, string, string, string) {
+	var clientPayload hd.ClientRehydratePayload
+	if err := json.Unmarshal([]byte(req.ClientPayload), &clientPayload); err != nil {
+		return nil, "400", "Invalid rehydrate payload", err.Error()
+	}
+
+	ticket, err := rehydrate(svc, clientPayload)
+	if err != nil {
+		return nil, "403", "Rehydrate failed", err.Error()
+	}
+
+	resp := hd.RehydrateSuccessResponse{
+		Version:    hd.RehydrateResponseVersion,
+		Success:    true,
+		AuthTicket: *ticket,
+	}
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return nil, "500", "Failed to encode rehydrate response", err.Error()
+	}
+
+	return hd.HydrateServerReply{
+		CommandType:   hd.HydrateCmdRehydrate,
+		ServerPayload: string(respBytes),
+	}, "200", "Rehydrate successful", ""
+}
+
+func rehydrate(
+	svc *opaque_api.DefaultOpaqueService,
+	p hd.ClientRehydratePayload,
+) (*at.AuthTicket, error) {
+	payload, err := openHydrateState(p.HydrateStateEnvelope, p.DeviceIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	assoc := payload.AssociatedTicket
+
+	// The user (or just this role) may have been removed since the state was sealed
+	user := assoc.AuthenticatedUser
+	if err := requireUserGroupBinding(svc, user); err != nil {
+		return nil, err
+	}
+
+	return auth_ticket.CreateAuthTicket(
+		user,
+		at.AuthTicketPurposeLogin,
+		assoc.Scope,
+		true,
+		assoc.Payload, // Same session
+	)
+}
+
+// openHydrateState decrypts and fully validates a HydrateStateEnvelope presented by deviceID.
+func openHydrateState(env hd.HydrateStateEnvelope, deviceID string) (*at.RehydratedTicketPayload, error) {
+	payload, err := ss.VerifyAndDecryptHydrateEnvelope(env, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("hydrate envelope rejected: %w", err)
+	}
+	if payload.HydrateVersion != at.RehydratedTicketVersion {
+		return nil, fmt.Errorf("unsupported hydrate version: %s", payload.HydrateVersion)
+	}
+
+	assoc := payload.AssociatedTicket
+	if err := auth_ticket.VerifyAuthTicketSignature(&assoc); err != nil {
+		return nil, fmt.Errorf("associated ticket rejected: %w", err)
+	}
+	if time.Now().Unix()-assoc.IssuedAtUnixTimestamp > int64(at.RehydratedTicketTTL.Seconds()) {
+		return nil, fmt.Errorf("hydrate state expired")
+	}
+	// Signing out the session also retires every Hydrate state sealed from it
+	if err := session.Active().Check(auth_ticket.SessionID(&assoc)); err != nil {
+		return nil, fmt.Errorf("hydrate state rejected: %w", err)
+	}
+
+	return payload, nil
+}
+
+func requireUserGroupBinding(svc *opaque_api.DefaultOpaqueService, user uagc.UniqueUser) error {
+	core := uagc.CoreUser{TenantID: user.TenantID, UserID: user.UserID}
+	bindings, err := svc.Store().GetUserGroupsForUser(core)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user group bindings: %w", err)
+	}
+	for _, b := range bindings {
+		if b.UserGroupID == user.UserGroupID {
+			return nil
+		}
+	}
+	return fmt.Errorf("user group %s is not bound to user", user.UserGroupID)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	hd "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+)
+
+// HandleUserRoleSwitch lets a user holding a valid Hydrate state sign in as another
+// UserGroup bound to the same CoreUser (e.g. Coach -> Parent) without re-running OPAQUE.
+func HandleUserRoleSwitch(
+	svc *opaque_api.DefaultOpaqueService,
+	req hd.HydrateClientReply,
+) (any, string, string, string) {
+	var clientPayload hd.ClientUserRoleSwitchPayload
+	if err := json.Unmarshal([]byte(req.ClientPayload), &clientPayload); err != nil {
+		return nil, "400", "Invalid user role switch payload", err.Error()
+	}
+
+	payload, err := openHydrateState(clientPayload.HydrateStateEnvelope, clientPayload.DeviceIdentifier)
+	if err != nil {
+		return nil, "403", "User role switch failed", err.Error()
+	}
+
+	ticket, err := switchUserRole(svc, at.UserRoleSwitchPayload{
+		RehydratedTicketPayload: *payload,
+		SwitchFrom:              clientPayload.SwitchFrom,
+		SwitchTo:                clientPayload.SwitchTo,
+	})
+	if err != nil {
+		return nil, "403", "User role switch failed", err.Error()
+	}
+
+	resp := hd.UserRoleSwitchSuccessResponse{
+		Version:    hd.UserRoleSwitchResponseVersion,
+		Success:    true,
+		AuthTicket: *ticket,
+	}
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return nil, "500", "Failed to encode user role switch response", err.Error()
+	}
+
+	return hd.HydrateServerReply{
+		CommandType:   hd.HydrateCmdUserRoleSwitch,
+		ServerPayload: string(respBytes),
+	}, "200", "User role switch successful", ""
+}
+
+// switchUserRole expects sw.RehydratedTicketPayload to be already verified (see openHydrateState).
+func switchUserRole(
+	svc *opaque_api.DefaultOpaqueService,
+	sw at.UserRoleSwitchPayload,
+) (*at.AuthTicket, error) {
+	from := sw.RehydratedTicketPayload.AssociatedTicket.AuthenticatedUser
+	if sw.SwitchFrom != from {
+		return nil, fmt.Errorf("switch_from does not match hydrate state")
+	}
+	if sw.SwitchTo.TenantID != from.TenantID || sw.SwitchTo.UserID != from.UserID {
+		return nil, fmt.Errorf("cannot switch to a role of another user")
+	}
+	if sw.SwitchTo.UserGroupID == from.UserGroupID {
+		return nil, fmt.Errorf("already signed in as %s", from.UserGroupID)
+	}
+
+	// The role being left must still be held: bound to the user and signed in by the
+	// session the Hydrate state was sealed from
+	assoc := sw.RehydratedTicketPayload.AssociatedTicket
+	if err := session.Active().CheckUserGroup(auth_ticket.SessionID(&assoc), sw.SwitchFrom); err != nil {
+		return nil, fmt.Errorf("switch_from rejected: %w", err)
+	}
+	if err := requireUserGroupBinding(svc, sw.SwitchFrom); err != nil {
+		return nil, fmt.Errorf("switch_from rejected: %w", err)
+	}
+	if err := requireUserGroupBinding(svc, sw.SwitchTo); err != nil {
+		return nil, err
+	}
+
+	return auth_ticket.CreateAuthTicket(
+		sw.SwitchTo,
+		at.AuthTicketPurposeUserRoleSwitch,
+		assoc.Scope,
+		true,
+		assoc.Payload, // Same session
+	)
+}
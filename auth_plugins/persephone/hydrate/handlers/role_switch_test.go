@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/secure_state"
+	hd "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	"github.com/drlzh/mng-app-user-auth-prot/opaque_store"
+	"github.com/drlzh/mng-app-user-auth-prot/session_store"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+	"github.com/stretchr/testify/require"
+)
+
+const testDevice = "device-1"
+
+// bindUser stores a record for core holding exactly groups, as registration would.
+func bindUser(t *testing.T, svc *opaque_api.DefaultOpaqueService, core uagc.CoreUser, groups ...string) {
+	rec := &uagc.OpaqueUserRecord{}
+	for _, g := range groups {
+		rec.UserGroups = append(rec.UserGroups, uagc.UserGroupBinding{CoreUser: core, UserGroupID: g})
+	}
+	data, err := uagc.SerializeOpaqueUserRecord(rec)
+	require.NoError(t, err)
+	require.NoError(t, svc.Store().SaveRaw(core, data))
+}
+
+// backdatedTicket signs a login ticket for user as if it had been issued at issuedAt.
+func backdatedTicket(t *testing.T, user uagc.UniqueUser, payload json.RawMessage, issuedAt time.Time) *at.AuthTicket {
+	keyID, priv := uagc.Ed448AuthTicketSigningKey()
+	ticket := at.AuthTicket{
+		Version:               at.AuthTicketVersion,
+		AuthenticatedUser:     user,
+		IssuedAtUnixTimestamp: issuedAt.Unix(),
+		Purpose:               at.AuthTicketPurposeLogin,
+		Nonce:                 "backdated",
+		Payload:               payload,
+		SigningKeyIdentifier:  keyID,
+	}
+	msg, err := at.CanonicalBytes(ticket)
+	require.NoError(t, err)
+	sig, err := ed448_api.SignWithContext(priv, msg, sigctx.AuthTicket)
+	require.NoError(t, err)
+	ticket.Signature = base64.RawURLEncoding.EncodeToString(sig)
+	return &ticket
+}
+
+func TestUserRoleSwitch(t *testing.T) {
+	r := session.NewRegistry(session_store.NewMemoryAdapter())
+	session.Install(r)
+	defer session.Install(nil)
+
+	svc, err := opaque_api.NewDefaultOpaqueService(opaque_store.NewGhettoAdapter(ghetto_db.New()))
+	require.NoError(t, err)
+
+	core := uagc.CoreUser{TenantID: "dojo-a", UserID: "dima"}
+	coach := uagc.UniqueUser{TenantID: core.TenantID, UserGroupID: uagc.UserGroupCoach, UserID: core.UserID}
+	parent := uagc.UniqueUser{TenantID: core.TenantID, UserGroupID: uagc.UserGroupParent, UserID: core.UserID}
+	staff := uagc.UniqueUser{TenantID: core.TenantID, UserGroupID: uagc.UserGroupStaff, UserID: core.UserID}
+
+	// sealed returns a Hydrate state for a login of user whose session signed in groups
+	sealed := func(t *testing.T, user uagc.UniqueUser, issuedAt time.Time, groups ...string) hd.HydrateStateEnvelope {
+		id, err := r.Start([]byte(t.Name()), core, groups, testDevice, "")
+		require.NoError(t, err)
+		payload, err := auth_ticket.SessionPayload(id)
+		require.NoError(t, err)
+		env, err := ss.CreateHydrateStateEnvelope(*backdatedTicket(t, user, payload, issuedAt), testDevice)
+		require.NoError(t, err)
+		return env
+	}
+
+	tests := []struct {
+		name     string
+		bound    []string // Groups the user holds when switching
+		signedIn []string // Groups the Hydrate state's session signed in
+		issuedAt time.Time
+		from, to uagc.UniqueUser
+		wantErr  string // "" = the switch succeeds
+	}{
+		{
+			name:     "happy path",
+			bound:    []string{uagc.UserGroupCoach, uagc.UserGroupParent},
+			signedIn: []string{uagc.UserGroupCoach},
+			issuedAt: time.Now(),
+			from:     coach, to: parent,
+		},
+		{
+			name:     "unbound target",
+			bound:    []string{uagc.UserGroupCoach, uagc.UserGroupParent},
+			signedIn: []string{uagc.UserGroupCoach},
+			issuedAt: time.Now(),
+			from:     coach, to: staff,
+			wantErr: "USER_GROUP_STAFF is not bound",
+		},
+		{
+			name:     "switch_from is not the hydrated role",
+			bound:    []string{uagc.UserGroupCoach, uagc.UserGroupParent, uagc.UserGroupStaff},
+			signedIn: []string{uagc.UserGroupCoach},
+			issuedAt: time.Now(),
+			from:     parent, to: staff,
+			wantErr: "does not match hydrate state",
+		},
+		{
+			name:     "switch_from no longer bound",
+			bound:    []string{uagc.UserGroupParent},
+			signedIn: []string{uagc.UserGroupCoach},
+			issuedAt: time.Now(),
+			from:     coach, to: parent,
+			wantErr: "USER_GROUP_COACH is not bound",
+		},
+		{
+			name:     "switch_from not signed in by the session",
+			bound:    []string{uagc.UserGroupCoach, uagc.UserGroupParent},
+			signedIn: []string{uagc.UserGroupParent},
+			issuedAt: time.Now(),
+			from:     coach, to: parent,
+			wantErr: "not signed in by this session",
+		},
+		{
+			name:     "expired hydrate state",
+			bound:    []string{uagc.UserGroupCoach, uagc.UserGroupParent},
+			signedIn: []string{uagc.UserGroupCoach},
+			issuedAt: time.Now().Add(-at.RehydratedTicketTTL - time.Hour),
+			from:     coach, to: parent,
+			wantErr: "expired",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bindUser(t, svc, core, tt.bound...)
+			payload, err := json.Marshal(hd.ClientUserRoleSwitchPayload{
+				HydrateStateEnvelope: sealed(t, coach, tt.issuedAt, tt.signedIn...),
+				DeviceIdentifier:     testDevice,
+				SwitchFrom:           tt.from,
+				SwitchTo:             tt.to,
+			})
+			require.NoError(t, err)
+
+			resp, status, info, extended := HandleUserRoleSwitch(svc, hd.HydrateClientReply{
+				CommandType:   hd.HydrateCmdUserRoleSwitch,
+				ClientPayload: string(payload),
+			})
+			if tt.wantErr != "" {
+				require.Equal(t, "403", status, info)
+				require.Contains(t, extended, tt.wantErr)
+				require.Nil(t, resp)
+				return
+			}
+			require.Equal(t, "200", status, extended)
+
+			var ok hd.UserRoleSwitchSuccessResponse
+			require.NoError(t, json.Unmarshal([]byte(resp.(hd.HydrateServerReply).ServerPayload), &ok))
+			require.Equal(t, tt.to, ok.AuthTicket.AuthenticatedUser)
+			require.Equal(t, at.AuthTicketPurposeUserRoleSwitch, ok.AuthTicket.Purpose)
+			require.True(t, ok.AuthTicket.IsRehydrated)
+			require.NoError(t, auth_ticket.VerifyAuthTicket(&ok.AuthTicket))
+		})
+	}
+}
@@ -1 +1,164 @@
 package secure_state
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	hd "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/encryption/chacha_poly1305/chacha_poly1305_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/encryption/rsa/rsa_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	"github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+const (
+	KeyBlockVersion        = "v1"
+	KeyEncryptionAlgorithm = "RSA-5120-SHA3-512-OAEP"
+	SignatureAlgorithm     = "Ed448"
+)
+
+// CreateHydrateStateEnvelope binds a fresh AuthTicket to a device, signs the resulting
+// RehydratedTicketPayload with the Hydrate key, and seals it for offline storage on the client.
+func CreateHydrateStateEnvelope(ticket at.AuthTicket, deviceID string) (hd.HydrateStateEnvelope, error) {
+	keyID, priv := user_auth_global_config.Ed448HydrateSigningKey()
+	payload := at.RehydratedTicketPayload{
+		HydrateVersion:       at.RehydratedTicketVersion,
+		AssociatedTicket:     ticket,
+		DeviceIdentifier:     deviceID,
+		SigningKeyIdentifier: keyID,
+	}
+
+	// Sign the serialized payload
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return hd.HydrateStateEnvelope{}, err
+	}
+
+	sig, err := ed448_api.SignWithContext(priv, payloadBytes, sigctx.HydrateState)
+	if err != nil {
+		return hd.HydrateStateEnvelope{}, err
+	}
+	payload.Signature = base64.RawURLEncoding.EncodeToString(sig)
+
+	// Re-marshal with signature
+	signedPayloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return hd.HydrateStateEnvelope{}, err
+	}
+
+	// Generate symmetric key
+	symmetricKey := make([]byte, chacha_poly1305_api.KeySize)
+	if _, err := rand.Read(symmetricKey); err != nil {
+		return hd.HydrateStateEnvelope{}, err
+	}
+
+	// Encrypt payload using symmetric key
+	nonceEnc := make([]byte, chacha_poly1305_api.NonceSizeX)
+	if _, err := rand.Read(nonceEnc); err != nil {
+		return hd.HydrateStateEnvelope{}, err
+	}
+	ciphertext, err := chacha_poly1305_api.Encrypt(symmetricKey, nonceEnc, signedPayloadBytes, []byte(deviceID))
+	if err != nil {
+		return hd.HydrateStateEnvelope{}, err
+	}
+
+	// Encrypt symmetric key using RSA
+	encKey, err := rsa_api.Encrypt(user_auth_global_config.RsaHydratePublicKey(), symmetricKey, nil)
+	if err != nil {
+		return hd.HydrateStateEnvelope{}, err
+	}
+
+	// Sign symmetric key using Ed448
+	sigKey, err := ed448_api.SignWithContext(priv, symmetricKey, sigctx.HydrateEnvelopeKey)
+	if err != nil {
+		return hd.HydrateStateEnvelope{}, err
+	}
+
+	return hd.HydrateStateEnvelope{
+		EnvelopeKeyBlock: hd.HydrateEnvelopeKeyBlock{
+			Version:                                KeyBlockVersion,
+			EncryptedEphemeralSymmetricEnvelopeKey: base64.RawURLEncoding.EncodeToString(encKey),
+			SignatureKeyID:                         keyID,
+			EphemeralSymmetricEnvelopeKeySignature: base64.RawURLEncoding.EncodeToString(sigKey),
+		},
+		EncryptedRehydratedTicket: base64.RawURLEncoding.EncodeToString(append(nonceEnc, ciphertext...)),
+		ExpiresAtUnixTimestamp:    ticket.IssuedAtUnixTimestamp + int64(at.RehydratedTicketTTL.Seconds()),
+	}, nil
+}
+
+// VerifyAndDecryptHydrateEnvelope opens a HydrateStateEnvelope presented by deviceID and
+// returns the verified RehydratedTicketPayload. Expiry and ticket checks are left to the caller.
+func VerifyAndDecryptHydrateEnvelope(env hd.HydrateStateEnvelope, deviceID string) (*at.RehydratedTicketPayload, error) {
+	// --- Decrypt symmetric key ---
+	encKey, err := base64.RawURLEncoding.DecodeString(env.EnvelopeKeyBlock.EncryptedEphemeralSymmetricEnvelopeKey)
+	if err != nil {
+		return nil, errors.New("invalid base64 in encrypted symmetric key")
+	}
+	sigKeyBytes, err := base64.RawURLEncoding.DecodeString(env.EnvelopeKeyBlock.EphemeralSymmetricEnvelopeKeySignature)
+	if err != nil || len(sigKeyBytes) != ed448_api.SignatureSize {
+		return nil, errors.New("invalid signature on symmetric key")
+	}
+
+	symmetricKey, err := rsa_api.Decrypt(user_auth_global_config.RsaHydratePrivateKey(), encKey, nil)
+	if err != nil {
+		return nil, errors.New("RSA decryption of symmetric key failed")
+	}
+
+	keyBlockPub, err := user_auth_global_config.Ed448HydrateVerificationKey(env.EnvelopeKeyBlock.SignatureKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ed448_api.VerifyWithContext(keyBlockPub, symmetricKey, sigKeyBytes, sigctx.HydrateEnvelopeKey) {
+		return nil, errors.New("Ed448 signature on symmetric key verification failed")
+	}
+
+	// --- Decrypt payload (device identifier is bound as AAD) ---
+	ciphertextWithNonce, err := base64.RawURLEncoding.DecodeString(env.EncryptedRehydratedTicket)
+	if err != nil || len(ciphertextWithNonce) <= chacha_poly1305_api.NonceSizeX {
+		return nil, errors.New("invalid base64 or ciphertext size")
+	}
+	nonce := ciphertextWithNonce[:chacha_poly1305_api.NonceSizeX]
+	ciphertext := ciphertextWithNonce[chacha_poly1305_api.NonceSizeX:]
+
+	plaintext, err := chacha_poly1305_api.Decrypt(symmetricKey, nonce, ciphertext, []byte(deviceID))
+	if err != nil {
+		return nil, errors.New("decryption of RehydratedTicketPayload failed")
+	}
+
+	// --- Verify RehydratedTicketPayload signature ---
+	var payload at.RehydratedTicketPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, errors.New("failed to unmarshal decrypted hydrate payload")
+	}
+
+	sigBytes, err := base64.RawURLEncoding.DecodeString(payload.Signature)
+	if err != nil || len(sigBytes) != ed448_api.SignatureSize {
+		return nil, errors.New("invalid base64 or length of hydrate payload signature")
+	}
+
+	// Remove signature before verification
+	payloadCopy := payload
+	payloadCopy.Signature = ""
+	msgBytes, err := json.Marshal(payloadCopy)
+	if err != nil {
+		return nil, errors.New("failed to re-marshal hydrate payload for signature verification")
+	}
+
+	payloadPub, err := user_auth_global_config.Ed448HydrateVerificationKey(payload.SigningKeyIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	if !ed448_api.VerifyWithContext(payloadPub, msgBytes, sigBytes, sigctx.HydrateState) {
+		return nil, errors.New("signature on hydrate payload verification failed")
+	}
+
+	if payload.DeviceIdentifier != deviceID {
+		return nil, errors.New("hydrate payload is bound to a different device")
+	}
+
+	return &payload, nil
+}
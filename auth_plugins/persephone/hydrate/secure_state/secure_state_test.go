@@ -0,0 +1,29 @@
+package secure_state
+
+import (
+	"testing"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHydrateEnvelopeRoundTrip(t *testing.T) {
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserID: "akira", UserGroupID: uagc.UserGroupCoach}
+	ticket, err := auth_ticket.CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, nil)
+	require.NoError(t, err)
+
+	env, err := CreateHydrateStateEnvelope(*ticket, "device-1")
+	require.NoError(t, err)
+
+	payload, err := VerifyAndDecryptHydrateEnvelope(env, "device-1")
+	require.NoError(t, err)
+	require.Equal(t, "device-1", payload.DeviceIdentifier)
+	require.Equal(t, user, payload.AssociatedTicket.AuthenticatedUser)
+	require.NoError(t, auth_ticket.VerifyAuthTicketSignature(&payload.AssociatedTicket))
+
+	// Replaying the envelope from another device must fail
+	_, err = VerifyAndDecryptHydrateEnvelope(env, "device-2")
+	require.Error(t, err)
+}
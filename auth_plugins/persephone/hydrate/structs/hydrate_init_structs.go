@@ -1,6 +1,9 @@
 package structs
 
-import "time"
+import (
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"time"
+)
 
 const (
 	HydrateCmdInitiateStepOne   = "HYDRATE_INIT_STEP_ONE"
@@ -13,6 +16,7 @@ const (
 	DefaultPoWDifficulty = 20
 	HydratePoWTTL        = 5 * time.Minute
 	DefaultPoWSubject    = "HYDRATE_INIT"
+	HydratePoWIntent     = "HYDRATE" // Bound into the challenge so OPAQUE PoWs can't pay for Hydrate and vice versa
 )
 
 type HydrateInit struct {
@@ -21,12 +25,14 @@ type HydrateInit struct {
 }
 
 type ClientHydrateInitStepOnePayload struct {
-	UnixTimestamp int64 `json:"unix_timestamp"`
+	UnixTimestamp int64         `json:"unix_timestamp"`
+	User          uagc.CoreUser `json:"user"` // Optional hint for per-user PoW difficulty
 }
 
 type ServerHydrateInitStepTwoPayload struct {
 	UnixTimestamp int64  `json:"unix_timestamp"`
 	PoWChallenge  string `json:"pow_challenge"`
+	PoWScheme     string `json:"pow_scheme"` // pow_api.Scheme*, tells the client which solver to run
 }
 
 type ClientHydrateInitStepThreePayload struct {
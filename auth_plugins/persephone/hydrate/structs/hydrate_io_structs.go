@@ -0,0 +1,84 @@
+package structs
+
+import (
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+const (
+	HydrateCmdDehydrate = "HYDRATE_DEHYDRATE"
+	HydrateCmdRehydrate = "HYDRATE_REHYDRATE"
+
+	HydrateCmdUserRoleSwitch = "HYDRATE_USER_ROLE_SWITCH"
+)
+
+const (
+	HydrateEnvelopeKeyBlockVersion = "v1"
+	DehydrateResponseVersion       = "v1"
+	RehydrateResponseVersion       = "v1"
+	UserRoleSwitchResponseVersion  = "v1"
+)
+
+type HydrateServerReply struct {
+	CommandType   string `json:"command_type"`
+	ServerPayload string `json:"server_payload,omitempty"`
+}
+
+type HydrateClientReply struct {
+	PoWSolution   string `json:"pow"`
+	UnixTimestamp int64  `json:"unix_timestamp"`
+	CommandType   string `json:"command_type"`
+	ClientPayload string `json:"client_payload"`
+}
+
+type HydrateEnvelopeKeyBlock struct {
+	Version                                string `json:"version"`
+	EncryptedEphemeralSymmetricEnvelopeKey string `json:"encrypted_ephemeral_symmetric_master_key"`
+	SignatureKeyID                         string `json:"signature_key_id"`
+	EphemeralSymmetricEnvelopeKeySignature string `json:"ephemeral_symmetric_envelope_key_signature"`
+}
+
+// HydrateStateEnvelope is the opaque blob the client stores offline ("Remember Me").
+// Only the server can open it; the client just hands it back on rehydration.
+type HydrateStateEnvelope struct {
+	EnvelopeKeyBlock          HydrateEnvelopeKeyBlock `json:"envelope_key_block"`
+	EncryptedRehydratedTicket string                  `json:"encrypted_rehydrated_ticket"`
+	ExpiresAtUnixTimestamp    int64                   `json:"expires_at_unix_timestamp"` // Informational, the sealed copy is authoritative
+}
+
+type ClientDehydratePayload struct {
+	AuthTicket       at.AuthTicket `json:"auth_ticket"`       // Fresh, OPAQUE-issued login ticket
+	DeviceIdentifier string        `json:"device_identifier"` // Stable per-install identifier
+}
+
+type DehydrateSuccessResponse struct {
+	Version              string               `json:"version"`
+	Success              bool                 `json:"success"`
+	HydrateStateEnvelope HydrateStateEnvelope `json:"hydrate_state_envelope"`
+}
+
+type ClientRehydratePayload struct {
+	HydrateStateEnvelope HydrateStateEnvelope `json:"hydrate_state_envelope"`
+	DeviceIdentifier     string               `json:"device_identifier"`
+}
+
+type RehydrateSuccessResponse struct {
+	Version    string        `json:"version"`
+	Success    bool          `json:"success"`
+	AuthTicket at.AuthTicket `json:"auth_ticket"` // IsRehydrated = true
+}
+
+// ClientUserRoleSwitchPayload carries the offline Hydrate state plus the requested switch.
+// The server opens the envelope and assembles the at.UserRoleSwitchPayload from it.
+type ClientUserRoleSwitchPayload struct {
+	HydrateStateEnvelope HydrateStateEnvelope `json:"hydrate_state_envelope"`
+	DeviceIdentifier     string               `json:"device_identifier"`
+	SwitchFrom           uagc.UniqueUser      `json:"switch_from"`
+	SwitchTo             uagc.UniqueUser      `json:"switch_to"`
+}
+
+type UserRoleSwitchSuccessResponse struct {
+	Version    string        `json:"version"`
+	Success    bool          `json:"success"`
+	AuthTicket at.AuthTicket `json:"auth_ticket"` // Purpose = AuthTicketPurposeUserRoleSwitch
+}
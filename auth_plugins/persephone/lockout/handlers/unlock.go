@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/lockout"
+	ls "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/lockout/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// HandleAccountUnlock processes PSP_ACCOUNT_UNLOCK: an admin of the same tenant, holding
+// the policy right account/unlock, lifts a login lockout or backoff on a CoreUser.
+func HandleAccountUnlock(
+	payload string,
+	traceID string,
+	lock *lockout.Controller,
+) (any, string, string, string) {
+	var req ls.ClientAccountUnlockPayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid account unlock payload", err.Error()
+	}
+
+	admin, err := auth_ticket.RequireFreshLogin(&req.AuthTicket)
+	if err != nil {
+		return nil, "403", "AuthTicket rejected", err.Error()
+	}
+
+	ev := audit_log.Event{
+		Actor:   admin,
+		Target:  uagc.UniqueUser{TenantID: req.TargetUser.TenantID, UserID: req.TargetUser.UserID},
+		Command: audit_log.EventAccountUnlock,
+		TraceID: traceID,
+	}
+
+	if req.TargetUser.TenantID != admin.TenantID || req.TargetUser.UserID == "" {
+		return audit_log.Reply(ev, nil, "403", "Account unlock denied", "target must be a user of own tenant")
+	}
+	if err := policy.Active().AuthorizeUser(admin, false, policy.ResourceAccount, policy.OperationUnlock); err != nil {
+		return audit_log.Reply(ev, nil, "403", "Account unlock denied", err.Error())
+	}
+
+	if err := lock.Unlock(req.TargetUser); err != nil {
+		return audit_log.Reply(ev, nil, "500", "Account unlock failed", err.Error())
+	}
+
+	return audit_log.Reply(ev, ls.AccountUnlockSuccessResponse{
+		Version:    ls.AccountUnlockResponseVersion,
+		Success:    true,
+		TargetUser: req.TargetUser,
+	}, "200", "Account unlocked", "")
+}
@@ -0,0 +1,111 @@
+// Package lockout throttles online password guessing. Failed OPAQUE LoginFinish attempts
+// are counted per CoreUser in a lockout_store; each count maps to a wait before the next
+// attempt is accepted, doubling with every failure, and eventually to a temporary lockout
+// an admin can lift early.
+//
+// Guessing spread across a tenant is not gated here: a tenant-wide wait would let anyone
+// hold every login of a dojo back by guessing at made-up users. Failed logins raise the
+// tenant's PoW difficulty instead (see pow_control), which costs the guesser, not the
+// tenant's users.
+//
+// Counts are kept for any CoreUser named in a login, registered or not, so the replies
+// say nothing about which accounts exist.
+package lockout
+
+import (
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/lockout_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// Schedule maps a failure count to a wait.
+type Schedule struct {
+	FreeAttempts int           // Failures tolerated before any wait
+	BaseDelay    time.Duration // Wait after the first counted failure, doubling with each further one
+	MaxDelay     time.Duration // Cap on that wait
+	LockAfter    int           // Failures that lock outright for LockDuration; 0 = never
+	LockDuration time.Duration
+	Window       time.Duration // A quiet period this long forgets all failures
+}
+
+// Delay is how long after the last of failures the next attempt must wait.
+func (s Schedule) Delay(failures int) time.Duration {
+	if s.LockAfter > 0 && failures >= s.LockAfter {
+		return s.LockDuration
+	}
+	if failures <= s.FreeAttempts {
+		return 0
+	}
+	shift := failures - s.FreeAttempts - 1
+	if shift > 30 {
+		return s.MaxDelay
+	}
+	return min(s.BaseDelay<<shift, s.MaxDelay)
+}
+
+// Config holds the per-CoreUser schedule.
+type Config struct {
+	User Schedule
+}
+
+// Status is why a login is being held back.
+type Status struct {
+	RetryAfter time.Duration
+	Locked     bool // The CoreUser reached User.LockAfter
+}
+
+type Controller struct {
+	store lockout_store.LockoutStore
+	conf  Config
+	now   func() time.Time
+}
+
+func NewController(store lockout_store.LockoutStore, conf Config) *Controller {
+	// Forgetting failures before a lock or wait runs out would lift it early
+	conf.User.Window = max(conf.User.Window, conf.User.LockDuration, conf.User.MaxDelay)
+	return &Controller{store: store, conf: conf, now: time.Now}
+}
+
+// Check returns a non-nil Status if user may not attempt a login yet.
+func (c *Controller) Check(user uagc.CoreUser) (*Status, error) {
+	rec, err := c.store.Get(lockout_store.UserKey(user))
+	if err != nil {
+		return nil, err
+	}
+
+	now, s := c.now(), c.conf.User
+	last := time.Unix(rec.LastFailureAtUnixTimestamp, 0)
+	if now.Sub(last) > s.Window {
+		return nil, nil
+	}
+	wait := last.Add(s.Delay(rec.Failures)).Sub(now)
+	if wait <= 0 {
+		return nil, nil
+	}
+	return &Status{
+		RetryAfter: wait,
+		Locked:     s.LockAfter > 0 && rec.Failures >= s.LockAfter,
+	}, nil
+}
+
+// RecordFailure counts a failed LoginFinish. It reports true when this failure locked
+// the CoreUser, so the caller can audit it once.
+func (c *Controller) RecordFailure(user uagc.CoreUser) (bool, error) {
+	s := c.conf.User
+	rec, err := c.store.RecordFailure(lockout_store.UserKey(user), c.now().Unix(), int64(s.Window.Seconds()))
+	if err != nil {
+		return false, err
+	}
+	return s.LockAfter > 0 && rec.Failures == s.LockAfter, nil
+}
+
+// RecordSuccess clears the CoreUser's failures.
+func (c *Controller) RecordSuccess(user uagc.CoreUser) error {
+	return c.store.Reset(lockout_store.UserKey(user))
+}
+
+// Unlock lifts a lockout or wait on user, e.g. by an admin once the owner is verified.
+func (c *Controller) Unlock(user uagc.CoreUser) error {
+	return c.store.Reset(lockout_store.UserKey(user))
+}
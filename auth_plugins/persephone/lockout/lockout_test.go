@@ -0,0 +1,95 @@
+package lockout
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/lockout_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	User: Schedule{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    6,
+		LockDuration: time.Hour,
+		Window:       time.Hour,
+	},
+}
+
+func TestScheduleDelay(t *testing.T) {
+	s := testConfig.User
+	require.Equal(t, time.Duration(0), s.Delay(2))
+	require.Equal(t, time.Second, s.Delay(3))
+	require.Equal(t, 4*time.Second, s.Delay(5))
+	require.Equal(t, time.Hour, s.Delay(6))
+	require.Equal(t, time.Hour, s.Delay(1000))
+}
+
+func TestLockoutAndUnlock(t *testing.T) {
+	c := NewController(lockout_store.NewGhettoAdapter(ghetto_db.New()), testConfig)
+	clock := time.Unix(time.Now().Unix(), 0) // The store keeps whole seconds
+	c.now = func() time.Time { return clock }
+
+	akira := uagc.CoreUser{TenantID: "dojo-a", UserID: "akira"}
+	for i := 0; i < 2; i++ {
+		_, err := c.RecordFailure(akira)
+		require.NoError(t, err)
+	}
+	held, err := c.Check(akira)
+	require.NoError(t, err)
+	require.Nil(t, held)
+
+	_, err = c.RecordFailure(akira)
+	require.NoError(t, err)
+	held, err = c.Check(akira)
+	require.NoError(t, err)
+	require.Equal(t, time.Second, held.RetryAfter)
+	require.False(t, held.Locked)
+
+	for i := 0; i < 2; i++ {
+		locked, err := c.RecordFailure(akira)
+		require.NoError(t, err)
+		require.False(t, locked)
+	}
+	locked, err := c.RecordFailure(akira)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	clock = clock.Add(30 * time.Minute)
+	held, err = c.Check(akira)
+	require.NoError(t, err)
+	require.True(t, held.Locked)
+
+	require.NoError(t, c.Unlock(akira))
+	held, err = c.Check(akira)
+	require.NoError(t, err)
+	require.Nil(t, held)
+}
+
+func TestSprayingATenantHoldsNoOneElse(t *testing.T) {
+	c := NewController(lockout_store.NewGhettoAdapter(ghetto_db.New()), testConfig)
+	clock := time.Unix(time.Now().Unix(), 0)
+	c.now = func() time.Time { return clock }
+
+	// However many made-up users are guessed at, only they are held back; the tenant's
+	// real users still log in (tenant-wide pressure goes to PoW difficulty instead)
+	for i := 0; i < 100; i++ {
+		user := uagc.CoreUser{TenantID: "dojo-a", UserID: fmt.Sprintf("made-up-%d", i%10)}
+		_, err := c.RecordFailure(user)
+		require.NoError(t, err)
+	}
+
+	held, err := c.Check(uagc.CoreUser{TenantID: "dojo-a", UserID: "made-up-0"})
+	require.NoError(t, err)
+	require.True(t, held.Locked)
+
+	held, err = c.Check(uagc.CoreUser{TenantID: "dojo-a", UserID: "akira"})
+	require.NoError(t, err)
+	require.Nil(t, held)
+}
@@ -0,0 +1,19 @@
+package structs
+
+import (
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+const AccountUnlockResponseVersion = "v1"
+
+type ClientAccountUnlockPayload struct {
+	AuthTicket at.AuthTicket `json:"auth_ticket"` // Admin's fresh login ticket
+	TargetUser uagc.CoreUser `json:"target_user"` // Must be in the admin's tenant
+}
+
+type AccountUnlockSuccessResponse struct {
+	Version    string        `json:"version"`
+	Success    bool          `json:"success"`
+	TargetUser uagc.CoreUser `json:"target_user"`
+}
@@ -2,37 +2,143 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant"
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	config "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/config"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/lockout"
 	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/pow_control"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
-	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_api"
+	"github.com/drlzh/mng-app-user-auth-prot/grant_store"
+	"github.com/drlzh/mng-app-user-auth-prot/pow_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 )
 
 func DispatchOpaque(
 	payload string,
 	traceID string,
+	remoteAddr string,
 	svc *opaque_api.DefaultOpaqueService,
+	ledger grant_store.AuthGrantLedger,
+	seen pow_store.SeenNonceStore,
+	pow *pow_control.DifficultyController,
+	lock *lockout.Controller,
+	conf *config.Config,
 ) (any, string, string, string) {
 	var msg op.OpaqueClientReply
 	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
 		return nil, "400", "Invalid OPAQUE message", err.Error()
 	}
 
-	// PoW check — here reused across subcommands
-	if err := hashcash_api.VerifyToken(msg.PoWSolution, "OPAQUE_INIT"); err != nil {
+	intent, ok := opaqueIntent(msg.CommandType)
+	if !ok {
+		return nil, "400", "Unknown OPAQUE subcommand", msg.CommandType
+	}
+
+	// All OPAQUE client payloads name the target CoreUser under "user"
+	user := clientPayloadUser(msg)
+	keys := []string{
+		pow_control.IPKey(remoteAddr),
+		pow_control.TenantKey(user.TenantID),
+		pow_control.UserKey(user),
+	}
+
+	// The challenge may have been issued without a user hint, so re-check against the real target
+	if err := pow.RequireDifficulty(conf.PoWScheme, msg.PoWSolution, keys...); err != nil {
 		return nil, "403", "PoW verification failed", err.Error()
 	}
 
+	// PoW check — the challenge must have been issued for this trace and this flow,
+	// and each subcommand may spend it at most conf.PoWMaxUses times
+	subject := pow_api.BindSubject(conf.PoWSubject, traceID, intent)
+	if err := pow_api.VerifyAndSpendToken(conf.PoWScheme, msg.PoWSolution, subject, msg.CommandType, seen, conf.PoWMaxUses); err != nil {
+		return nil, "403", "PoW verification failed", err.Error()
+	}
+
+	var resp any
+	var status, info, extended string
 	switch msg.CommandType {
 	case op.OpaqueCmdLoginStepOne, op.OpaqueCmdLoginStepTwo:
-		return HandleLogin(svc, msg)
+		resp, status, info, extended = HandleLogin(svc, lock, traceID, msg)
+		if status != "200" {
+			pow.RecordFailure(keys...)
+		}
 
 	case op.OpaqueCmdRegisterStepOne, op.OpaqueCmdRegisterStepTwo:
-		return HandleRegister(svc, msg)
+		resp, status, info, extended = HandleRegister(svc, ledger, msg)
 
 	case op.OpaqueCmdPasswordResetStepOne, op.OpaqueCmdPasswordResetStepTwo:
-		return HandlePasswordReset(svc, msg)
+		resp, status, info, extended = HandlePasswordReset(svc, ledger, msg)
 
 	default:
 		return nil, "400", "Unknown OPAQUE subcommand", msg.CommandType
 	}
+
+	auditOpaque(msg, user, traceID, status, info)
+	return resp, status, info, extended
+}
+
+// auditOpaque records the final step of each OPAQUE flow. The actor is whoever enabled
+// it: the user for logins, the AuthGrant issuer for registrations and password resets.
+// On failure the grant is unverified, so no actor is recorded.
+func auditOpaque(msg op.OpaqueClientReply, user uagc.CoreUser, traceID, status, info string) {
+	ev := audit_log.Event{
+		Target:  uagc.UniqueUser{TenantID: user.TenantID, UserID: user.UserID},
+		TraceID: traceID,
+		Outcome: audit_log.OutcomeForStatus(status),
+		Detail:  info,
+	}
+
+	var purpose string
+	switch msg.CommandType {
+	case op.OpaqueCmdLoginStepTwo:
+		ev.Command = audit_log.EventLogin
+	case op.OpaqueCmdRegisterStepTwo:
+		ev.Command, purpose = audit_log.EventRegister, ag.AuthGrantPurposeRegister
+	case op.OpaqueCmdPasswordResetStepTwo:
+		ev.Command, purpose = audit_log.EventPasswordReset, ag.AuthGrantPurposePasswordReset
+	default:
+		return
+	}
+
+	if ev.Outcome == audit_log.OutcomeSuccess {
+		ev.Actor = ev.Target
+		if purpose != "" {
+			var p struct {
+				AuthGrant ag.AuthGrant `json:"auth_grant"`
+			}
+			_ = json.Unmarshal([]byte(msg.ClientPayload), &p)
+			if subject, err := auth_grant.VerifyAuthGrantForUser(&p.AuthGrant, purpose, user); err == nil {
+				ev.Actor = subject.IssuedBy
+				ev.Detail = fmt.Sprintf("%s (auth grant %s)", info, p.AuthGrant.GrantID)
+			}
+		}
+	}
+	audit_log.Record(ev)
+}
+
+// opaqueIntent maps an OPAQUE subcommand to the PoW intent it must have been issued for
+func opaqueIntent(cmd string) (string, bool) {
+	switch cmd {
+	case op.OpaqueCmdLoginStepOne, op.OpaqueCmdLoginStepTwo:
+		return op.OpaqueIntentLogin, true
+	case op.OpaqueCmdRegisterStepOne, op.OpaqueCmdRegisterStepTwo:
+		return op.OpaqueIntentRegister, true
+	case op.OpaqueCmdPasswordResetStepOne, op.OpaqueCmdPasswordResetStepTwo:
+		return op.OpaqueIntentPasswordReset, true
+	default:
+		return "", false
+	}
+}
+
+func clientPayloadUser(msg op.OpaqueClientReply) uagc.CoreUser {
+	var p struct {
+		User uagc.CoreUser `json:"user"`
+	}
+	_ = json.Unmarshal([]byte(msg.ClientPayload), &p)
+	return p.User
 }
@@ -4,12 +4,13 @@ import (
 	"encoding/json"
 	config "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/config"
 	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
-	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_api"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/pow_control"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_api"
 	"time"
 )
 
 // HandleOpaqueInit processes PSP_CMD_OPAQUE_INITIATE_OPAQUE using raw JSON payload + trace
-func HandleOpaqueInit(payload string, traceID string, conf *config.Config) (any, string, string, string) {
+func HandleOpaqueInit(payload string, traceID string, remoteAddr string, pow *pow_control.DifficultyController, conf *config.Config) (any, string, string, string) {
 	var init op.OpaqueInit
 	if err := json.Unmarshal([]byte(payload), &init); err != nil {
 		return nil, "400", "Invalid OpaqueInit JSON", err.Error()
@@ -17,29 +18,36 @@ func HandleOpaqueInit(payload string, traceID string, conf *config.Config) (any,
 
 	switch init.InitStep {
 	case op.OpaqueCmdInitiateStepOne:
-		return handleInitStepOne(init.InitPayload, conf)
+		return handleInitStepOne(init.InitPayload, traceID, remoteAddr, pow, conf)
 
 	case op.OpaqueCmdInitiateStepThree:
-		return handleInitStepThree(init.InitPayload, conf)
+		return handleInitStepThree(init.InitPayload, traceID, conf)
 
 	default:
 		return nil, "400", "Unknown init_step", init.InitStep
 	}
 }
 
-func handleInitStepOne(payload string, conf *config.Config) (any, string, string, string) {
+func handleInitStepOne(payload string, traceID string, remoteAddr string, pow *pow_control.DifficultyController, conf *config.Config) (any, string, string, string) {
 	var step1 op.ClientOpaqueInitStepOnePayload
 	if err := json.Unmarshal([]byte(payload), &step1); err != nil {
 		return nil, "400", "Invalid StepOne payload", err.Error()
 	}
+	if !isOpaqueIntent(step1.Intent) {
+		return nil, "400", "Unknown PoW intent", step1.Intent
+	}
 
-	cfg := hashcash_api.Config{
-		Subject:    conf.PoWSubject,
-		Difficulty: conf.PoWDifficulty,
-		TTL:        conf.PoWTTL,
+	// User hint is optional; DispatchOpaque re-checks difficulty against the real user
+	keys := []string{
+		pow_control.IPKey(remoteAddr),
+		pow_control.TenantKey(step1.User.TenantID),
+		pow_control.UserKey(step1.User),
 	}
+	pow.RecordRequest(keys...)
 
-	chal, err := hashcash_api.CreateChallenge(cfg)
+	// The challenge is only valid for this trace and this OPAQUE flow
+	subject := pow_api.BindSubject(conf.PoWSubject, traceID, step1.Intent)
+	chal, err := pow_api.CreateChallenge(conf.PoWChallengeConfig(subject, pow.Difficulty(keys...)))
 	if err != nil {
 		return nil, "500", "PoW challenge creation failed", err.Error()
 	}
@@ -47,17 +55,19 @@ func handleInitStepOne(payload string, conf *config.Config) (any, string, string
 	resp := op.ServerOpaqueInitStepTwoPayload{
 		UnixTimestamp: time.Now().Unix(),
 		PoWChallenge:  chal.Token,
+		PoWScheme:     chal.Scheme,
 	}
 	return resp, "200", "PoW challenge issued", ""
 }
 
-func handleInitStepThree(payload string, conf *config.Config) (any, string, string, string) {
+func handleInitStepThree(payload string, traceID string, conf *config.Config) (any, string, string, string) {
 	var step3 op.ClientOpaqueInitStepThreePayload
 	if err := json.Unmarshal([]byte(payload), &step3); err != nil {
 		return nil, "400", "Invalid StepThree payload", err.Error()
 	}
 
-	if err := hashcash_api.VerifyToken(step3.PoWSolution, conf.PoWSubject); err != nil {
+	subject := pow_api.BindSubject(conf.PoWSubject, traceID, step3.Intent)
+	if err := pow_api.VerifyToken(conf.PoWScheme, step3.PoWSolution, subject); err != nil {
 		return op.ServerOpaqueInitStepFourPayload{
 			UnixTimestamp: time.Now().Unix(),
 			Success:       false,
@@ -69,3 +79,12 @@ func handleInitStepThree(payload string, conf *config.Config) (any, string, stri
 		Success:       true,
 	}, "200", "PoW verified", ""
 }
+
+func isOpaqueIntent(intent string) bool {
+	switch intent {
+	case op.OpaqueIntentLogin, op.OpaqueIntentRegister, op.OpaqueIntentPasswordReset:
+		return true
+	default:
+		return false
+	}
+}
@@ -3,39 +3,73 @@ package handlers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
 
 	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
 	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/lockout"
 	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/secure_state"
 	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
 	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 )
 
+// errLoginFinish marks a wrong password (or a forged KE3), the failure lockout counts.
+var errLoginFinish = errors.New("opaque login step 2 failed")
+
+// loginFailedInfo is the one answer for every failed login step. Unknown users, wrong
+// passwords and internal errors must look alike to the client; the cause is only logged.
+const loginFailedInfo = "Login failed"
+
+// HandleLogin runs both login steps. Either is refused while lock holds the named CoreUser
+// back; failed LoginFinish attempts are counted against the user step one ran for. Step
+// one answers unknown users with a fake KE2, so they fail in step two like a wrong
+// password, and are throttled the same way.
 func HandleLogin(
 	svc *opaque_api.DefaultOpaqueService,
+	lock *lockout.Controller,
+	traceID string,
 	req op.OpaqueClientReply,
 ) (any, string, string, string) {
+	var clientPayload op.ClientLoginPayload
+	if err := json.Unmarshal([]byte(req.ClientPayload), &clientPayload); err != nil {
+		return nil, "400", "Invalid login payload", err.Error()
+	}
+
+	held, err := lock.Check(clientPayload.User)
+	if err != nil {
+		return nil, "500", "Login throttle unavailable", err.Error()
+	}
+	if held != nil {
+		info := "Too many failed logins"
+		if held.Locked {
+			info = "Account temporarily locked"
+		}
+		return nil, "403", info, fmt.Sprintf("retry after %ds", int64(held.RetryAfter.Seconds()+0.5))
+	}
 
 	switch req.CommandType {
 	case op.OpaqueCmdLoginStepOne:
-		var clientPayload op.ClientLoginPayload
-		if err := json.Unmarshal([]byte(req.ClientPayload), &clientPayload); err != nil {
-			return nil, "400", "Invalid login payload", err.Error()
+		loginResp, akeState, err := svc.LoginStep1(clientPayload.User, req.OpaqueClientResponse)
+		if err != nil {
+			return loginFailed(traceID, op.OpaqueCmdLoginStepOne, err)
 		}
 
-		loginResp, serverState, err := svc.LoginStep1(clientPayload.User, req.OpaqueClientResponse)
+		serverState, err := json.Marshal(op.LoginServerState{User: clientPayload.User, AkeServerState: akeState})
 		if err != nil {
-			return nil, "400", "LoginStep1 failed", err.Error()
+			return loginFailed(traceID, op.OpaqueCmdLoginStepOne, err)
 		}
-
 		envelope, err := ss.CreateOpaqueStateEnvelope(
 			op.OpaqueCmdLoginStepOne,
-			serverState,
+			string(serverState),
 		)
 		if err != nil {
-			return nil, "500", "Failed to seal opaque state", err.Error()
+			return loginFailed(traceID, op.OpaqueCmdLoginStepOne, err)
 		}
 
 		reply := op.OpaqueServerReply{
@@ -47,9 +81,15 @@ func HandleLogin(
 		return reply, "200", "Login Step One successful", ""
 
 	case op.OpaqueCmdLoginStepTwo:
-		sessionAuth, err := handleOpaqueLoginStepTwo(svc, req)
+		sessionAuth, user, err := handleOpaqueLoginStepTwo(svc, clientPayload, req)
+		if errors.Is(err, errLoginFinish) {
+			recordLoginFailure(lock, user, traceID)
+		}
 		if err != nil {
-			return nil, "400", "LoginStep2 failed", err.Error()
+			return loginFailed(traceID, op.OpaqueCmdLoginStepTwo, err)
+		}
+		if err := lock.RecordSuccess(user); err != nil {
+			log.Printf("⚠️ Failed to clear login failures for %s: %v", user.EncodeKey(), err)
 		}
 
 		reply := op.OpaqueServerReply{
@@ -64,35 +104,77 @@ func HandleLogin(
 	}
 }
 
+// loginFailed logs why a login step failed and returns the uniform failure reply.
+func loginFailed(traceID, step string, err error) (any, string, string, string) {
+	log.Printf("⚠️ %s failed (trace %s): %v", step, traceID, err)
+	return nil, "400", loginFailedInfo, ""
+}
+
+// recordLoginFailure counts a failed LoginFinish and audits the failure that locks the user.
+func recordLoginFailure(lock *lockout.Controller, user uagc.CoreUser, traceID string) {
+	locked, err := lock.RecordFailure(user)
+	if err != nil {
+		log.Printf("⚠️ Failed to record login failure for %s: %v", user.EncodeKey(), err)
+		return
+	}
+	if locked {
+		audit_log.Record(audit_log.Event{
+			Target:  uagc.UniqueUser{TenantID: user.TenantID, UserID: user.UserID},
+			Command: audit_log.EventAccountLock,
+			TraceID: traceID,
+			Outcome: audit_log.OutcomeSuccess,
+			Detail:  "too many failed logins",
+		})
+	}
+}
+
+// handleOpaqueLoginStepTwo finishes the AKE and returns the encoded LoginSuccessResponse,
+// along with the CoreUser step one ran for (known once the envelope opens).
 func handleOpaqueLoginStepTwo(
 	svc *opaque_api.DefaultOpaqueService,
+	clientPayload op.ClientLoginPayload,
 	msg op.OpaqueClientReply,
-) (string, error) {
-	var clientPayload op.ClientLoginPayload
-	if err := json.Unmarshal([]byte(msg.ClientPayload), &clientPayload); err != nil {
-		return "", fmt.Errorf("invalid login payload: %w", err)
-	}
-	coreUser := clientPayload.User
-
+) (string, uagc.CoreUser, error) {
 	env := msg.OpaqueServerStateEnvelope
-	state, err := ss.VerifyAndDecryptEnvelope(env)
+	sealed, err := ss.VerifyAndDecryptEnvelope(env)
 	if err != nil {
-		return "", fmt.Errorf("envelope decryption failed: %w", err)
+		return "", uagc.CoreUser{}, fmt.Errorf("envelope decryption failed: %w", err)
+	}
+	var state op.LoginServerState
+	if err := json.Unmarshal([]byte(sealed), &state); err != nil {
+		return "", uagc.CoreUser{}, fmt.Errorf("invalid login state: %w", err)
+	}
+	coreUser := state.User
+	if clientPayload.User != coreUser {
+		return "", coreUser, fmt.Errorf("login payload user does not match login step one")
 	}
 
-	sessionKeyB64, err := svc.LoginStep2(msg.OpaqueClientResponse, state)
+	sessionKeyB64, err := svc.LoginStep2(msg.OpaqueClientResponse, state.AkeServerState)
 	if err != nil {
-		return "", fmt.Errorf("opaque login step 2 failed: %w", err)
+		return "", coreUser, fmt.Errorf("%w: %w", errLoginFinish, err)
 	}
 
 	sessionKey, err := base64.RawURLEncoding.DecodeString(sessionKeyB64)
 	if err != nil {
-		return "", fmt.Errorf("session key decode failed: %w", err)
+		return "", coreUser, fmt.Errorf("session key decode failed: %w", err)
 	}
 
 	bindings, err := svc.Store().GetUserGroupsForUser(coreUser)
 	if err != nil {
-		return "", fmt.Errorf("failed to retrieve user group bindings: %w", err)
+		return "", coreUser, fmt.Errorf("failed to retrieve user group bindings: %w", err)
+	}
+
+	groupIDs := make([]string, len(bindings))
+	for i, b := range bindings {
+		groupIDs[i] = b.UserGroupID
+	}
+	sessionID, err := session.Active().Start(sessionKey, coreUser, groupIDs, clientPayload.DeviceIdentifier, clientPayload.DeviceName)
+	if err != nil {
+		return "", coreUser, fmt.Errorf("failed to record session: %w", err)
+	}
+	ticketPayload, err := auth_ticket.SessionPayload(sessionID)
+	if err != nil {
+		return "", coreUser, fmt.Errorf("marshal session payload: %w", err)
 	}
 
 	var entries []op.LoginPerUserGroupEntry
@@ -109,20 +191,20 @@ func handleOpaqueLoginStepTwo(
 			at.AuthTicketPurposeLogin,
 			"",
 			false,
-			nil,
+			ticketPayload,
 		)
 		if err != nil {
-			return "", fmt.Errorf("failed to issue token: %w", err)
+			return "", coreUser, fmt.Errorf("failed to issue token: %w", err)
 		}
 
 		ticketBytes, err := json.Marshal(ticket)
 		if err != nil {
-			return "", fmt.Errorf("marshal auth ticket: %w", err)
+			return "", coreUser, fmt.Errorf("marshal auth ticket: %w", err)
 		}
 
 		encToken, err := ss.EncryptTokenWithSessionKey(sessionKey, string(ticketBytes))
 		if err != nil {
-			return "", fmt.Errorf("token encryption failed: %w", err)
+			return "", coreUser, fmt.Errorf("token encryption failed: %w", err)
 		}
 
 		entries = append(entries, op.LoginPerUserGroupEntry{
@@ -132,17 +214,29 @@ func handleOpaqueLoginStepTwo(
 		})
 	}
 
+	refresh, err := session.Active().IssueRefreshToken(sessionID)
+	if err != nil {
+		return "", coreUser, fmt.Errorf("failed to issue refresh token: %w", err)
+	}
+	encRefresh, err := ss.EncryptTokenWithSessionKey(sessionKey, refresh.Token)
+	if err != nil {
+		return "", coreUser, fmt.Errorf("refresh token encryption failed: %w", err)
+	}
+
 	resp := op.LoginSuccessResponse{
-		Version:        op.LoginSuccessResponseVersion,
-		Success:        true,
-		UserGroupCount: len(entries),
-		UserGroups:     entries,
+		Version:                            op.LoginSuccessResponseVersion,
+		Success:                            true,
+		SessionID:                          sessionID,
+		UserGroupCount:                     len(entries),
+		UserGroups:                         entries,
+		EncryptedRefreshToken:              encRefresh,
+		RefreshTokenExpiresAtUnixTimestamp: refresh.ExpiresAtUnixTimestamp,
 	}
 
 	jsonBytes, err := json.Marshal(resp)
 	if err != nil {
-		return "", fmt.Errorf("marshal login success response failed: %w", err)
+		return "", coreUser, fmt.Errorf("marshal login success response failed: %w", err)
 	}
 
-	return base64.RawURLEncoding.EncodeToString(jsonBytes), nil
+	return base64.RawURLEncoding.EncodeToString(jsonBytes), coreUser, nil
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/config"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/lockout"
+	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	driver "github.com/drlzh/mng-app-user-auth-prot/internal/opaque_test_driver"
+	"github.com/drlzh/mng-app-user-auth-prot/lockout_store"
+	"github.com/drlzh/mng-app-user-auth-prot/opaque_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+	"github.com/stretchr/testify/require"
+)
+
+type loginReply struct {
+	resp                   any
+	status, info, extended string
+}
+
+// login runs both steps through HandleLogin. When the client cannot finish the AKE (wrong
+// password, or a fake record), it sends a KE3 of the right size anyway, as an attacker would.
+func login(t *testing.T, svc *opaque_api.DefaultOpaqueService, lock *lockout.Controller, user uagc.CoreUser, password string) loginReply {
+	payload, err := json.Marshal(op.ClientLoginPayload{User: user})
+	require.NoError(t, err)
+	client := driver.NewClient(t)
+
+	resp, status, _, _ := HandleLogin(svc, lock, "trace-1", op.OpaqueClientReply{
+		CommandType:          op.OpaqueCmdLoginStepOne,
+		OpaqueClientResponse: driver.B64(client.LoginInit([]byte(password)).Serialize()),
+		ClientPayload:        string(payload),
+	})
+	require.Equal(t, "200", status)
+	stepOne := resp.(op.OpaqueServerReply)
+
+	ke3, err := driver.LoginFinish(t, client, user, stepOne.OpaqueServerResponse)
+	if err != nil {
+		ke3 = make([]byte, opaque.DefaultConfiguration().MAC.Size())
+	}
+
+	var r loginReply
+	r.resp, r.status, r.info, r.extended = HandleLogin(svc, lock, "trace-1", op.OpaqueClientReply{
+		CommandType:               op.OpaqueCmdLoginStepTwo,
+		OpaqueServerStateEnvelope: stepOne.OpaqueServerStateEnvelope,
+		OpaqueClientResponse:      driver.B64(ke3),
+		ClientPayload:             string(payload),
+	})
+	return r
+}
+
+func TestLoginFailuresLookAlike(t *testing.T) {
+	svc, err := opaque_api.NewDefaultOpaqueService(opaque_store.NewGhettoAdapter(ghetto_db.New()))
+	require.NoError(t, err)
+	lock := lockout.NewController(lockout_store.NewGhettoAdapter(ghetto_db.New()), config.DefaultConfig().LockoutConfig())
+	known := uagc.CoreUser{TenantID: "dojo-a", UserID: "amanda"}
+	driver.Register(t, svc, known, "correct horse")
+
+	ok := login(t, svc, lock, known, "correct horse")
+	require.Equal(t, "200", ok.status)
+
+	wrong := login(t, svc, lock, known, "battery staple")
+	unknown := login(t, svc, lock, uagc.CoreUser{TenantID: "dojo-a", UserID: "nobody"}, "battery staple")
+
+	require.NotEqual(t, "200", wrong.status)
+	require.Nil(t, wrong.resp)
+	require.Empty(t, wrong.extended)
+	require.Equal(t, wrong, unknown)
+}
@@ -4,25 +4,37 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant"
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
 	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	"github.com/drlzh/mng-app-user-auth-prot/grant_store"
 )
 
 func HandlePasswordReset(
 	svc *opaque_api.DefaultOpaqueService,
+	ledger grant_store.AuthGrantLedger,
 	req op.OpaqueClientReply,
 ) (any, string, string, string) {
-	var payload op.ClientLoginPayload
+	var payload op.ClientPasswordResetPayload
 	if err := json.Unmarshal([]byte(req.ClientPayload), &payload); err != nil {
 		return nil, "400", "Invalid client payload", err.Error()
 	}
 
+	// Without this anyone who solves a PoW could overwrite any user's OPAQUE record
+	if _, err := auth_grant.VerifyAuthGrantForUser(&payload.AuthGrant, ag.AuthGrantPurposePasswordReset, payload.User); err != nil {
+		return nil, "403", "AuthGrant verification failed", err.Error()
+	}
+	if err := auth_grant.CheckAuthGrantUnspent(ledger, &payload.AuthGrant); err != nil {
+		return nil, "403", "AuthGrant verification failed", err.Error()
+	}
+
 	switch req.CommandType {
 	case op.OpaqueCmdPasswordResetStepOne:
 		return handleResetStep1(svc, req, payload)
 
 	case op.OpaqueCmdPasswordResetStepTwo:
-		return handleResetStep2(svc, req, payload)
+		return handleResetStep2(svc, ledger, req, payload)
 
 	default:
 		return nil, "400", "Unsupported password reset command", req.CommandType
@@ -32,7 +44,7 @@ func HandlePasswordReset(
 func handleResetStep1(
 	svc *opaque_api.DefaultOpaqueService,
 	req op.OpaqueClientReply,
-	payload op.ClientLoginPayload,
+	payload op.ClientPasswordResetPayload,
 ) (any, string, string, string) {
 	respB64, err := svc.PasswordResetStep1(payload.User, req.OpaqueClientResponse)
 	if err != nil {
@@ -47,10 +59,18 @@ func handleResetStep1(
 
 func handleResetStep2(
 	svc *opaque_api.DefaultOpaqueService,
+	ledger grant_store.AuthGrantLedger,
 	req op.OpaqueClientReply,
-	payload op.ClientLoginPayload,
+	payload op.ClientPasswordResetPayload,
 ) (any, string, string, string) {
+	// Burn the grant first so two resets with one link cannot both write; hand it back
+	// if the new record is not stored, so the link can be retried
+	if err := auth_grant.ConsumeAuthGrant(ledger, &payload.AuthGrant); err != nil {
+		return nil, "403", "AuthGrant verification failed", err.Error()
+	}
+
 	if err := svc.PasswordResetStep2(payload.User, req.OpaqueClientResponse); err != nil {
+		auth_grant.ReleaseAuthGrant(ledger, &payload.AuthGrant)
 		return nil, "400", "Password reset Step 2 failed", err.Error()
 	}
 
@@ -2,24 +2,29 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant"
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
 	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	"github.com/drlzh/mng-app-user-auth-prot/grant_store"
 	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 )
 
 func HandleRegister(
 	svc *opaque_api.DefaultOpaqueService,
+	ledger grant_store.AuthGrantLedger,
 	req op.OpaqueClientReply,
 ) (any, string, string, string) {
 
 	switch req.CommandType {
 	case op.OpaqueCmdRegisterStepOne:
-		return handleRegisterStepOne(svc, req)
+		return handleRegisterStepOne(svc, ledger, req)
 
 	case op.OpaqueCmdRegisterStepTwo:
-		return handleRegisterStepTwo(svc, req)
+		return handleRegisterStepTwo(svc, ledger, req)
 
 	default:
 		return nil, "400", "Unknown registration command", req.CommandType
@@ -28,6 +33,7 @@ func HandleRegister(
 
 func handleRegisterStepOne(
 	svc *opaque_api.DefaultOpaqueService,
+	ledger grant_store.AuthGrantLedger,
 	reg op.OpaqueClientReply,
 ) (any, string, string, string) {
 	var clientPayload op.ClientRegistrationPayload
@@ -35,6 +41,13 @@ func handleRegisterStepOne(
 		return nil, "400", "Invalid client payload", err.Error()
 	}
 
+	if _, err := auth_grant.VerifyAuthGrantForUser(&clientPayload.AuthGrant, ag.AuthGrantPurposeRegister, clientPayload.User); err != nil {
+		return nil, "403", "AuthGrant verification failed", err.Error()
+	}
+	if err := auth_grant.CheckAuthGrantUnspent(ledger, &clientPayload.AuthGrant); err != nil {
+		return nil, "403", "AuthGrant verification failed", err.Error()
+	}
+
 	respB64, err := svc.RegistrationStep1(clientPayload.User, reg.OpaqueClientResponse)
 	if err != nil {
 		return nil, "400", "OPAQUE step one failed", err.Error()
@@ -48,6 +61,7 @@ func handleRegisterStepOne(
 
 func handleRegisterStepTwo(
 	svc *opaque_api.DefaultOpaqueService,
+	ledger grant_store.AuthGrantLedger,
 	reg op.OpaqueClientReply,
 ) (any, string, string, string) {
 	var clientPayload op.ClientRegistrationPayload
@@ -55,19 +69,33 @@ func handleRegisterStepTwo(
 		return nil, "400", "Invalid client payload", err.Error()
 	}
 
-	// Step 2: Store OPAQUE record by CoreUser
-	if err := svc.RegistrationStep2(clientPayload.User, reg.OpaqueClientResponse); err != nil {
-		return nil, "500", "OPAQUE step two failed", err.Error()
+	subject, err := auth_grant.VerifyAuthGrantForUser(&clientPayload.AuthGrant, ag.AuthGrantPurposeRegister, clientPayload.User)
+	if err != nil {
+		return nil, "403", "AuthGrant verification failed", err.Error()
 	}
 
-	// Step 3: Store composite roles (optional)
-	if len(clientPayload.NewGroups) > 0 {
-		if err := uagc.ValidateAllRolesMatchCore(clientPayload.User, clientPayload.NewGroups); err != nil {
-			return nil, "400", "Invalid role bindings", err.Error()
-		}
-		if err := svc.Store().UpdateRoles(clientPayload.User, clientPayload.NewGroups); err != nil {
-			return nil, "500", "Failed to store user roles", err.Error()
-		}
+	// Roles come from the grant; the client may only narrow them down
+	if len(clientPayload.NewGroups) == 0 {
+		clientPayload.NewGroups = subject.UserGroups
+	}
+	if err := requireGroupsCoveredByGrant(clientPayload.NewGroups, subject); err != nil {
+		return nil, "403", "Invalid role bindings", err.Error()
+	}
+
+	if err := uagc.ValidateAllRolesMatchCore(clientPayload.User, clientPayload.NewGroups); err != nil {
+		return nil, "400", "Invalid role bindings", err.Error()
+	}
+
+	// Burn the grant before writing anything, so a replayed link cannot race us;
+	// hand it back if the user is not stored, so the link can be retried
+	if err := auth_grant.ConsumeAuthGrant(ledger, &clientPayload.AuthGrant); err != nil {
+		return nil, "403", "AuthGrant verification failed", err.Error()
+	}
+
+	// Step 2: Store the OPAQUE record and its roles by CoreUser, in one write
+	if err := svc.RegistrationStep2(clientPayload.User, reg.OpaqueClientResponse, clientPayload.NewGroups); err != nil {
+		auth_grant.ReleaseAuthGrant(ledger, &clientPayload.AuthGrant)
+		return nil, "500", "OPAQUE step two failed", err.Error()
 	}
 
 	ack := op.ServerOpaqueRegistrationSuccessAcknowledgementPayload{
@@ -84,3 +112,16 @@ func handleRegisterStepTwo(
 		ServerPayload: string(payloadBytes),
 	}, "200", "OPAQUE registration complete", ""
 }
+
+func requireGroupsCoveredByGrant(groups []uagc.UserGroupBinding, subject *ag.AuthGrantSubject) error {
+	allowed := make(map[string]struct{}, len(subject.UserGroups))
+	for _, g := range subject.UserGroups {
+		allowed[g.EncodeKey()] = struct{}{}
+	}
+	for _, g := range groups {
+		if _, ok := allowed[g.EncodeKey()]; !ok {
+			return fmt.Errorf("user group %s not covered by auth grant", g.UserGroupID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant"
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	"github.com/drlzh/mng-app-user-auth-prot/grant_store"
+	driver "github.com/drlzh/mng-app-user-auth-prot/internal/opaque_test_driver"
+	"github.com/drlzh/mng-app-user-auth-prot/opaque_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails the next SaveRaw, as a database outage mid-registration would, and
+// every UpdateRoles, since registration must not depend on a second write.
+type failingStore struct {
+	opaque_store.OpaqueClientStore
+	failNext bool
+}
+
+func (s *failingStore) SaveRaw(user uagc.CoreUser, data []byte) error {
+	if s.failNext {
+		s.failNext = false
+		return errors.New("injected write failure")
+	}
+	return s.OpaqueClientStore.SaveRaw(user, data)
+}
+
+func (s *failingStore) UpdateRoles(uagc.CoreUser, []uagc.UserGroupBinding) error {
+	return errors.New("injected role write failure")
+}
+
+func TestRegisterFailureLeavesNoHalfAccount(t *testing.T) {
+	store := &failingStore{OpaqueClientStore: opaque_store.NewGhettoAdapter(ghetto_db.New())}
+	svc, err := opaque_api.NewDefaultOpaqueService(store)
+	require.NoError(t, err)
+	ledger := grant_store.NewGhettoAdapter(ghetto_db.New())
+
+	user := uagc.CoreUser{TenantID: "dojo-a", UserID: "new-student"}
+	groups := []uagc.UserGroupBinding{{CoreUser: user, UserGroupID: uagc.UserGroupChild}}
+	subject, err := json.Marshal(ag.AuthGrantSubject{TargetUser: user, UserGroups: groups})
+	require.NoError(t, err)
+	grant, err := auth_grant.CreateAuthGrant("grant-1", ag.AuthGrantPurposeRegister, "", "", subject, time.Minute)
+	require.NoError(t, err)
+
+	stepTwo := func() string {
+		payload, err := json.Marshal(op.ClientRegistrationPayload{User: user, AuthGrant: *grant})
+		require.NoError(t, err)
+		_, status, _, _ := HandleRegister(svc, ledger, op.OpaqueClientReply{
+			CommandType:          op.OpaqueCmdRegisterStepTwo,
+			OpaqueClientResponse: driver.RegistrationRecord(t, svc, user, "correct horse"),
+			ClientPayload:        string(payload),
+		})
+		return status
+	}
+
+	store.failNext = true
+	require.Equal(t, "500", stepTwo())
+	exists, err := store.Exists(user)
+	require.NoError(t, err)
+	require.False(t, exists)
+	require.NoError(t, auth_grant.CheckAuthGrantUnspent(ledger, grant))
+
+	// The same link works once the store is back, and the user gets the granted roles
+	require.Equal(t, "200", stepTwo())
+	bound, err := store.GetUserGroupsForUser(user)
+	require.NoError(t, err)
+	require.Equal(t, groups, bound)
+	require.ErrorIs(t, auth_grant.CheckAuthGrantUnspent(ledger, grant), grant_store.ErrGrantAlreadyConsumed)
+}
@@ -0,0 +1,23 @@
+package secure_state
+
+import (
+	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+)
+
+// CanonicalServerStateBytes returns the bytes an OpaqueServerState signature covers:
+// every field but Signature, encoded per utils/canonical in this order:
+//
+//	version, step, ake_server_state, unix_timestamp (int), nonce, signature_algorithm
+//
+// See testdata/canonical_vectors.json.
+func CanonicalServerStateBytes(s op.OpaqueServerState) ([]byte, error) {
+	return canonical.NewEncoder("OpaqueServerState").
+		String(s.Version).
+		String(s.Step).
+		String(s.AkeServerState).
+		Int64(s.UnixTimestamp).
+		String(s.Nonce).
+		String(s.SignatureAlgorithm).
+		Bytes()
+}
@@ -0,0 +1,44 @@
+package secure_state
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	op "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/canonical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalVectors(t *testing.T) {
+	f, err := canonical.ReadVectorFile("testdata/canonical_vectors.json")
+	require.NoError(t, err)
+	require.Equal(t, sigctx.OpaqueState, f.SigningContext)
+	pub, err := hex.DecodeString(f.PublicKeyHex)
+	require.NoError(t, err)
+
+	for _, v := range f.Vectors {
+		var state op.OpaqueServerState
+		require.NoError(t, json.Unmarshal(v.Object, &state), v.Name)
+
+		got, err := CanonicalServerStateBytes(state)
+		require.NoError(t, err, v.Name)
+		require.Equal(t, v.CanonicalHex, hex.EncodeToString(got), v.Name)
+
+		sig, err := base64.RawURLEncoding.DecodeString(state.Signature)
+		require.NoError(t, err, v.Name)
+		require.True(t, ed448_api.VerifyWithContext(ed448_api.PublicKey(pub), got, sig, f.SigningContext), v.Name)
+	}
+}
+
+func TestOpaqueStateEnvelopeRoundTrip(t *testing.T) {
+	env, err := CreateOpaqueStateEnvelope(op.OpaqueCmdLoginStepOne, "YWtlLXN0YXRl")
+	require.NoError(t, err)
+
+	ake, err := VerifyAndDecryptEnvelope(env)
+	require.NoError(t, err)
+	require.Equal(t, "YWtlLXN0YXRl", ake)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/encryption/chacha_poly1305/chacha_poly1305_api"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/encryption/rsa/rsa_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
 	"github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 )
 
@@ -41,13 +42,13 @@ func CreateOpaqueStateEnvelope(step string, akeStateB64 string) (op.OpaqueServer
 		SignatureAlgorithm: SignatureAlgorithm,
 	}
 
-	// Sign the serialized state
-	stateBytes, err := json.Marshal(state)
+	// Sign the canonical encoding of the state
+	stateBytes, err := CanonicalServerStateBytes(state)
 	if err != nil {
 		return op.OpaqueServerStateEnvelope{}, err
 	}
 
-	sig, err := ed448_api.Sign(user_auth_global_config.Ed448PersephonePrivateKey(), stateBytes)
+	sig, err := ed448_api.SignWithContext(user_auth_global_config.Ed448OpaquePrivateKey(), stateBytes, sigctx.OpaqueState)
 	if err != nil {
 		return op.OpaqueServerStateEnvelope{}, err
 	}
@@ -82,7 +83,7 @@ func CreateOpaqueStateEnvelope(step string, akeStateB64 string) (op.OpaqueServer
 	}
 
 	// Sign symmetric key using Ed448
-	sigKey, err := ed448_api.Sign(user_auth_global_config.Ed448PersephonePrivateKey(), symmetricKey)
+	sigKey, err := ed448_api.SignWithContext(user_auth_global_config.Ed448OpaquePrivateKey(), symmetricKey, sigctx.OpaqueEnvelopeKey)
 	if err != nil {
 		return op.OpaqueServerStateEnvelope{}, err
 	}
@@ -119,7 +120,7 @@ func VerifyAndDecryptEnvelope(env op.OpaqueServerStateEnvelope) (string, error)
 		return "", errors.New("RSA decryption of symmetric key failed")
 	}
 
-	if !ed448_api.Verify(sigKey, symmetricKey, user_auth_global_config.Ed448PersephonePublicKey()) {
+	if !ed448_api.VerifyWithContext(user_auth_global_config.Ed448OpaquePublicKey(), symmetricKey, sigKey, sigctx.OpaqueEnvelopeKey) {
 		return "", errors.New("Ed448 signature on symmetric key verification failed")
 	}
 
@@ -149,15 +150,12 @@ func VerifyAndDecryptEnvelope(env op.OpaqueServerStateEnvelope) (string, error)
 	
 	sig := ed448_api.Signature(sigBytes)
 
-	// Remove signature before verification
-	stateCopy := state
-	stateCopy.Signature = ""
-	msgBytes, err := json.Marshal(stateCopy)
+	msgBytes, err := CanonicalServerStateBytes(state)
 	if err != nil {
-		return "", errors.New("failed to re-marshal server state for signature verification")
+		return "", errors.New("failed to encode server state for signature verification")
 	}
 
-	if !ed448_api.Verify(sig, msgBytes, user_auth_global_config.Ed448PersephonePublicKey()) {
+	if !ed448_api.VerifyWithContext(user_auth_global_config.Ed448OpaquePublicKey(), msgBytes, sig, sigctx.OpaqueState) {
 		return "", errors.New("signature on server state verification failed")
 	}
 
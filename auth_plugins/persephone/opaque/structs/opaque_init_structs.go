@@ -1,6 +1,9 @@
 package structs
 
-import "time"
+import (
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"time"
+)
 
 const (
 	OpaqueCmdInitiateStepOne   = "OPAQUE_INIT_STEP_ONE"
@@ -9,6 +12,14 @@ const (
 	OpaqueCmdInitiateStepFour  = "OPAQUE_INIT_STEP_FOUR"
 )
 
+// OPAQUE flows a PoW challenge can be issued for; the intent is bound into the
+// signed challenge together with the PSP trace ID
+const (
+	OpaqueIntentLogin         = "LOGIN"
+	OpaqueIntentRegister      = "REGISTER"
+	OpaqueIntentPasswordReset = "RESET"
+)
+
 const (
 	DefaultPoWDifficulty = 20
 	OpaquePoWTTL         = 5 * time.Minute
@@ -21,17 +32,21 @@ type OpaqueInit struct {
 }
 
 type ClientOpaqueInitStepOnePayload struct {
-	UnixTimestamp int64 `json:"unix_timestamp"`
+	UnixTimestamp int64         `json:"unix_timestamp"`
+	User          uagc.CoreUser `json:"user"`   // Optional hint for per-user PoW difficulty
+	Intent        string        `json:"intent"` // One of OpaqueIntent*
 }
 
 type ServerOpaqueInitStepTwoPayload struct {
 	UnixTimestamp int64  `json:"unix_timestamp"`
 	PoWChallenge  string `json:"pow_challenge"`
+	PoWScheme     string `json:"pow_scheme"` // pow_api.Scheme*, tells the client which solver to run
 }
 
 type ClientOpaqueInitStepThreePayload struct {
 	UnixTimestamp int64  `json:"unix_timestamp"`
 	PoWSolution   string `json:"pow_solution"`
+	Intent        string `json:"intent"` // Same intent as requested in step one
 }
 
 type ServerOpaqueInitStepFourPayload struct {
@@ -49,8 +49,18 @@ type OpaqueServerStateEnvelope struct {
 	EncryptedOpaqueServerState string           `json:"encrypted_opaque_server_state"`
 }
 
+// LoginServerState is what login step one seals into its OpaqueServerStateEnvelope. The
+// CoreUser whose record the AKE ran against travels with the state, so step two issues
+// tickets for that user and not whoever the step two payload names.
+type LoginServerState struct {
+	User           uagc.CoreUser `json:"user"`
+	AkeServerState string        `json:"ake_server_state"`
+}
+
 type ClientLoginPayload struct {
-	User uagc.CoreUser `json:"user"`
+	User             uagc.CoreUser `json:"user"`
+	DeviceIdentifier string        `json:"device_identifier,omitempty"` // Shown in the session list
+	DeviceName       string        `json:"device_name,omitempty"`       // e.g. "Pixel 8"; display only
 }
 
 type LoginPerUserGroupEntry struct {
@@ -62,6 +72,11 @@ type LoginPerUserGroupEntry struct {
 type LoginSuccessResponse struct {
 	Version        string                   `json:"version"`
 	Success        bool                     `json:"success"`
+	SessionID      string                   `json:"session_id"` // Also in every ticket's payload
 	UserGroupCount int                      `json:"user_group_count"`
 	UserGroups     []LoginPerUserGroupEntry `json:"user_groups"`
+
+	// Single-use; trade it on PSP_SESSION_REFRESH for new tickets and its successor
+	EncryptedRefreshToken              string `json:"encrypted_refresh_token"`
+	RefreshTokenExpiresAtUnixTimestamp int64  `json:"refresh_token_expires_at_unix_timestamp"`
 }
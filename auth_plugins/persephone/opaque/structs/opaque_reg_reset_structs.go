@@ -1,6 +1,9 @@
 package structs
 
-import uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+import (
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
 
 const (
 	OpaqueCmdRegisterStepOne      = "OPAQUE_REGISTER_STEP_ONE"
@@ -16,5 +19,11 @@ type ServerOpaqueRegistrationSuccessAcknowledgementPayload struct {
 
 type ClientRegistrationPayload struct {
 	User      uagc.CoreUser           `json:"user"`
-	NewGroups []uagc.UserGroupBinding `json:"new_groups,omitempty"` // optional
+	NewGroups []uagc.UserGroupBinding `json:"new_groups,omitempty"` // optional, must be covered by AuthGrant
+	AuthGrant ag.AuthGrant            `json:"auth_grant"`           // AuthGrantPurposeRegister
+}
+
+type ClientPasswordResetPayload struct {
+	User      uagc.CoreUser `json:"user"`
+	AuthGrant ag.AuthGrant  `json:"auth_grant"` // AuthGrantPurposePasswordReset
 }
@@ -2,10 +2,22 @@ package persephone
 
 import (
 	"errors"
+	"fmt"
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
 	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/config"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/lockout"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/pow_control"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	"github.com/drlzh/mng-app-user-auth-prot/delegation_store"
+	"github.com/drlzh/mng-app-user-auth-prot/grant_store"
 	"github.com/drlzh/mng-app-user-auth-prot/internal/context"
+	"github.com/drlzh/mng-app-user-auth-prot/lockout_store"
 	"github.com/drlzh/mng-app-user-auth-prot/opaque_store"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	"github.com/drlzh/mng-app-user-auth-prot/pow_store"
+	"github.com/drlzh/mng-app-user-auth-prot/session_store"
 	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
 )
 
@@ -15,8 +27,13 @@ import (
 // (so that we don't need IT security staff 24x7)
 
 type PersephoneHandler struct {
-	svc  *opaque_api.DefaultOpaqueService
-	conf *config.Config
+	svc         *opaque_api.DefaultOpaqueService
+	ledger      grant_store.AuthGrantLedger
+	seen        pow_store.SeenNonceStore
+	pow         *pow_control.DifficultyController
+	lockout     *lockout.Controller
+	delegations *delegation.Service
+	conf        *config.Config
 }
 
 func NewPersephoneHandler() *PersephoneHandler {
@@ -48,14 +65,58 @@ func (h *PersephoneHandler) Init(ctx *context.AppContext) error {
 	if h.conf == nil {
 		h.conf = config.DefaultConfig()
 	}
+	if err := h.conf.Validate(); err != nil {
+		return fmt.Errorf("persephone config: %w", err)
+	}
 
 	var store opaque_store.OpaqueClientStore
+	var ledger grant_store.AuthGrantLedger
+	var seen pow_store.SeenNonceStore
+	var delegations delegation_store.DelegationStore
+	var audit audit_log.AuditStore
+	var sessions session_store.SessionStore
+	var failures lockout_store.LockoutStore
 	if ctx.DB != nil {
 		store = opaque_store.NewPgAdapter(ctx.DB)
+		ledger = grant_store.NewPgAdapter(ctx.DB)
+		seen = pow_store.NewPgAdapter(ctx.DB) // shared across instances
+		delegations = delegation_store.NewPgAdapter(ctx.DB)
+		audit = audit_log.NewPgAdapter(ctx.DB)
+		sessions = session_store.NewPgAdapter(ctx.DB)
+		failures = lockout_store.NewPgAdapter(ctx.DB)
 	} else {
-		store = opaque_store.NewGhettoAdapter(ghetto_db.New())
+		db := ghetto_db.New()
+		store = opaque_store.NewGhettoAdapter(db)
+		ledger = grant_store.NewGhettoAdapter(db)
+		seen = pow_store.NewMemoryAdapter()
+		delegations = delegation_store.NewMemoryAdapter()
+		audit = audit_log.NewMemoryAdapter()
+		sessions = session_store.NewMemoryAdapter()
+		failures = lockout_store.NewGhettoAdapter(db)
+	}
+	svc, err := opaque_api.NewDefaultOpaqueService(store)
+	if err != nil {
+		return err
 	}
-	h.svc = opaque_api.NewDefaultOpaqueService(store)
+	h.svc = svc
+	h.ledger = ledger
+	h.seen = seen
+	h.delegations = delegation.NewService(delegations)
+
+	// Delegated entitlements count wherever policy.Authorize is used
+	policy.Install(policy.Active().WithDelegations(h.delegations))
+	audit_log.Install(audit_log.NewLogger(audit))
+	session.Install(session.NewRegistry(sessions))
+
+	h.lockout = lockout.NewController(failures, h.conf.LockoutConfig())
+	minBits, maxBits := h.conf.PoWDifficultyBounds()
+	h.pow = pow_control.NewDifficultyController(pow_control.Config{
+		MinDifficulty:  minBits,
+		MaxDifficulty:  maxBits,
+		HalfLife:       h.conf.PoWHalfLife,
+		RequestsPerBit: h.conf.PoWRequestsPerBit,
+		FailuresPerBit: h.conf.PoWFailuresPerBit,
+	})
 
 	return nil
 }
@@ -66,5 +127,10 @@ func (h *PersephoneHandler) Routes() []string {
 }
 
 func (h *PersephoneHandler) HandleRequest(path string, payloadIn string, statusIn, infoIn, extendedIn string) (payloadOut any, statusOut, infoOut, extendedOut string) {
-	return Dispatch(payloadIn, statusIn, infoIn, extendedIn, h.svc, h.conf)
+	return h.Dispatch(payloadIn, statusIn, infoIn, extendedIn, "")
+}
+
+// HandleRequestFrom implements auth_service_registry.RemoteAwareHandler (per-IP PoW difficulty).
+func (h *PersephoneHandler) HandleRequestFrom(remoteAddr string, path string, payloadIn string, statusIn, infoIn, extendedIn string) (payloadOut any, statusOut, infoOut, extendedOut string) {
+	return h.Dispatch(payloadIn, statusIn, infoIn, extendedIn, remoteAddr)
 }
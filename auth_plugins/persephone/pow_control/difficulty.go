@@ -0,0 +1,197 @@
+package pow_control
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_impl"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_api"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// DifficultyGrace tolerates difficulty rising by this many bits while the client was solving
+const DifficultyGrace = 1
+
+// Config bounds the adaptive PoW difficulty.
+// Each doubling of the decayed request rate (in units of RequestsPerBit) or failure
+// count (in units of FailuresPerBit) on any key adds one bit, i.e. doubles client work.
+type Config struct {
+	MinDifficulty  int
+	MaxDifficulty  int
+	HalfLife       time.Duration // How fast counters cool down when things are quiet
+	RequestsPerBit float64
+	FailuresPerBit float64
+}
+
+type counter struct {
+	requests float64
+	failures float64
+	updated  time.Time
+}
+
+// DifficultyController tracks recent PoW requests and failed logins per key
+// (tenant, IP, user) and derives the difficulty to hand out for a new challenge.
+type DifficultyController struct {
+	conf Config
+
+	mu       sync.Mutex
+	counters map[string]*counter
+	swept    time.Time
+}
+
+func NewDifficultyController(conf Config) *DifficultyController {
+	if conf.MaxDifficulty > hashcash_impl.MaxDifficulty {
+		conf.MaxDifficulty = hashcash_impl.MaxDifficulty
+	}
+	if conf.MinDifficulty < 1 {
+		conf.MinDifficulty = 1
+	}
+	if conf.MaxDifficulty < conf.MinDifficulty {
+		conf.MaxDifficulty = conf.MinDifficulty
+	}
+	if conf.HalfLife <= 0 {
+		conf.HalfLife = time.Minute
+	}
+	return &DifficultyController{
+		conf:     conf,
+		counters: make(map[string]*counter),
+		swept:    time.Now(),
+	}
+}
+
+// ─── Keys ──────────────────────────────────────────────────────
+
+func IPKey(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	return "ip:" + addr
+}
+
+func TenantKey(tenantID string) string {
+	if tenantID == "" {
+		return ""
+	}
+	return "tenant:" + tenantID
+}
+
+func UserKey(user uagc.CoreUser) string {
+	if user.TenantID == "" && user.UserID == "" {
+		return ""
+	}
+	return "user:" + user.EncodeKey()
+}
+
+// ─── Recording ──────────────────────────────────────────────────────
+
+// RecordRequest counts one PoW challenge issuance against every non-empty key.
+func (c *DifficultyController) RecordRequest(keys ...string) {
+	c.record(keys, 1, 0)
+}
+
+// RecordFailure counts one failed authentication attempt against every non-empty key.
+func (c *DifficultyController) RecordFailure(keys ...string) {
+	c.record(keys, 0, 1)
+}
+
+func (c *DifficultyController) record(keys []string, req, fail float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweepLocked(now)
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		ctr, ok := c.counters[k]
+		if !ok {
+			ctr = &counter{updated: now}
+			c.counters[k] = ctr
+		}
+		c.decayLocked(ctr, now)
+		ctr.requests += req
+		ctr.failures += fail
+	}
+}
+
+// ─── Difficulty ──────────────────────────────────────────────────────
+
+// Difficulty returns the difficulty for a challenge concerning all given keys;
+// the hottest key wins.
+func (c *DifficultyController) Difficulty(keys ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	extra := 0
+	for _, k := range keys {
+		ctr, ok := c.counters[k]
+		if !ok {
+			continue
+		}
+		c.decayLocked(ctr, now)
+		if bits := c.extraBits(ctr); bits > extra {
+			extra = bits
+		}
+	}
+
+	d := c.conf.MinDifficulty + extra
+	if d > c.conf.MaxDifficulty {
+		d = c.conf.MaxDifficulty
+	}
+	return d
+}
+
+func (c *DifficultyController) extraBits(ctr *counter) int {
+	bits := 0
+	if c.conf.RequestsPerBit > 0 {
+		bits += int(math.Log2(1 + ctr.requests/c.conf.RequestsPerBit))
+	}
+	if c.conf.FailuresPerBit > 0 {
+		bits += int(math.Log2(1 + ctr.failures/c.conf.FailuresPerBit))
+	}
+	return bits
+}
+
+func (c *DifficultyController) decayLocked(ctr *counter, now time.Time) {
+	dt := now.Sub(ctr.updated)
+	if dt <= 0 {
+		return
+	}
+	f := math.Exp2(-dt.Seconds() / c.conf.HalfLife.Seconds())
+	ctr.requests *= f
+	ctr.failures *= f
+	ctr.updated = now
+}
+
+// sweepLocked drops counters that have cooled down to nothing, so the map
+// does not grow with every IP that ever talked to us.
+func (c *DifficultyController) sweepLocked(now time.Time) {
+	if now.Sub(c.swept) < c.conf.HalfLife {
+		return
+	}
+	for k, ctr := range c.counters {
+		c.decayLocked(ctr, now)
+		if ctr.requests < 0.01 && ctr.failures < 0.01 {
+			delete(c.counters, k)
+		}
+	}
+	c.swept = now
+}
+
+// RequireDifficulty checks a solved token against the difficulty now due for keys, less
+// DifficultyGrace. A challenge may have been issued before the counters rose, or without
+// the user hint the request now names, so the difficulty is re-checked when it is spent.
+func (c *DifficultyController) RequireDifficulty(scheme, token string, keys ...string) error {
+	got, err := pow_api.TokenDifficulty(scheme, token)
+	if err != nil {
+		return err
+	}
+	if required := c.Difficulty(keys...) - DifficultyGrace; got < required {
+		return fmt.Errorf("difficulty %d below required %d, request a new challenge", got, required)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package pow_control
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_impl"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_api"
+)
+
+func TestDifficultyRisesAndDecays(t *testing.T) {
+	c := NewDifficultyController(Config{
+		MinDifficulty:  10,
+		MaxDifficulty:  20,
+		HalfLife:       time.Minute,
+		RequestsPerBit: 10,
+		FailuresPerBit: 2,
+	})
+	ip := IPKey("203.0.113.7")
+	quiet := IPKey("198.51.100.1")
+
+	if d := c.Difficulty(ip); d != 10 {
+		t.Fatalf("expected base difficulty 10, got %d", d)
+	}
+
+	for i := 0; i < 30; i++ {
+		c.RecordFailure(ip)
+	}
+	hot := c.Difficulty(ip)
+	if hot <= 10 {
+		t.Fatalf("expected difficulty to rise after failures, got %d", hot)
+	}
+	if d := c.Difficulty(quiet, ""); d != 10 {
+		t.Errorf("unrelated key should stay at base, got %d", d)
+	}
+	if d := c.Difficulty(quiet, ip); d != hot {
+		t.Errorf("hottest key should win, got %d want %d", d, hot)
+	}
+
+	// Pretend ten half-lives passed
+	c.counters[ip].updated = time.Now().Add(-10 * time.Minute)
+	if d := c.Difficulty(ip); d != 10 {
+		t.Errorf("expected difficulty to decay back to base, got %d", d)
+	}
+}
+
+func TestDifficultyClampedToMax(t *testing.T) {
+	c := NewDifficultyController(Config{
+		MinDifficulty:  10,
+		MaxDifficulty:  100,
+		HalfLife:       time.Minute,
+		FailuresPerBit: 1,
+	})
+	for i := 0; i < 1<<18; i++ {
+		c.RecordFailure("k")
+	}
+	if d := c.Difficulty("k"); d != hashcash_impl.MaxDifficulty {
+		t.Errorf("expected clamp to hashcash max, got %d", d)
+	}
+}
+
+func TestRequireDifficulty(t *testing.T) {
+	c := NewDifficultyController(Config{
+		MinDifficulty:  4,
+		MaxDifficulty:  20,
+		HalfLife:       time.Minute,
+		FailuresPerBit: 1,
+	})
+	ip := IPKey("203.0.113.7")
+
+	chal, err := pow_api.CreateChallenge(pow_api.Config{Subject: "s", Difficulty: 4, TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := hashcash_api.SolveChallenge(chal.Token, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RequireDifficulty(pow_api.SchemeHashcash, token, ip); err != nil {
+		t.Fatalf("expected token at base difficulty to pass, got %v", err)
+	}
+
+	// Failures since the challenge was issued push the key past the grace
+	for i := 0; i < 30; i++ {
+		c.RecordFailure(ip)
+	}
+	if err := c.RequireDifficulty(pow_api.SchemeHashcash, token, ip); err == nil {
+		t.Errorf("expected token to be refused once difficulty rose")
+	}
+}
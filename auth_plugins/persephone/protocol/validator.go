@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
 	"github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 )
 
@@ -18,7 +19,7 @@ func GenerateTraceID() (string, error) {
 
 func SignTraceID(traceID string) (string, error) {
 	priv := user_auth_global_config.Ed448PersephonePrivateKey()
-	sig, err := ed448_api.Sign(priv, []byte(traceID))
+	sig, err := ed448_api.SignWithContext(priv, []byte(traceID), sigctx.TraceID)
 	if err != nil {
 		return "", err
 	}
@@ -34,7 +35,7 @@ func VerifyTraceID(traceID, signature string) error {
 
 	sig := ed448_api.Signature(sigBytes)
 
-	if !ed448_api.Verify(sig, []byte(traceID), pub) {
+	if !ed448_api.VerifyWithContext(pub, []byte(traceID), sig, sigctx.TraceID) {
 		return errors.New("signature verification failed")
 	}
 	return nil
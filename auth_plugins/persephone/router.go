@@ -1,18 +1,22 @@
 package persephone
 
 import (
-	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/config"
+	agh "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/handlers"
+	bth "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/biscuit_token/handlers"
+	dh "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/delegation/handlers"
+	hh "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/hydrate/handlers"
+	lh "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/lockout/handlers"
 	handlers "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/opaque/handlers"
 	proto "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/protocol"
+	sh "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session/handlers"
 	psp "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/structs"
-	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
 )
 
-func Dispatch(
+// Dispatch routes one PSP request. remoteAddr is the caller's IP ("" if unknown).
+func (h *PersephoneHandler) Dispatch(
 	raw string,
 	statusIn, infoIn, extendedIn string,
-	svc *opaque_api.DefaultOpaqueService,
-	conf *config.Config,
+	remoteAddr string,
 ) (payloadOut any, statusOut, infoOut, extendedOut string) {
 	cmd, payload, traceID, signature, err := UnwrapFromPersephoneRequest(raw)
 	if err != nil {
@@ -33,11 +37,55 @@ func Dispatch(
 	// Route commands
 	switch cmd {
 	case psp.PspCmdOpaqueInitiateOpaque:
-		inner, status, info, extended := handlers.HandleOpaqueInit(payload, traceID, conf)
+		inner, status, info, extended := handlers.HandleOpaqueInit(payload, traceID, remoteAddr, h.pow, h.conf)
 		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
 
 	case psp.PspCmdOpaqueExecute:
-		inner, status, info, extended := handlers.DispatchOpaque(payload, traceID, svc)
+		inner, status, info, extended := handlers.DispatchOpaque(payload, traceID, remoteAddr, h.svc, h.ledger, h.seen, h.pow, h.lockout, h.conf)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdHydrateInitiateHydrate:
+		inner, status, info, extended := hh.HandleHydrateInit(payload, traceID, remoteAddr, h.pow, h.conf)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdHydrateExecute:
+		inner, status, info, extended := hh.DispatchHydrate(payload, traceID, remoteAddr, h.svc, h.seen, h.pow, h.conf)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdAuthGrantIssue:
+		inner, status, info, extended := agh.HandleAuthGrantIssue(payload, traceID, h.svc)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdAuthGrantRevoke:
+		inner, status, info, extended := agh.HandleAuthGrantRevoke(payload, traceID, h.svc, h.ledger)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdBiscuitExchange:
+		inner, status, info, extended := bth.HandleBiscuitExchange(payload, traceID)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdDelegationIssue:
+		inner, status, info, extended := dh.HandleDelegationIssue(payload, traceID, h.delegations)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdDelegationRevoke:
+		inner, status, info, extended := dh.HandleDelegationRevoke(payload, traceID, h.delegations)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdSessionList:
+		inner, status, info, extended := sh.HandleSessionList(payload, traceID)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdSessionRevoke:
+		inner, status, info, extended := sh.HandleSessionRevoke(payload, traceID)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdSessionRefresh:
+		inner, status, info, extended := sh.HandleSessionRefresh(payload, traceID, h.svc)
+		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
+
+	case psp.PspCmdAccountUnlock:
+		inner, status, info, extended := lh.HandleAccountUnlock(payload, traceID, h.lockout)
 		return WrapToPersephoneReply(cmd, inner, status, info, extended, traceID, signature)
 
 	default:
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
+	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// HandleSessionList processes PSP_SESSION_LIST: a user lists their own login sessions, or
+// an admin of the same tenant those of another user.
+func HandleSessionList(payload string, traceID string) (any, string, string, string) {
+	var req ss.ClientSessionListPayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid session list payload", err.Error()
+	}
+
+	target, err := authorizeTarget(&req.AuthTicket, req.TargetUser, policy.OperationRead)
+	if err != nil {
+		return nil, "403", "Session list denied", err.Error()
+	}
+
+	stored, err := session.Active().List(target)
+	if err != nil {
+		return nil, "500", "Session list failed", err.Error()
+	}
+
+	current := auth_ticket.SessionID(&req.AuthTicket)
+	sessions := make([]ss.Session, len(stored))
+	for i, s := range stored {
+		sessions[i] = ss.Session{
+			SessionID:              s.SessionID,
+			UserGroupIDs:           s.UserGroupIDs,
+			DeviceIdentifier:       s.DeviceIdentifier,
+			DeviceName:             s.DeviceName,
+			IssuedAtUnixTimestamp:  s.IssuedAtUnixTimestamp,
+			RevokedAtUnixTimestamp: s.RevokedAtUnixTimestamp,
+			IsCurrent:              s.SessionID == current,
+		}
+	}
+
+	return ss.SessionListSuccessResponse{
+		Version:  ss.SessionListResponseVersion,
+		Success:  true,
+		Sessions: sessions,
+	}, "200", "Sessions listed", ""
+}
+
+// authorizeTarget verifies the ticket and resolves whose sessions it may act on: its own
+// CoreUser always, another user of the tenant only with the policy right session/operation.
+// Rehydrated tickets are fine, so a user can sign out a lost phone from another device.
+func authorizeTarget(ticket *at.AuthTicket, target *uagc.CoreUser, operation string) (uagc.CoreUser, error) {
+	if err := auth_ticket.VerifyAuthTicket(ticket); err != nil {
+		return uagc.CoreUser{}, err
+	}
+	if ticket.Purpose != at.AuthTicketPurposeLogin && ticket.Purpose != at.AuthTicketPurposeUserRoleSwitch {
+		return uagc.CoreUser{}, fmt.Errorf("a login ticket is required")
+	}
+
+	actor := ticket.AuthenticatedUser
+	self := uagc.CoreUser{TenantID: actor.TenantID, UserID: actor.UserID}
+	if target == nil || *target == self {
+		return self, nil
+	}
+	if target.TenantID != actor.TenantID {
+		return uagc.CoreUser{}, fmt.Errorf("cannot manage sessions outside own tenant")
+	}
+	if err := policy.Active().AuthorizeUser(actor, ticket.IsRehydrated, policy.ResourceSession, operation); err != nil {
+		return uagc.CoreUser{}, err
+	}
+	return *target, nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
+	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/opaque/opaque_api"
+	"github.com/drlzh/mng-app-user-auth-prot/session_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// HandleSessionRefresh processes PSP_SESSION_REFRESH: a rotating refresh token from login
+// step two is exchanged for a new AuthTicket per user group still bound to the user, and
+// the next refresh token. Refreshed tickets are marked IsRehydrated: they prove an earlier
+// login, not a fresh one, so AuthGrant issuance and Dehydrate still require OPAQUE.
+// The reply is not encrypted: the client holds no secret the server could derive a key
+// from that an observer of the request would not also have, so it relies on TLS.
+func HandleSessionRefresh(
+	payload string,
+	traceID string,
+	svc *opaque_api.DefaultOpaqueService,
+) (any, string, string, string) {
+	var req ss.ClientSessionRefreshPayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid session refresh payload", err.Error()
+	}
+
+	s, next, err := session.Active().Refresh(req.RefreshToken)
+	if errors.Is(err, session.ErrRefreshTokenReused) {
+		return audit_log.Reply(audit_log.Event{
+			Target:  uagc.UniqueUser{TenantID: s.TenantID, UserID: s.UserID},
+			Command: audit_log.EventSessionRefresh,
+			TraceID: traceID,
+			Detail:  fmt.Sprintf("refresh token reused; session %s revoked", s.SessionID),
+		}, nil, "403", "Session refresh denied", err.Error())
+	}
+	if errors.Is(err, session.ErrRefreshTokenInvalid) ||
+		errors.Is(err, session.ErrSessionRevoked) ||
+		errors.Is(err, session_store.ErrSessionNotFound) {
+		return nil, "403", "Session refresh denied", err.Error()
+	}
+	if err != nil {
+		return nil, "500", "Session refresh failed", err.Error()
+	}
+
+	user := uagc.UniqueUser{TenantID: s.TenantID, UserID: s.UserID}
+	ev := audit_log.Event{
+		Actor:   user,
+		Target:  user,
+		Command: audit_log.EventSessionRefresh,
+		TraceID: traceID,
+		Detail:  fmt.Sprintf("session %s", s.SessionID),
+	}
+
+	entries, err := refreshTickets(svc, s)
+	if err != nil {
+		return audit_log.Reply(ev, nil, "500", "Session refresh failed", err.Error())
+	}
+
+	return audit_log.Reply(ev, ss.SessionRefreshSuccessResponse{
+		Version:                            ss.SessionRefreshResponseVersion,
+		Success:                            true,
+		SessionID:                          s.SessionID,
+		UserGroupCount:                     len(entries),
+		UserGroups:                         entries,
+		RefreshToken:                       next.Token,
+		RefreshTokenExpiresAtUnixTimestamp: next.ExpiresAtUnixTimestamp,
+	}, "200", "Session refreshed", "")
+}
+
+// refreshTickets mints a ticket for each user group the login covered that is still bound.
+func refreshTickets(
+	svc *opaque_api.DefaultOpaqueService,
+	s *session_store.StoredSession,
+) ([]ss.SessionRefreshPerUserGroupEntry, error) {
+	bindings, err := svc.Store().GetUserGroupsForUser(uagc.CoreUser{TenantID: s.TenantID, UserID: s.UserID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user group bindings: %w", err)
+	}
+	bound := make(map[string]bool, len(bindings))
+	for _, b := range bindings {
+		bound[b.UserGroupID] = true
+	}
+
+	ticketPayload, err := auth_ticket.SessionPayload(s.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ss.SessionRefreshPerUserGroupEntry
+	for _, group := range s.UserGroupIDs {
+		if !bound[group] {
+			continue
+		}
+		ticket, err := auth_ticket.CreateAuthTicket(
+			uagc.UniqueUser{TenantID: s.TenantID, UserID: s.UserID, UserGroupID: group},
+			at.AuthTicketPurposeLogin,
+			"",
+			true,
+			ticketPayload,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to issue token: %w", err)
+		}
+		entries = append(entries, ss.SessionRefreshPerUserGroupEntry{
+			UserGroupID:   group,
+			UserGroupName: uagc.FriendlyNameForGroupID(group),
+			AuthTicket:    *ticket,
+		})
+	}
+	return entries, nil
+}
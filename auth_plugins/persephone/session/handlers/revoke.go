@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/audit_log"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session"
+	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	"github.com/drlzh/mng-app-user-auth-prot/session_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// HandleSessionRevoke processes PSP_SESSION_REVOKE: end one session, or sign out
+// everywhere. Tickets and Hydrate states of an ended session stop verifying at once.
+func HandleSessionRevoke(payload string, traceID string) (any, string, string, string) {
+	var req ss.ClientSessionRevokePayload
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, "400", "Invalid session revoke payload", err.Error()
+	}
+	if req.All == (req.SessionID != "") {
+		return nil, "400", "Invalid session revoke payload", "set exactly one of session_id and all"
+	}
+
+	target, err := authorizeTarget(&req.AuthTicket, req.TargetUser, policy.OperationRevoke)
+	if err != nil {
+		return nil, "403", "Session revocation denied", err.Error()
+	}
+
+	ev := audit_log.Event{
+		Actor:   req.AuthTicket.AuthenticatedUser,
+		Target:  uagc.UniqueUser{TenantID: target.TenantID, UserID: target.UserID},
+		Command: audit_log.EventSessionRevoke,
+		TraceID: traceID,
+	}
+
+	count := 1
+	if req.All {
+		keep := ""
+		if req.KeepCurrent {
+			keep = auth_ticket.SessionID(&req.AuthTicket)
+		}
+		count, err = session.Active().RevokeAll(target, keep)
+		ev.Detail = fmt.Sprintf("all sessions (%d)", count)
+	} else {
+		err = session.Active().Revoke(target, req.SessionID)
+		ev.Detail = fmt.Sprintf("session %s", req.SessionID)
+	}
+	if errors.Is(err, session_store.ErrSessionNotFound) {
+		return nil, "400", "Unknown session", err.Error()
+	}
+	if err != nil {
+		return audit_log.Reply(ev, nil, "500", "Session revocation failed", err.Error())
+	}
+
+	return audit_log.Reply(ev, ss.SessionRevokeSuccessResponse{
+		Version:      ss.SessionRevokeResponseVersion,
+		Success:      true,
+		RevokedCount: count,
+	}, "200", "Sessions revoked", "")
+}
@@ -0,0 +1,119 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/session_store"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused; session revoked")
+)
+
+// RefreshToken is a refresh credential as handed to the client. Token is only ever
+// returned here; the store keeps its hash.
+type RefreshToken struct {
+	Token                  string
+	SessionID              string
+	ExpiresAtUnixTimestamp int64
+}
+
+// IssueRefreshToken hands out the first refresh token of a session, at login.
+func (r *Registry) IssueRefreshToken(sessionID string) (*RefreshToken, error) {
+	s, err := r.store.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return r.issueRefreshToken(s, 1)
+}
+
+// Refresh exchanges a refresh token for the next one. Each token works once: presenting
+// a used token again means it was copied, so the whole session (every ticket, Hydrate
+// state and refresh token derived from that login) is revoked and ErrRefreshTokenReused
+// returned together with the session, for auditing.
+func (r *Registry) Refresh(token string) (*session_store.StoredSession, *RefreshToken, error) {
+	hash := hashRefreshToken(token)
+	rec, err := r.store.GetRefreshToken(hash)
+	if errors.Is(err, session_store.ErrRefreshTokenNotFound) {
+		return nil, nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := r.store.Get(rec.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := r.now().Unix()
+	if rec.UsedAtUnixTimestamp == 0 {
+		err = r.store.ConsumeRefreshToken(hash, now)
+	} else {
+		err = session_store.ErrRefreshTokenAlreadyUsed
+	}
+	if errors.Is(err, session_store.ErrRefreshTokenAlreadyUsed) {
+		if rerr := r.store.Revoke(s.SessionID, now); rerr != nil {
+			return s, nil, fmt.Errorf("%w (revocation failed: %v)", ErrRefreshTokenReused, rerr)
+		}
+		return s, nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The token is spent either way; a revoked or expired session gets no successor
+	if err := r.Check(s.SessionID); err != nil {
+		return nil, nil, err
+	}
+	if now >= rec.ExpiresAtUnixTimestamp {
+		return nil, nil, ErrRefreshTokenInvalid
+	}
+
+	next, err := r.issueRefreshToken(s, rec.Generation+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, next, nil
+}
+
+// issueRefreshToken stores a new generation for s. It expires after RefreshTokenTTL of
+// disuse, and never later than the session itself.
+func (r *Registry) issueRefreshToken(s *session_store.StoredSession, generation int) (*RefreshToken, error) {
+	buf := make([]byte, ss.RefreshTokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := r.now()
+	expires := now.Add(ss.RefreshTokenTTL).Unix()
+	if end := s.IssuedAtUnixTimestamp + int64(SessionLifetime.Seconds()); expires > end {
+		expires = end
+	}
+	if expires <= now.Unix() {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	err := r.store.PutRefreshToken(session_store.StoredRefreshToken{
+		TokenHash:              hashRefreshToken(token),
+		SessionID:              s.SessionID,
+		Generation:             generation,
+		IssuedAtUnixTimestamp:  now.Unix(),
+		ExpiresAtUnixTimestamp: expires,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RefreshToken{Token: token, SessionID: s.SessionID, ExpiresAtUnixTimestamp: expires}, nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
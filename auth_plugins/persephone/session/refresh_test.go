@@ -0,0 +1,66 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	ss "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/session/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/session_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+func startSession(t *testing.T, r *Registry) (string, *RefreshToken) {
+	user := uagc.CoreUser{TenantID: "dojo-a", UserID: "akira"}
+	id, err := r.Start([]byte("session-key"), user, []string{"USER_GROUP_ADULT"}, "phone", "")
+	require.NoError(t, err)
+	first, err := r.IssueRefreshToken(id)
+	require.NoError(t, err)
+	return id, first
+}
+
+func TestRefreshRotates(t *testing.T) {
+	r := NewRegistry(session_store.NewMemoryAdapter())
+	id, first := startSession(t, r)
+
+	s, second, err := r.Refresh(first.Token)
+	require.NoError(t, err)
+	require.Equal(t, id, s.SessionID)
+	require.NotEqual(t, first.Token, second.Token)
+
+	_, third, err := r.Refresh(second.Token)
+	require.NoError(t, err)
+	require.NoError(t, r.Check(id))
+
+	_, _, err = r.Refresh("not-a-token")
+	require.ErrorIs(t, err, ErrRefreshTokenInvalid)
+	require.NoError(t, r.Check(id))
+	require.NotEmpty(t, third.Token)
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	r := NewRegistry(session_store.NewMemoryAdapter())
+	id, first := startSession(t, r)
+
+	_, second, err := r.Refresh(first.Token)
+	require.NoError(t, err)
+
+	// An attacker replays the copied first token
+	s, _, err := r.Refresh(first.Token)
+	require.ErrorIs(t, err, ErrRefreshTokenReused)
+	require.Equal(t, id, s.SessionID)
+	require.ErrorIs(t, r.Check(id), ErrSessionRevoked)
+
+	// The legitimate client's current token dies with the session
+	_, _, err = r.Refresh(second.Token)
+	require.ErrorIs(t, err, ErrSessionRevoked)
+}
+
+func TestRefreshTokenExpires(t *testing.T) {
+	r := NewRegistry(session_store.NewMemoryAdapter())
+	_, first := startSession(t, r)
+
+	r.now = func() time.Time { return time.Now().Add(ss.RefreshTokenTTL + time.Minute) }
+	_, _, err := r.Refresh(first.Token)
+	require.ErrorIs(t, err, ErrRefreshTokenInvalid)
+}
@@ -0,0 +1,190 @@
+// Package session keeps server-side memory of OPAQUE logins. Each login step two records a
+// session keyed by an ID derived from the OPAQUE session key; every ticket it hands out,
+// and every ticket later rehydrated or role-switched from it, carries that ID (see
+// auth_ticket.SessionID). Revoking the session makes all of them fail verification, which
+// is how a user signs a lost phone out, or everywhere at once.
+//
+// A session also owns a chain of single-use refresh tokens (see refresh.go) that extend it
+// past the two-minute ticket TTL without re-running OPAQUE.
+//
+// Biscuits already exchanged for a ticket are verified offline and run to their own expiry.
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/session_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// sessionIDLabel separates session ID derivation from any other use of the session key.
+const sessionIDLabel = "PERSEPHONE-SESSION-ID-V1"
+
+// SessionLifetime is how long a session can produce tickets: the Hydrate state sealed from
+// its login ticket is the longest-lived thing derived from it.
+const SessionLifetime = at.RehydratedTicketTTL
+
+var (
+	ErrSessionRevoked = errors.New("session has been revoked")
+	ErrSessionUnknown = errors.New("unknown session")
+	ErrNotSessionUser = errors.New("user group was not signed in by this session")
+)
+
+// Registry records sessions and answers whether one may still be used.
+type Registry struct {
+	store session_store.SessionStore
+	now   func() time.Time
+}
+
+func NewRegistry(store session_store.SessionStore) *Registry {
+	return &Registry{store: store, now: time.Now}
+}
+
+// IDFromSessionKey derives a session ID from the OPAQUE session key. The ID is a one-way
+// hash, so storing or logging it reveals nothing about the key.
+func IDFromSessionKey(sessionKey []byte) string {
+	h := sha256.New()
+	h.Write([]byte(sessionIDLabel))
+	h.Write(sessionKey)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Start records a new login of user and returns its session ID.
+func (r *Registry) Start(
+	sessionKey []byte,
+	user uagc.CoreUser,
+	userGroupIDs []string,
+	deviceIdentifier, deviceName string,
+) (string, error) {
+	id := IDFromSessionKey(sessionKey)
+	err := r.store.Put(session_store.StoredSession{
+		SessionID:             id,
+		TenantID:              user.TenantID,
+		UserID:                user.UserID,
+		UserGroupIDs:          userGroupIDs,
+		DeviceIdentifier:      deviceIdentifier,
+		DeviceName:            deviceName,
+		IssuedAtUnixTimestamp: r.now().Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Check returns nil if sessionID may still be used. Tickets minted outside a session
+// carry no ID and pass.
+func (r *Registry) Check(sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	_, err := r.usable(sessionID)
+	return err
+}
+
+// CheckUserGroup is Check, plus that the session was started by user's CoreUser and
+// issued tickets for user's UserGroup.
+func (r *Registry) CheckUserGroup(sessionID string, user uagc.UniqueUser) error {
+	if sessionID == "" {
+		return nil
+	}
+	s, err := r.usable(sessionID)
+	if err != nil {
+		return err
+	}
+	if s.TenantID != user.TenantID || s.UserID != user.UserID || !slices.Contains(s.UserGroupIDs, user.UserGroupID) {
+		return ErrNotSessionUser
+	}
+	return nil
+}
+
+func (r *Registry) usable(sessionID string) (*session_store.StoredSession, error) {
+	s, err := r.store.Get(sessionID)
+	if errors.Is(err, session_store.ErrSessionNotFound) {
+		return nil, ErrSessionUnknown
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session lookup failed: %w", err)
+	}
+	if s.RevokedAtUnixTimestamp != 0 {
+		return nil, ErrSessionRevoked
+	}
+	return s, nil
+}
+
+// List returns user's sessions that can still produce tickets, revoked ones included.
+func (r *Registry) List(user uagc.CoreUser) ([]session_store.StoredSession, error) {
+	since := r.now().Add(-SessionLifetime).Unix()
+	return r.store.ListForUser(user.TenantID, user.UserID, since)
+}
+
+// Revoke ends one of user's sessions. A session of another user is reported as not found.
+func (r *Registry) Revoke(user uagc.CoreUser, sessionID string) error {
+	s, err := r.store.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if s.TenantID != user.TenantID || s.UserID != user.UserID {
+		return session_store.ErrSessionNotFound
+	}
+	return r.store.Revoke(sessionID, r.now().Unix())
+}
+
+// RevokeAll signs user out everywhere except keepSessionID ("" = keep none) and returns
+// how many sessions it ended.
+func (r *Registry) RevokeAll(user uagc.CoreUser, keepSessionID string) (int, error) {
+	sessions, err := r.List(user)
+	if err != nil {
+		return 0, err
+	}
+	now := r.now().Unix()
+	revoked := 0
+	for _, s := range sessions {
+		if s.SessionID == keepSessionID || s.RevokedAtUnixTimestamp != 0 {
+			continue
+		}
+		if err := r.store.Revoke(s.SessionID, now); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// ─── Installed registry ─────────────────────────────────────────
+
+var (
+	mu     sync.RWMutex
+	active *Registry
+)
+
+// Install replaces the registry ticket verification consults. Persephone installs one at Init.
+func Install(r *Registry) {
+	mu.Lock()
+	defer mu.Unlock()
+	active = r
+}
+
+// Active returns the installed registry, falling back to an in-memory one.
+func Active() *Registry {
+	mu.RLock()
+	r := active
+	mu.RUnlock()
+	if r != nil {
+		return r
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		active = NewRegistry(session_store.NewMemoryAdapter())
+	}
+	return active
+}
@@ -0,0 +1,82 @@
+package structs
+
+import (
+	"time"
+
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+const (
+	SessionListResponseVersion    = "v1"
+	SessionRevokeResponseVersion  = "v1"
+	SessionRefreshResponseVersion = "v1"
+)
+
+const (
+	RefreshTokenSize = 32
+	RefreshTokenTTL  = 14 * 24 * time.Hour // Idle limit; the session's lifetime still caps it
+)
+
+// Session is one login as shown to its user or an admin.
+type Session struct {
+	SessionID              string   `json:"session_id"`
+	UserGroupIDs           []string `json:"user_group_ids"`
+	DeviceIdentifier       string   `json:"device_identifier,omitempty"`
+	DeviceName             string   `json:"device_name,omitempty"`
+	IssuedAtUnixTimestamp  int64    `json:"issued_at_unix_timestamp"`
+	RevokedAtUnixTimestamp int64    `json:"revoked_at_unix_timestamp,omitempty"`
+	IsCurrent              bool     `json:"is_current"` // The session of the presented ticket
+}
+
+type ClientSessionListPayload struct {
+	AuthTicket at.AuthTicket  `json:"auth_ticket"`
+	TargetUser *uagc.CoreUser `json:"target_user,omitempty"` // nil = own sessions; others need policy session/read
+}
+
+type SessionListSuccessResponse struct {
+	Version  string    `json:"version"`
+	Success  bool      `json:"success"`
+	Sessions []Session `json:"sessions"`
+}
+
+// ClientSessionRevokePayload ends SessionID, or with All every session of the target
+// ("sign out everywhere"), optionally keeping the one the request came from.
+type ClientSessionRevokePayload struct {
+	AuthTicket  at.AuthTicket  `json:"auth_ticket"`
+	TargetUser  *uagc.CoreUser `json:"target_user,omitempty"` // nil = own sessions; others need policy session/revoke
+	SessionID   string         `json:"session_id,omitempty"`
+	All         bool           `json:"all,omitempty"`
+	KeepCurrent bool           `json:"keep_current,omitempty"` // With All: stay signed in here
+}
+
+type SessionRevokeSuccessResponse struct {
+	Version      string `json:"version"`
+	Success      bool   `json:"success"`
+	RevokedCount int    `json:"revoked_count"`
+}
+
+// ClientSessionRefreshPayload trades the current refresh token for fresh AuthTickets and
+// the next refresh token. The presented token is spent whatever the outcome.
+type ClientSessionRefreshPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionRefreshPerUserGroupEntry carries the AuthTicket in the clear. Unlike login step
+// two there is no key only the client holds to encrypt it under, so the refresh reply
+// relies on TLS alone, as the refresh token in the request already does.
+type SessionRefreshPerUserGroupEntry struct {
+	UserGroupID   string        `json:"user_group_id"`
+	UserGroupName string        `json:"user_group_name"`
+	AuthTicket    at.AuthTicket `json:"auth_ticket"` // Purpose = AuthTicketPurposeLogin, IsRehydrated
+}
+
+type SessionRefreshSuccessResponse struct {
+	Version                            string                            `json:"version"`
+	Success                            bool                              `json:"success"`
+	SessionID                          string                            `json:"session_id"`
+	UserGroupCount                     int                               `json:"user_group_count"`
+	UserGroups                         []SessionRefreshPerUserGroupEntry `json:"user_groups"`
+	RefreshToken                       string                            `json:"refresh_token"` // Replaces the one presented
+	RefreshTokenExpiresAtUnixTimestamp int64                             `json:"refresh_token_expires_at_unix_timestamp"`
+}
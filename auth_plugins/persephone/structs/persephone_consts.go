@@ -13,4 +13,18 @@ const (
 
 	PspCmdHydrateInitiateHydrate = "PSP_INITIATE_HYDRATE"
 	PspCmdHydrateExecute         = "PSP_HYDRATE_EXECUTE"
+
+	PspCmdAuthGrantIssue  = "PSP_AUTH_GRANT_ISSUE"
+	PspCmdAuthGrantRevoke = "PSP_AUTH_GRANT_REVOKE"
+
+	PspCmdBiscuitExchange = "PSP_BISCUIT_EXCHANGE"
+
+	PspCmdDelegationIssue  = "PSP_DELEGATION_ISSUE"
+	PspCmdDelegationRevoke = "PSP_DELEGATION_REVOKE"
+
+	PspCmdSessionList    = "PSP_SESSION_LIST"
+	PspCmdSessionRevoke  = "PSP_SESSION_REVOKE"
+	PspCmdSessionRefresh = "PSP_SESSION_REFRESH"
+
+	PspCmdAccountUnlock = "PSP_ACCOUNT_UNLOCK"
 )
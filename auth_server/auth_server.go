@@ -6,6 +6,7 @@ import (
 	"github.com/rs/cors"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 )
@@ -82,7 +83,7 @@ func AuthEndpoint(w http.ResponseWriter, r *http.Request) {
 	}
 
 	normalizedPath := normalizePath(r.URL.Path)
-	payloadOut, statusOut, infoOut, extendedOut := auth_service_registry.Dispatch(normalizedPath, pluginPayload, statusIn, infoIn, extendedIn)
+	payloadOut, statusOut, infoOut, extendedOut := auth_service_registry.DispatchFrom(clientAddr(r), normalizedPath, pluginPayload, statusIn, infoIn, extendedIn)
 
 	respBytes, err := wrapper.Wrap(marshalToString(payloadOut), statusOut, infoOut, extendedOut)
 	if err != nil {
@@ -104,6 +105,16 @@ func normalizePath(p string) string {
 	return p
 }
 
+// clientAddr returns the peer IP. X-Forwarded-For is deliberately ignored since
+// it is client-controlled unless a trusted proxy strips it; revisit when deploying behind one.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func writeError(w http.ResponseWriter, status, info, extended string, code int) {
 	resp := TransportMessage{
 		Status:             status,
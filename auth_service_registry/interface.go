@@ -10,6 +10,12 @@ type AuthSubsystemHandler interface {
 	HandleRequest(path string, payloadIn string, statusIn, infoIn, extendedIn string) (payloadOut any, statusOut, infoOut, extendedOut string)
 }
 
+// RemoteAwareHandler is optionally implemented by plugins that need the caller's network
+// address (e.g. for per-IP throttling). Dispatch falls back to HandleRequest otherwise.
+type RemoteAwareHandler interface {
+	HandleRequestFrom(remoteAddr string, path string, payloadIn string, statusIn, infoIn, extendedIn string) (payloadOut any, statusOut, infoOut, extendedOut string)
+}
+
 type PluginFactory struct {
 	Name     string
 	Handler  func() AuthSubsystemHandler
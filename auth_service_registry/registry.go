@@ -77,11 +77,19 @@ func checkRouteConflicts(routes []string) error {
 }
 
 func Dispatch(path string, payloadIn string, status, infoIn, extendedIn string) (payloadOut any, statusOut, infoOut, extendedOut string) {
+	return DispatchFrom("", path, payloadIn, status, infoIn, extendedIn)
+}
+
+// DispatchFrom is Dispatch with the caller's address, forwarded to RemoteAwareHandler plugins.
+func DispatchFrom(remoteAddr string, path string, payloadIn string, status, infoIn, extendedIn string) (payloadOut any, statusOut, infoOut, extendedOut string) {
 	mu.RLock()
 	defer mu.RUnlock()
 
 	for _, entry := range entries {
 		if strings.HasPrefix(path, entry.Path) {
+			if ra, ok := entry.Handler.(RemoteAwareHandler); ok {
+				return ra.HandleRequestFrom(remoteAddr, path, payloadIn, status, infoIn, extendedIn)
+			}
 			return entry.Handler.HandleRequest(path, payloadIn, status, infoIn, extendedIn)
 		}
 	}
@@ -0,0 +1,177 @@
+// Package auth_verifier lets downstream services verify AuthTickets, AuthGrants and
+// Biscuits offline. It fetches the public keys DEMETER publishes on /keys, caches them, and
+// checks signatures exactly like the auth server does; see
+// <ProjectRoot>\auth_plugins\persephone\auth_ticket\structs\canonical.go for the signed bytes.
+//
+//	v := auth_verifier.New(auth_verifier.HTTPSource{URL: "https://auth.example.com/api/v1/auth/keys"}, auth_verifier.Options{})
+//	if err := v.VerifyAuthTicket(ticket); err != nil { ... }
+package auth_verifier
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/biscuit-auth/biscuit-go/v2"
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/demeter/structs"
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/biscuit/biscuit_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+)
+
+var (
+	ErrKeySetUnavailable = errors.New("auth server key set unavailable")
+	ErrUnknownKey        = errors.New("unknown signing key")
+	ErrKeyOutsideWindow  = errors.New("artifact issued outside the signing key's validity window")
+	ErrInvalidSignature  = errors.New("invalid signature")
+	ErrUnsupported       = errors.New("unsupported artifact version")
+	ErrExpired           = errors.New("artifact has expired")
+)
+
+// Options tune caching and freshness. Zero values pick the defaults.
+type Options struct {
+	MaxKeySetAge       time.Duration // Default ds.PublicKeySetMaxAge; the server's hint wins if shorter
+	MinRefreshInterval time.Duration // Default 30s; floor between refetches triggered by unknown key IDs
+	MaxStale           time.Duration // Default 1h; how long a stale set serves while the server is down
+	AuthTicketTTL      time.Duration // Default at.AuthTicketTTL
+	ClockSkew          time.Duration // Default 30s; tolerated for issued-at timestamps in the future
+	Now                func() time.Time
+}
+
+type Verifier struct {
+	keys          *keyCache
+	authTicketTTL time.Duration
+	clockSkew     time.Duration
+	now           func() time.Time
+}
+
+func New(source KeySetSource, opts Options) *Verifier {
+	if opts.MaxKeySetAge <= 0 {
+		opts.MaxKeySetAge = ds.PublicKeySetMaxAge
+	}
+	if opts.MinRefreshInterval <= 0 {
+		opts.MinRefreshInterval = 30 * time.Second
+	}
+	if opts.MaxStale <= 0 {
+		opts.MaxStale = time.Hour
+	}
+	if opts.AuthTicketTTL <= 0 {
+		opts.AuthTicketTTL = at.AuthTicketTTL
+	}
+	if opts.ClockSkew <= 0 {
+		opts.ClockSkew = 30 * time.Second
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Verifier{
+		keys: &keyCache{
+			source:     source,
+			maxAge:     opts.MaxKeySetAge,
+			minRefresh: opts.MinRefreshInterval,
+			maxStale:   opts.MaxStale,
+			now:        opts.Now,
+		},
+		authTicketTTL: opts.AuthTicketTTL,
+		clockSkew:     opts.ClockSkew,
+		now:           opts.Now,
+	}
+}
+
+// Refresh refetches the key set now instead of waiting for the cache to expire.
+func (v *Verifier) Refresh() error {
+	return v.keys.refresh()
+}
+
+// VerifyAuthTicket checks the ticket's signature and that it is younger than the ticket TTL.
+func (v *Verifier) VerifyAuthTicket(ticket *at.AuthTicket) error {
+	if err := v.VerifyAuthTicketSignature(ticket); err != nil {
+		return err
+	}
+	if v.now().Unix()-ticket.IssuedAtUnixTimestamp > int64(v.authTicketTTL.Seconds()) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// VerifyAuthTicketSignature checks only the signature, ignoring the ticket TTL.
+func (v *Verifier) VerifyAuthTicketSignature(ticket *at.AuthTicket) error {
+	if ticket == nil || ticket.Version != at.AuthTicketVersion {
+		return ErrUnsupported
+	}
+	data, err := at.CanonicalBytes(*ticket)
+	if err != nil {
+		return err
+	}
+	return v.verify(ds.KeyUseAuthTicket, ticket.SigningKeyIdentifier, ticket.IssuedAtUnixTimestamp, data, ticket.Signature, sigctx.AuthTicket)
+}
+
+// VerifyAuthGrant checks the grant's signature and expiry. Whether the grant was already
+// consumed or revoked is only known to the auth server.
+func (v *Verifier) VerifyAuthGrant(grant *ag.AuthGrant) error {
+	if grant == nil || grant.Version != ag.AuthGrantVersion {
+		return ErrUnsupported
+	}
+	data, err := ag.CanonicalBytes(*grant)
+	if err != nil {
+		return err
+	}
+	if err := v.verify(ds.KeyUseAuthGrant, grant.SigningKeyIdentifier, grant.IssuedAtUnixTimestamp, data, grant.Signature, sigctx.AuthGrant); err != nil {
+		return err
+	}
+	if v.now().Unix() > grant.ExpiresAtUnixTimestamp {
+		return ErrExpired
+	}
+	return nil
+}
+
+// BiscuitAuthorizer verifies a Biscuit from PSP_BISCUIT_EXCHANGE (encoded as returned there)
+// against the published root keys and returns an authorizer loaded with its blocks. Add the
+// request's facts and policies, including time({now}), and call Authorize.
+func (v *Verifier) BiscuitAuthorizer(encoded string) (biscuit.Authorizer, error) {
+	token, err := biscuit_api.Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := v.keys.all(ds.KeyUseBiscuit)
+	if err != nil {
+		return nil, err
+	}
+	// Tokens don't name their root key and only a few are ever published, so try each
+	for _, k := range roots {
+		if a, err := biscuit_api.NewAuthorizer(token, ed25519.PublicKey(k.material)); err == nil {
+			return a, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+func (v *Verifier) verify(use, kid string, issuedAt int64, data []byte, signature string, ctx string) error {
+	if issuedAt > v.now().Add(v.clockSkew).Unix() {
+		return errors.New("artifact issued in the future")
+	}
+
+	k, err := v.keys.lookup(use, kid)
+	if err != nil {
+		return err
+	}
+	if (k.NotBefore != 0 && issuedAt < k.NotBefore) || (k.NotAfter != 0 && issuedAt > k.NotAfter) {
+		return ErrKeyOutsideWindow
+	}
+	if k.SigningContext != ctx {
+		return ErrInvalidSignature
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed448_api.SignatureSize {
+		return ErrInvalidSignature
+	}
+	if !ed448_api.VerifyWithContext(ed448_api.PublicKey(k.material), data, sig, ctx) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package auth_verifier
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/biscuit-auth/biscuit-go/v2"
+	"github.com/biscuit-auth/biscuit-go/v2/parser"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/demeter"
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/demeter/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant"
+	ag "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_grant/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/biscuit_token"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_server"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_service_registry"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/biscuit/biscuit_api"
+	"github.com/drlzh/mng-app-user-auth-prot/internal/context"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+type countingSource struct {
+	KeySetSource
+	fetches int
+}
+
+func (s *countingSource) FetchKeySet() (*ds.PublicKeySet, error) {
+	s.fetches++
+	return s.KeySetSource.FetchKeySet()
+}
+
+func TestVerifyAuthTicketOverHTTP(t *testing.T) {
+	require.NoError(t, auth_service_registry.RegisterPlugin(auth_service_registry.PluginFactory{
+		Name:    "DEMETER_KEYS",
+		Handler: demeter.NewKeySetHandler,
+	}, &context.AppContext{}))
+	srv := httptest.NewServer(http.HandlerFunc(auth_server.AuthEndpoint))
+	defer srv.Close()
+
+	v := New(HTTPSource{URL: srv.URL + "/api/v1/auth/keys"}, Options{})
+
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: "ADULT", UserID: "akira"}
+	ticket, err := auth_ticket.CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, json.RawMessage(`{"k":"v"}`))
+	require.NoError(t, err)
+	require.NoError(t, v.VerifyAuthTicket(ticket))
+
+	tampered := *ticket
+	tampered.AuthenticatedUser.UserGroupID = "COACH"
+	require.ErrorIs(t, v.VerifyAuthTicket(&tampered), ErrInvalidSignature)
+
+	// A ticket signature must not verify as a grant, even under the same key ID
+	grant := ag.AuthGrant{
+		Version:                ag.AuthGrantVersion,
+		IssuedAtUnixTimestamp:  ticket.IssuedAtUnixTimestamp,
+		ExpiresAtUnixTimestamp: time.Now().Add(time.Hour).Unix(),
+		SigningKeyIdentifier:   ticket.SigningKeyIdentifier,
+		Signature:              ticket.Signature,
+	}
+	require.Error(t, v.VerifyAuthGrant(&grant))
+}
+
+func TestVerifyAuthGrantAndExpiry(t *testing.T) {
+	now := time.Now()
+	v := New(StaticSource{KeySet: demeter.BuildPublicKeySet(uagc.ActiveKeyStore(), now)}, Options{
+		Now: func() time.Time { return now },
+	})
+
+	grant, err := auth_grant.CreateAuthGrant("g1", ag.AuthGrantPurposeRegister, "", "", json.RawMessage(`{}`), time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, v.VerifyAuthGrant(grant))
+
+	now = now.Add(2 * time.Minute)
+	require.ErrorIs(t, v.VerifyAuthGrant(grant), ErrExpired)
+}
+
+func TestUnknownKeyRefreshIsRateLimited(t *testing.T) {
+	now := time.Now()
+	src := &countingSource{KeySetSource: StaticSource{KeySet: demeter.BuildPublicKeySet(uagc.ActiveKeyStore(), now)}}
+	v := New(src, Options{Now: func() time.Time { return now }})
+
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: "ADULT", UserID: "akira"}
+	ticket, err := auth_ticket.CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, nil)
+	require.NoError(t, err)
+	require.NoError(t, v.VerifyAuthTicket(ticket))
+	require.Equal(t, 1, src.fetches)
+
+	ticket.SigningKeyIdentifier = "no-such-key"
+	for i := 0; i < 3; i++ {
+		require.True(t, errors.Is(v.VerifyAuthTicket(ticket), ErrUnknownKey))
+	}
+	require.Equal(t, 1, src.fetches) // Fetched less than MinRefreshInterval ago
+
+	now = now.Add(time.Minute)
+	require.True(t, errors.Is(v.VerifyAuthTicket(ticket), ErrUnknownKey))
+	require.Equal(t, 2, src.fetches)
+}
+
+func TestKeyValidityWindow(t *testing.T) {
+	now := time.Now()
+	set := demeter.BuildPublicKeySet(uagc.ActiveKeyStore(), now)
+	for i := range set.Keys {
+		set.Keys[i].NotAfter = now.Add(-time.Hour).Unix() // Key stopped signing an hour ago
+	}
+	v := New(StaticSource{KeySet: set}, Options{})
+
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: "ADULT", UserID: "akira"}
+	ticket, err := auth_ticket.CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, v.VerifyAuthTicket(ticket), ErrKeyOutsideWindow)
+}
+
+func TestBiscuitAuthorizer(t *testing.T) {
+	v := New(StaticSource{KeySet: demeter.BuildPublicKeySet(uagc.ActiveKeyStore(), time.Now())}, Options{})
+
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupStaff, UserID: "desk"}
+	ticket, err := auth_ticket.CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, nil)
+	require.NoError(t, err)
+	encoded, _, _, err := biscuit_token.Mint(ticket, time.Minute)
+	require.NoError(t, err)
+
+	a, err := v.BiscuitAuthorizer(encoded)
+	require.NoError(t, err)
+	a.AddAuthorizer(parser.New().Must().Authorizer(`
+		time({now});
+		allow if user_group("USER_GROUP_STAFF"), right("attendance", "write");
+	`, biscuit_api.Params{"now": biscuit.Date(time.Now())}))
+	require.NoError(t, a.Authorize())
+
+	_, err = v.BiscuitAuthorizer(encoded[:len(encoded)/2])
+	require.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package auth_verifier
+
+import (
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Downstream services embed this package, so it must not link in the auth server, its
+// stores, or user_auth_global_config (whose dev key store carries private keys).
+func TestDoesNotLinkServerSide(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not in PATH")
+	}
+	out, err := exec.Command(goTool, "list", "-deps", ".").Output()
+	require.NoError(t, err)
+
+	const module = "github.com/drlzh/mng-app-user-auth-prot/"
+	forbidden := []string{"user_auth_global_config", "auth_server", "keystore"}
+	for _, dep := range strings.Fields(string(out)) {
+		rel, ok := strings.CutPrefix(dep, module)
+		if !ok {
+			continue
+		}
+		for _, f := range forbidden {
+			require.False(t, rel == f || strings.HasPrefix(rel, f+"/"), "auth_verifier links %s", dep)
+		}
+		require.False(t, strings.HasSuffix(path.Base(rel), "_store"), "auth_verifier links %s", dep)
+		require.NotContains(t, rel, "ghetto_db", "auth_verifier links %s", dep)
+	}
+}
@@ -0,0 +1,142 @@
+package auth_verifier
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/demeter/structs"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+)
+
+type cachedKey struct {
+	ds.PublishedKey
+	material []byte
+}
+
+// keySizes lists the algorithms this package can verify, with their public key sizes.
+var keySizes = map[string]int{
+	ds.KeyAlgorithmEd448:   ed448_api.PubKeySize,
+	ds.KeyAlgorithmEd25519: ed25519.PublicKeySize,
+}
+
+// keyCache holds the last fetched key set. It refetches once the set is older than its
+// max age, and early when an artifact names an unknown key (at most once per
+// minRefresh, so garbage key IDs can't be used to hammer the auth server).
+// If the auth server is unreachable the stale set keeps serving for up to maxStale.
+type keyCache struct {
+	source     KeySetSource
+	maxAge     time.Duration
+	minRefresh time.Duration
+	maxStale   time.Duration
+	now        func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]cachedKey // use + "/" + kid
+	ttl         time.Duration
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+func cacheKey(use, kid string) string {
+	return use + "/" + kid
+}
+
+// lookup returns the key an artifact of the given use names.
+func (c *keyCache) lookup(use, kid string) (cachedKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if err := c.ensureFreshLocked(now); err != nil {
+		return cachedKey{}, err
+	}
+
+	if k, ok := c.keys[cacheKey(use, kid)]; ok {
+		return k, nil
+	}
+	if now.Sub(c.lastAttempt) >= c.minRefresh {
+		if err := c.refreshLocked(now); err == nil {
+			if k, ok := c.keys[cacheKey(use, kid)]; ok {
+				return k, nil
+			}
+		}
+	}
+	return cachedKey{}, fmt.Errorf("%w: %s/%s", ErrUnknownKey, use, kid)
+}
+
+// all returns every cached key of the given use, for tokens that don't name their key.
+func (c *keyCache) all(use string) ([]cachedKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ensureFreshLocked(c.now()); err != nil {
+		return nil, err
+	}
+
+	var out []cachedKey
+	for _, k := range c.keys {
+		if k.Use == use {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+// ensureFreshLocked refetches an expired set, falling back to the stale one within maxStale.
+func (c *keyCache) ensureFreshLocked(now time.Time) error {
+	var refreshErr error
+	if c.keys == nil || now.Sub(c.fetchedAt) > c.ttl {
+		refreshErr = c.refreshLocked(now)
+	}
+	if c.keys == nil || now.Sub(c.fetchedAt) > c.ttl+c.maxStale {
+		if refreshErr == nil {
+			refreshErr = ErrKeySetUnavailable
+		}
+		return fmt.Errorf("%w: %v", ErrKeySetUnavailable, refreshErr)
+	}
+	return nil
+}
+
+// refresh forces a refetch, e.g. right after the auth server rotated keys.
+func (c *keyCache) refresh() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshLocked(c.now())
+}
+
+func (c *keyCache) refreshLocked(now time.Time) error {
+	c.lastAttempt = now
+	set, err := c.source.FetchKeySet()
+	if err != nil {
+		return err
+	}
+	if set.Version != ds.PublicKeySetVersion {
+		return fmt.Errorf("unsupported key set version %q", set.Version)
+	}
+
+	keys := make(map[string]cachedKey, len(set.Keys))
+	for _, k := range set.Keys {
+		size, ok := keySizes[k.Algorithm]
+		if !ok {
+			continue // Published for someone else
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(k.PublicKey)
+		if err != nil || len(raw) != size {
+			return fmt.Errorf("malformed public key %s/%s", k.Use, k.KeyID)
+		}
+		keys[cacheKey(k.Use, k.KeyID)] = cachedKey{PublishedKey: k, material: raw}
+	}
+
+	ttl := c.maxAge
+	if hint := time.Duration(set.MaxAgeSeconds) * time.Second; hint > 0 && hint < ttl {
+		ttl = hint
+	}
+
+	c.keys = keys
+	c.ttl = ttl
+	c.fetchedAt = now
+	return nil
+}
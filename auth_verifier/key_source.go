@@ -0,0 +1,87 @@
+package auth_verifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	ds "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/demeter/structs"
+)
+
+// KeySetSource fetches the auth server's published key set.
+type KeySetSource interface {
+	FetchKeySet() (*ds.PublicKeySet, error)
+}
+
+// HTTPSource fetches the key set from DEMETER's /keys route,
+// e.g. "https://auth.example.com/api/v1/auth/keys".
+type HTTPSource struct {
+	URL    string
+	Client *http.Client // nil uses a client with a 10s timeout
+}
+
+const maxKeySetResponseSize = 1 << 20
+
+// transportMessage mirrors auth_server.TransportMessage, the envelope every PSP reply
+// comes in. It is copied rather than imported so that this package does not link in
+// the auth server.
+type transportMessage struct {
+	Status             string `json:"status"`
+	StatusInfo         string `json:"status_info"`
+	StatusExtendedInfo string `json:"status_extended_info"`
+	Payload            string `json:"payload"`
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func (s HTTPSource) FetchKeySet() (*ds.PublicKeySet, error) {
+	client := s.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+
+	body, err := json.Marshal(transportMessage{})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("fetching key set: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxKeySetResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading key set: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching key set: HTTP %d", resp.StatusCode)
+	}
+
+	var msg transportMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("invalid transport message: %w", err)
+	}
+	if msg.Status != "200" {
+		return nil, fmt.Errorf("fetching key set: %s %s", msg.Status, msg.StatusInfo)
+	}
+
+	var set ds.PublicKeySet
+	if err := json.Unmarshal([]byte(msg.Payload), &set); err != nil {
+		return nil, fmt.Errorf("invalid key set: %w", err)
+	}
+	return &set, nil
+}
+
+// StaticSource serves a fixed key set, e.g. one pinned in a service's config or a test.
+type StaticSource struct {
+	KeySet ds.PublicKeySet
+}
+
+func (s StaticSource) FetchKeySet() (*ds.PublicKeySet, error) {
+	set := s.KeySet
+	return &set, nil
+}
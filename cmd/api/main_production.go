@@ -1,3 +1,5 @@
+//go:build production
+
 package main
 
 import (
@@ -7,6 +9,9 @@ import (
 	"github.com/drlzh/mng-app-user-auth-prot/auth_server"
 	"github.com/drlzh/mng-app-user-auth-prot/auth_service_registry"
 	"github.com/drlzh/mng-app-user-auth-prot/internal/context"
+	"github.com/drlzh/mng-app-user-auth-prot/keystore"
+	"github.com/drlzh/mng-app-user-auth-prot/policy"
+	"github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 	_ "github.com/lib/pq"
 	"log"
 	"os"
@@ -20,6 +25,13 @@ var Plugins = []auth_service_registry.PluginFactory{
 		Extras:   nil,
 		Required: false,
 	},
+	{
+		Name:     "DEMETER_KEYS",           // Publishes AuthTicket/AuthGrant public keys on /keys
+		Handler:  demeter.NewKeySetHandler, // See <ProjectRoot>\auth_verifier for downstream verification
+		Config:   nil,
+		Extras:   nil,
+		Required: true,
+	},
 	{
 		Name:     "PERSEPHONE", // PSP auth protocol master router
 		Handler:  func() auth_service_registry.AuthSubsystemHandler { return persephone.NewPersephoneHandler() },
@@ -57,7 +69,34 @@ func connectToPostgres() *sql.DB {
 	return db
 }
 
+// loadKeyStore installs the deployment's keys; production binaries have none compiled in.
+// See <ProjectRoot>\keystore\env_adapter.go: LoadFromEnvironment()
+func loadKeyStore() {
+	ks, err := keystore.LoadFromEnvironment()
+	if err != nil {
+		log.Fatalf("❌ Failed to load keystore: %v", err)
+	}
+	if err := user_auth_global_config.SetKeyStore(ks); err != nil {
+		log.Fatalf("❌ Invalid keystore: %v", err)
+	}
+	log.Println("🔑 Keystore loaded")
+}
+
+// loadPolicies installs the per-tenant entitlement policies.
+// See <ProjectRoot>\policy\loader.go: LoadFromEnvironment()
+func loadPolicies() {
+	engine, err := policy.LoadFromEnvironment()
+	if err != nil {
+		log.Fatalf("❌ Failed to load policies: %v", err)
+	}
+	policy.Install(engine)
+	log.Printf("📜 Policies loaded for tenants: %v", engine.Tenants())
+}
+
 func main() {
+	loadKeyStore()
+	loadPolicies()
+
 	var db *sql.DB = nil // In production, replace with db := connectToPostgres()
 	// This will make Persephone default to in-memory GhettoDB for easier local testing
 	// See <ProjectRoot>\auth_plugins\persephone\persephone.go: Init()
@@ -0,0 +1,97 @@
+package biscuit_api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/biscuit-auth/biscuit-go/v2"
+	"github.com/biscuit-auth/biscuit-go/v2/parser"
+)
+
+// Params fills {name} placeholders in Datalog code. Use them for anything user-controlled
+// instead of formatting it into the code.
+type Params = parser.ParametersMap
+
+var ErrMalformedToken = errors.New("malformed biscuit token")
+
+// Mint signs a new token whose authority block is the given block.
+func Mint(root ed25519.PrivateKey, authority biscuit.ParsedBlock) ([]byte, error) {
+	builder := biscuit.NewBuilder(root)
+	if err := builder.AddBlock(authority); err != nil {
+		return nil, fmt.Errorf("invalid authority block: %w", err)
+	}
+	b, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build biscuit: %w", err)
+	}
+	return b.Serialize()
+}
+
+// Attenuate appends a block of Datalog checks, e.g. `check if operation("read");`.
+// Anyone holding a token can do this without the root key; the result can only do less.
+func Attenuate(token []byte, code string, params Params) ([]byte, error) {
+	b, err := biscuit.Unmarshal(token)
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	block, err := parser.FromStringBlockWithParams(code, params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block: %w", err)
+	}
+
+	builder := b.CreateBlock()
+	if err := builder.AddBlock(block); err != nil {
+		return nil, fmt.Errorf("invalid block: %w", err)
+	}
+	attenuated, err := b.Append(rand.Reader, builder.Build())
+	if err != nil {
+		return nil, fmt.Errorf("failed to append block: %w", err)
+	}
+	return attenuated.Serialize()
+}
+
+// NewAuthorizer verifies the token's signature chain against root and returns an
+// authorizer loaded with its blocks. Callers add request facts and policies, then Authorize.
+func NewAuthorizer(token []byte, root ed25519.PublicKey) (biscuit.Authorizer, error) {
+	b, err := biscuit.Unmarshal(token)
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	return b.Authorizer(root)
+}
+
+// Authorize is NewAuthorizer followed by the given authorizer code (facts, checks and
+// allow/deny policies), e.g.
+//
+//	resource("belt_rank"); operation("read"); time({now});
+//	allow if resource($r), operation($op), right($r, $op);
+func Authorize(token []byte, root ed25519.PublicKey, code string, params Params) error {
+	a, err := NewAuthorizer(token, root)
+	if err != nil {
+		return err
+	}
+	parsed, err := parser.FromStringAuthorizerWithParams(code, params)
+	if err != nil {
+		return fmt.Errorf("invalid authorizer: %w", err)
+	}
+	a.AddAuthorizer(parsed)
+	return a.Authorize()
+}
+
+// Encode returns the URL-safe base64 form other biscuit libraries exchange tokens in.
+func Encode(token []byte) string {
+	return base64.URLEncoding.EncodeToString(token)
+}
+
+// Decode accepts Encode's output, with or without padding.
+func Decode(encoded string) ([]byte, error) {
+	token, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	return token, nil
+}
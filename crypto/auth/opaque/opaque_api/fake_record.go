@@ -0,0 +1,54 @@
+package opaque_api
+
+import (
+	"crypto/hkdf"
+	"fmt"
+
+	"github.com/bytemare/opaque"
+)
+
+const (
+	fakeRecordKeyInfo     = "mng-app-user-auth-prot/opaque/fake-record/client-key"
+	fakeRecordMaskingInfo = "mng-app-user-auth-prot/opaque/fake-record/masking-key"
+	fakeRecordKeyDST      = "mng-app-user-auth-prot-OPAQUE-FakeRecord-v1"
+
+	// envelopeNonceLength is the envelope nonce size fixed by the OPAQUE spec.
+	envelopeNonceLength = 32
+)
+
+// fakeRecord stands in for the registration record of a CoreUser that has none, so
+// LoginStep1 answers with a KE2 shaped like any other (the OPAQUE draft's fake record
+// defence against client enumeration). Unlike opaque.Configuration.GetFakeRecord it is
+// derived from the OPRF seed and the credential identifier, so repeated probes for the
+// same unknown user see the same client public key and masking key, just as they would
+// for a registered one. The client's LoginFinish can never succeed against it.
+func fakeRecord(
+	conf *opaque.Configuration,
+	server *opaque.Server,
+	oprfSeed, credentialIdentifier []byte,
+) (*opaque.ClientRecord, error) {
+	g := conf.AKE.Group()
+
+	keySeed, err := hkdf.Key(conf.KDF.New, oprfSeed, nil, fakeRecordKeyInfo+string(credentialIdentifier), conf.KDF.Size())
+	if err != nil {
+		return nil, fmt.Errorf("derive fake client key: %w", err)
+	}
+	maskingKey, err := hkdf.Key(conf.KDF.New, oprfSeed, nil, fakeRecordMaskingInfo+string(credentialIdentifier), conf.KDF.Size())
+	if err != nil {
+		return nil, fmt.Errorf("derive fake masking key: %w", err)
+	}
+	publicKey := g.Base().Multiply(g.HashToScalar(keySeed, []byte(fakeRecordKeyDST)))
+
+	raw := append(publicKey.Encode(), maskingKey...)
+	raw = append(raw, make([]byte, envelopeNonceLength+conf.MAC.Size())...)
+	record, err := server.Deserialize.RegistrationRecord(raw)
+	if err != nil {
+		return nil, fmt.Errorf("fake registration record parse: %w", err)
+	}
+
+	return &opaque.ClientRecord{
+		CredentialIdentifier: credentialIdentifier,
+		ClientIdentity:       credentialIdentifier,
+		RegistrationRecord:   record,
+	}, nil
+}
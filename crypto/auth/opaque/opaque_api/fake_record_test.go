@@ -0,0 +1,58 @@
+package opaque_api
+
+import (
+	"testing"
+
+	"github.com/bytemare/opaque"
+	driver "github.com/drlzh/mng-app-user-auth-prot/internal/opaque_test_driver"
+	"github.com/drlzh/mng-app-user-auth-prot/opaque_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+	"github.com/stretchr/testify/require"
+)
+
+// login runs LoginStep1 and the client's LoginFinish, returning the raw KE2 and the
+// client's error, if any.
+func login(t *testing.T, svc *DefaultOpaqueService, user uagc.CoreUser, password string) ([]byte, error) {
+	client := driver.NewClient(t)
+	ke2B64, _, err := svc.LoginStep1(user, driver.B64(client.LoginInit([]byte(password)).Serialize()))
+	require.NoError(t, err)
+	_, err = driver.LoginFinish(t, client, user, ke2B64)
+	return driver.Unb64(t, ke2B64), err
+}
+
+func TestLoginStep1HidesUnknownUsers(t *testing.T) {
+	svc, err := NewDefaultOpaqueService(opaque_store.NewGhettoAdapter(ghetto_db.New()))
+	require.NoError(t, err)
+	known := uagc.CoreUser{TenantID: "dojo-a", UserID: "amanda"}
+	unknown := uagc.CoreUser{TenantID: "dojo-a", UserID: "nobody"}
+	driver.Register(t, svc, known, "correct horse")
+
+	good, err := login(t, svc, known, "correct horse")
+	require.NoError(t, err)
+	wrong, err := login(t, svc, known, "battery staple")
+	require.Error(t, err)
+	fake, err := login(t, svc, unknown, "battery staple")
+	require.Error(t, err)
+
+	require.Len(t, wrong, len(good))
+	require.Len(t, fake, len(good))
+}
+
+func TestFakeRecordIsDeterministic(t *testing.T) {
+	conf := opaque.DefaultConfiguration()
+	server, err := conf.Server()
+	require.NoError(t, err)
+	seed := uagc.OpaqueServerSecretOprfSeed()
+
+	a1, err := fakeRecord(conf, server, seed, []byte("dojo-a|nobody"))
+	require.NoError(t, err)
+	a2, err := fakeRecord(conf, server, seed, []byte("dojo-a|nobody"))
+	require.NoError(t, err)
+	b, err := fakeRecord(conf, server, seed, []byte("dojo-a|someone"))
+	require.NoError(t, err)
+
+	require.Equal(t, a1.RegistrationRecord.Serialize(), a2.RegistrationRecord.Serialize())
+	require.NotEqual(t, a1.PublicKey.Encode(), b.PublicKey.Encode())
+	require.NotEqual(t, a1.MaskingKey, b.MaskingKey)
+}
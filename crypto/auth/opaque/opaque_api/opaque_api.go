@@ -4,7 +4,6 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"log"
 
 	"github.com/bytemare/opaque"
 	"github.com/drlzh/mng-app-user-auth-prot/opaque_store"
@@ -13,31 +12,21 @@ import (
 
 type DefaultOpaqueService struct {
 	store opaque_store.OpaqueClientStore
+	keys  *serverKeys
 }
 
 func (svc *DefaultOpaqueService) Store() opaque_store.OpaqueClientStore {
 	return svc.store
 }
 
-func NewDefaultOpaqueService(s opaque_store.OpaqueClientStore) *DefaultOpaqueService {
-	return &DefaultOpaqueService{store: s}
-}
-
-func (svc *DefaultOpaqueService) getServer() *opaque.Server {
-	conf := opaque.DefaultConfiguration()
-	server, err := conf.Server()
+// NewDefaultOpaqueService reads the server's OPAQUE key material from the keystore once;
+// it fails if that material is missing or invalid.
+func NewDefaultOpaqueService(s opaque_store.OpaqueClientStore) (*DefaultOpaqueService, error) {
+	keys, err := loadServerKeys(opaque.DefaultConfiguration())
 	if err != nil {
-		log.Fatalln("OPAQUE server instantiation failed:", err)
-	}
-	if err := server.SetKeyMaterial(
-		user_auth_global_config.OpaqueServerId(),
-		user_auth_global_config.OpaqueServerPrivateKey(),
-		user_auth_global_config.OpaqueServerPublicKey(),
-		user_auth_global_config.OpaqueServerSecretOprfSeed(),
-	); err != nil {
-		log.Fatalln("OPAQUE server key material error:", err)
+		return nil, err
 	}
-	return server
+	return &DefaultOpaqueService{store: s, keys: keys}, nil
 }
 
 // ─── Registration ─────────────────────────────────────────────────────────────
@@ -50,29 +39,28 @@ func (svc *DefaultOpaqueService) RegistrationStep1(
 		return "", fmt.Errorf("invalid base64: %w", err)
 	}
 
-	server := svc.getServer()
+	server := svc.keys.shared()
 	request, err := server.Deserialize.RegistrationRequest(reqBytes)
 	if err != nil {
 		return "", fmt.Errorf("deserialization failed: %w", err)
 	}
 
-	pubKey, err := server.Deserialize.DecodeAkePublicKey(user_auth_global_config.OpaqueServerPublicKey())
-	if err != nil {
-		return "", fmt.Errorf("decode server public key failed: %w", err)
-	}
-
 	response := server.RegistrationResponse(
 		request,
-		pubKey,
+		svc.keys.akePublicKey,
 		[]byte(user.EncodeKey()),
-		user_auth_global_config.OpaqueServerSecretOprfSeed(),
+		svc.keys.oprfSeed,
 	)
 
 	return base64.RawURLEncoding.EncodeToString(response.Serialize()), nil
 }
 
+// RegistrationStep2 stores the new user's OPAQUE record together with its user groups,
+// in one write, so a user is never left registered without the roles it was granted.
 func (svc *DefaultOpaqueService) RegistrationStep2(
-	user user_auth_global_config.CoreUser, registrationRecordB64 string,
+	user user_auth_global_config.CoreUser,
+	registrationRecordB64 string,
+	userGroups []user_auth_global_config.UserGroupBinding,
 ) error {
 	exists, err := svc.store.Exists(user)
 	if err != nil {
@@ -81,19 +69,20 @@ func (svc *DefaultOpaqueService) RegistrationStep2(
 	if exists {
 		return errors.New("user already registered")
 	}
-	return svc.saveRecord(user, registrationRecordB64)
+	return svc.saveRecord(user, registrationRecordB64, userGroups)
 }
 
 func (svc *DefaultOpaqueService) saveRecord(
-	user user_auth_global_config.CoreUser, registrationRecordB64 string,
+	user user_auth_global_config.CoreUser,
+	registrationRecordB64 string,
+	userGroups []user_auth_global_config.UserGroupBinding,
 ) error {
 	recordBytes, err := base64.RawURLEncoding.DecodeString(registrationRecordB64)
 	if err != nil {
 		return fmt.Errorf("base64 decode error: %w", err)
 	}
 
-	server := svc.getServer()
-	record, err := server.Deserialize.RegistrationRecord(recordBytes)
+	record, err := svc.keys.shared().Deserialize.RegistrationRecord(recordBytes)
 	if err != nil {
 		return fmt.Errorf("record deserialization error: %w", err)
 	}
@@ -109,7 +98,7 @@ func (svc *DefaultOpaqueService) saveRecord(
 	// Create and persist the full user record
 	newRecord := &user_auth_global_config.OpaqueUserRecord{
 		OpaqueRecord: opaqueBytes,
-		UserGroups:   []user_auth_global_config.UserGroupBinding{}, // populated separately
+		UserGroups:   user_auth_global_config.DeduplicateRoles(userGroups),
 	}
 
 	data, err := user_auth_global_config.SerializeOpaqueUserRecord(newRecord)
@@ -130,41 +119,65 @@ func (svc *DefaultOpaqueService) LoginStep1(
 		return "", "", fmt.Errorf("decode KE1: %w", err)
 	}
 
-	data, err := svc.store.LoadRaw(user)
+	server, err := svc.keys.newServer()
+	if err != nil {
+		return "", "", err
+	}
+	ke1, err := server.Deserialize.KE1(startBytes)
 	if err != nil {
-		return "", "", fmt.Errorf("load user record: %w", err)
+		return "", "", fmt.Errorf("KE1 parse error: %w", err)
 	}
 
-	rec, err := user_auth_global_config.DeserializeOpaqueUserRecord(data)
+	clientRecord, err := svc.loginRecord(server, user)
 	if err != nil {
-		return "", "", fmt.Errorf("record deserialize: %w", err)
+		return "", "", err
 	}
 
-	server := svc.getServer()
-	opaqueRecord, err := server.Deserialize.RegistrationRecord(rec.OpaqueRecord)
+	ke2, err := server.LoginInit(ke1, clientRecord)
 	if err != nil {
-		return "", "", fmt.Errorf("registration record parse: %w", err)
+		return "", "", fmt.Errorf("LoginInit error: %w", err)
 	}
 
-	clientRecord := &opaque.ClientRecord{
-		CredentialIdentifier: []byte(user.EncodeKey()),
-		ClientIdentity:       []byte(user.EncodeKey()),
-		RegistrationRecord:   opaqueRecord,
+	loginResponse := base64.RawURLEncoding.EncodeToString(ke2.Serialize())
+	state := base64.RawURLEncoding.EncodeToString(server.SerializeState())
+	return loginResponse, state, nil
+}
+
+// loginRecord loads the CoreUser's registration record, or a fake one if they have
+// none, so that LoginStep1 does not reveal whether an account exists. The fake record
+// is derived either way, so both cases also take about as long.
+func (svc *DefaultOpaqueService) loginRecord(
+	server *opaque.Server, user user_auth_global_config.CoreUser,
+) (*opaque.ClientRecord, error) {
+	credentialIdentifier := []byte(user.EncodeKey())
+
+	fake, err := fakeRecord(svc.keys.conf, server, svc.keys.oprfSeed, credentialIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	data, err := svc.store.LoadRaw(user)
+	if errors.Is(err, opaque_store.ErrUserNotFound) {
+		return fake, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load user record: %w", err)
 	}
 
-	ke1, err := server.Deserialize.KE1(startBytes)
+	rec, err := user_auth_global_config.DeserializeOpaqueUserRecord(data)
 	if err != nil {
-		return "", "", fmt.Errorf("KE1 parse error: %w", err)
+		return nil, fmt.Errorf("record deserialize: %w", err)
 	}
 
-	ke2, err := server.LoginInit(ke1, clientRecord)
+	opaqueRecord, err := server.Deserialize.RegistrationRecord(rec.OpaqueRecord)
 	if err != nil {
-		return "", "", fmt.Errorf("LoginInit error: %w", err)
+		return nil, fmt.Errorf("registration record parse: %w", err)
 	}
 
-	loginResponse := base64.RawURLEncoding.EncodeToString(ke2.Serialize())
-	state := base64.RawURLEncoding.EncodeToString(server.SerializeState())
-	return loginResponse, state, nil
+	return &opaque.ClientRecord{
+		CredentialIdentifier: credentialIdentifier,
+		ClientIdentity:       credentialIdentifier,
+		RegistrationRecord:   opaqueRecord,
+	}, nil
 }
 
 func (svc *DefaultOpaqueService) LoginStep2(
@@ -180,7 +193,10 @@ func (svc *DefaultOpaqueService) LoginStep2(
 		return "", fmt.Errorf("decode serverState: %w", err)
 	}
 
-	server := svc.getServer()
+	server, err := svc.keys.newServer()
+	if err != nil {
+		return "", err
+	}
 	if err := server.SetAKEState(stateBytes); err != nil {
 		return "", fmt.Errorf("SetAKEState failed: %w", err)
 	}
@@ -209,5 +225,5 @@ func (svc *DefaultOpaqueService) PasswordResetStep1(
 func (svc *DefaultOpaqueService) PasswordResetStep2(
 	user user_auth_global_config.CoreUser, registrationRecordB64 string,
 ) error {
-	return svc.saveRecord(user, registrationRecordB64)
+	return svc.saveRecord(user, registrationRecordB64, nil)
 }
@@ -0,0 +1,74 @@
+package opaque_api
+
+import (
+	"testing"
+
+	"github.com/bytemare/opaque"
+	driver "github.com/drlzh/mng-app-user-auth-prot/internal/opaque_test_driver"
+	"github.com/drlzh/mng-app-user-auth-prot/opaque_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+)
+
+// Run with: go test -run '^$' -bench . -benchmem ./crypto/auth/opaque/opaque_api/
+// The client's Argon2id stretching stays outside the timed loops; these measure the
+// server's share of a login only.
+
+func benchService(b *testing.B) (*DefaultOpaqueService, uagc.CoreUser) {
+	svc, err := NewDefaultOpaqueService(opaque_store.NewGhettoAdapter(ghetto_db.New()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	user := uagc.CoreUser{TenantID: "dojo-a", UserID: "amanda"}
+	driver.Register(b, svc, user, "correct horse")
+	return svc, user
+}
+
+// benchServer keeps BenchmarkNewServer's result alive so the build is not optimised away.
+var benchServer *opaque.Server
+
+func BenchmarkNewServer(b *testing.B) {
+	svc, _ := benchService(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		server, err := svc.keys.newServer()
+		if err != nil {
+			b.Fatal(err)
+		}
+		benchServer = server
+	}
+}
+
+func BenchmarkRegistrationStep1(b *testing.B) {
+	svc, user := benchService(b)
+	req := driver.B64(driver.NewClient(b).RegistrationInit([]byte("correct horse")).Serialize())
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := svc.RegistrationStep1(user, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoginStep1(b *testing.B) {
+	svc, user := benchService(b)
+	ke1 := driver.B64(driver.NewClient(b).LoginInit([]byte("correct horse")).Serialize())
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := svc.LoginStep1(user, ke1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoginStep1UnknownUser(b *testing.B) {
+	svc, _ := benchService(b)
+	user := uagc.CoreUser{TenantID: "dojo-a", UserID: "nobody"}
+	ke1 := driver.B64(driver.NewClient(b).LoginInit([]byte("correct horse")).Serialize())
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := svc.LoginStep1(user, ke1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
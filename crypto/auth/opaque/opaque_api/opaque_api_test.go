@@ -0,0 +1,41 @@
+package opaque_api
+
+import (
+	"fmt"
+	"testing"
+
+	driver "github.com/drlzh/mng-app-user-auth-prot/internal/opaque_test_driver"
+	"github.com/drlzh/mng-app-user-auth-prot/opaque_store"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentLogins runs logins side by side on one service; run it with -race. Every
+// login must finish with the session key its own client derived.
+func TestConcurrentLogins(t *testing.T) {
+	svc, err := NewDefaultOpaqueService(opaque_store.NewGhettoAdapter(ghetto_db.New()))
+	require.NoError(t, err)
+
+	users := make([]uagc.CoreUser, 4)
+	for i := range users {
+		users[i] = uagc.CoreUser{TenantID: "dojo-a", UserID: fmt.Sprintf("student-%d", i)}
+		driver.Register(t, svc, users[i], "password "+users[i].UserID)
+	}
+
+	for i := 0; i < 16; i++ {
+		user := users[i%len(users)]
+		t.Run(fmt.Sprintf("login-%d", i), func(t *testing.T) {
+			t.Parallel()
+			client := driver.NewClient(t)
+			ke2, state, err := svc.LoginStep1(user, driver.B64(client.LoginInit([]byte("password "+user.UserID)).Serialize()))
+			require.NoError(t, err)
+			ke3, err := driver.LoginFinish(t, client, user, ke2)
+			require.NoError(t, err)
+
+			sessionKey, err := svc.LoginStep2(driver.B64(ke3), state)
+			require.NoError(t, err)
+			require.Equal(t, driver.B64(client.SessionKey()), sessionKey)
+		})
+	}
+}
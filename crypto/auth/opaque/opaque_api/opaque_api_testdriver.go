@@ -26,7 +26,10 @@ func TestDriver() {
 	db := ghetto_db.New()
 	db.CreateTable(opaque_store.OpaqueClientTable)
 	store := opaque_store.NewGhettoAdapter(db)
-	opaqueSvc := NewDefaultOpaqueService(store)
+	opaqueSvc, err := NewDefaultOpaqueService(store)
+	if err != nil {
+		log.Fatalln("❌ OPAQUE service setup failed:", err)
+	}
 
 	userIdentifier := "test-user@example.com"
 
@@ -43,7 +46,7 @@ func TestDriver() {
 	// Step 2: Receive registrationRecord
 	regRecord := readInput("Paste `registrationRecord` from RN client: ")
 
-	err = opaqueSvc.RegistrationStep2(userIdentifier, regRecord)
+	err = opaqueSvc.RegistrationStep2(userIdentifier, regRecord, nil)
 	if err != nil {
 		log.Fatalln("❌ RegistrationStep2 failed:", err)
 	}
@@ -0,0 +1,71 @@
+package opaque_api
+
+import (
+	"fmt"
+
+	group "github.com/bytemare/crypto"
+	"github.com/bytemare/opaque"
+	"github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// serverKeys is the server's OPAQUE configuration and key material, read from the
+// keystore and validated once when the service is built.
+type serverKeys struct {
+	conf       *opaque.Configuration
+	serverID   []byte
+	privateKey []byte
+	publicKey  []byte
+	oprfSeed   []byte
+
+	// akePublicKey is the server's public key decoded, as RegistrationResponse wants it.
+	akePublicKey *group.Element
+
+	// keyed holds the key material set once. It never runs a login itself, so its AKE
+	// state stays empty and it can be shared for registration and deserialization.
+	keyed *opaque.Server
+}
+
+func loadServerKeys(conf *opaque.Configuration) (*serverKeys, error) {
+	k := &serverKeys{
+		conf:       conf,
+		serverID:   user_auth_global_config.OpaqueServerId(),
+		privateKey: user_auth_global_config.OpaqueServerPrivateKey(),
+		publicKey:  user_auth_global_config.OpaqueServerPublicKey(),
+		oprfSeed:   user_auth_global_config.OpaqueServerSecretOprfSeed(),
+	}
+
+	// SetKeyMaterial runs the library's own checks on the key material
+	keyed, err := k.newServer()
+	if err != nil {
+		return nil, err
+	}
+
+	akePublicKey, err := keyed.Deserialize.DecodeAkePublicKey(k.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode OPAQUE server public key: %w", err)
+	}
+
+	k.akePublicKey = akePublicKey
+	k.keyed = keyed
+	return k, nil
+}
+
+// shared returns the keyed server for the steps that hold no AKE state: registration
+// responses and message deserialization.
+func (k *serverKeys) shared() *opaque.Server {
+	return k.keyed
+}
+
+// newServer returns a server for one login step. A server carries the AKE state of the
+// login it is running, so it is never shared between requests; each one is built from
+// the key bytes read at startup rather than from the keystore.
+func (k *serverKeys) newServer() (*opaque.Server, error) {
+	server, err := opaque.NewServer(k.conf)
+	if err != nil {
+		return nil, fmt.Errorf("OPAQUE server instantiation failed: %w", err)
+	}
+	if err := server.SetKeyMaterial(k.serverID, k.privateKey, k.publicKey, k.oprfSeed); err != nil {
+		return nil, fmt.Errorf("OPAQUE server key material error: %w", err)
+	}
+	return server, nil
+}
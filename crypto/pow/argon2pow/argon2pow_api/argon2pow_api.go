@@ -0,0 +1,94 @@
+package argon2pow_api
+
+import (
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/argon2pow/argon2pow_impl"
+	"github.com/drlzh/mng-app-user-auth-prot/pow_store"
+)
+
+const (
+	// Sized so one attempt takes tens of milliseconds on a mid-range phone
+	DefaultMemory     = 16 * 1024 // KiB
+	DefaultIterations = 1
+)
+
+// Config defines the creation parameters for an Argon2id challenge.
+// Memory and Iterations are signed into the token; zero values fall back to the defaults.
+type Config struct {
+	Subject    string
+	Difficulty int
+	TTL        time.Duration
+	Memory     uint32 // KiB
+	Iterations uint32
+}
+
+// Challenge represents an Argon2id challenge to be solved by a client.
+type Challenge struct {
+	Token string // Full token string, ready to send to client
+}
+
+// CreateChallenge generates a new Argon2id challenge token.
+func CreateChallenge(cfg Config) (*Challenge, error) {
+	if cfg.Memory == 0 {
+		cfg.Memory = DefaultMemory
+	}
+	if cfg.Iterations == 0 {
+		cfg.Iterations = DefaultIterations
+	}
+	a, err := argon2pow_impl.New(cfg.Subject, cfg.Difficulty, cfg.Memory, cfg.Iterations, cfg.TTL)
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		Token: a.String(),
+	}, nil
+}
+
+// SolveChallenge is intended for client-side simulation/testing.
+// It solves a challenge locally and returns the full token.
+func SolveChallenge(token string, maxBits int) (string, error) {
+	a, err := argon2pow_impl.Parse(token)
+	if err != nil {
+		return "", err
+	}
+	if err := a.Solve(maxBits); err != nil {
+		return "", err
+	}
+	return a.String(), nil
+}
+
+// VerifyToken verifies both the signature and the PoW of a token.
+func VerifyToken(token string, expectedSubject string) error {
+	a, err := argon2pow_impl.Parse(token)
+	if err != nil {
+		return err
+	}
+	return a.Verify(expectedSubject)
+}
+
+// VerifyAndSpendToken verifies the token like VerifyToken and then records one use of it
+// in seen, keyed on the token nonce plus scope. See hashcash_api.VerifyAndSpendToken.
+func VerifyAndSpendToken(token, expectedSubject, scope string, seen pow_store.SeenNonceStore, maxUses int) error {
+	a, err := argon2pow_impl.Parse(token)
+	if err != nil {
+		return err
+	}
+	if err := a.Verify(expectedSubject); err != nil {
+		return err
+	}
+	if maxUses < 1 {
+		maxUses = 1
+	}
+	return seen.MarkSeen(a.Nonce+argon2pow_impl.Separator+scope, a.Timestamp, maxUses)
+}
+
+// TokenDifficulty returns the (signed) difficulty a token was issued with.
+// It does not verify the token.
+func TokenDifficulty(token string) (int, error) {
+	a, err := argon2pow_impl.Parse(token)
+	if err != nil {
+		return 0, err
+	}
+	return a.Difficulty, nil
+}
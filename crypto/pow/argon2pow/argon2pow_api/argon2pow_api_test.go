@@ -0,0 +1,57 @@
+package argon2pow_api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/pow_store"
+	"github.com/stretchr/testify/require"
+)
+
+func newSolvedToken(t *testing.T, subject string) string {
+	challenge, err := CreateChallenge(Config{
+		Subject:    subject,
+		Difficulty: 3,
+		TTL:        time.Minute,
+		Memory:     1024,
+		Iterations: 1,
+	})
+	require.NoError(t, err)
+
+	solvedToken, err := SolveChallenge(challenge.Token, 8)
+	require.NoError(t, err)
+	return solvedToken
+}
+
+func TestArgon2PoWFullFlow(t *testing.T) {
+	token := newSolvedToken(t, "test@example.com")
+
+	require.NoError(t, VerifyToken(token, "test@example.com"))
+	require.Error(t, VerifyToken(token, "other@example.com"))
+
+	difficulty, err := TokenDifficulty(token)
+	require.NoError(t, err)
+	require.Equal(t, 3, difficulty)
+}
+
+func TestArgon2PoWSignedParams(t *testing.T) {
+	token := newSolvedToken(t, "attacker@example.com")
+	parts := strings.Split(token, ":")
+	require.Len(t, parts, 9)
+
+	// Changing difficulty, memory or iterations must break the signature
+	for i, value := range map[int]string{1: "1", 2: "2048", 3: "2"} {
+		tampered := append([]string(nil), parts...)
+		tampered[i] = value
+		require.Error(t, VerifyToken(strings.Join(tampered, ":"), "attacker@example.com"), "field %d", i)
+	}
+}
+
+func TestArgon2PoWReplay(t *testing.T) {
+	token := newSolvedToken(t, "replay@example.com")
+	seen := pow_store.NewMemoryAdapter()
+
+	require.NoError(t, VerifyAndSpendToken(token, "replay@example.com", "LOGIN", seen, 1))
+	require.ErrorIs(t, VerifyAndSpendToken(token, "replay@example.com", "LOGIN", seen, 1), pow_store.ErrNonceExhausted)
+}
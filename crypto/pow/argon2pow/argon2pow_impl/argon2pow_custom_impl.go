@@ -0,0 +1,230 @@
+package argon2pow_impl
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/hashing/argon2/argon2_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_impl"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
+	"github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// Every attempt costs a full Argon2id evaluation, so difficulties are far lower than
+// hashcash: 2^Difficulty attempts on average instead of 2^Difficulty SHA-256 calls.
+const (
+	Version       = "argon2id.1"
+	Separator     = ":"
+	MaxDifficulty = 16
+
+	Threads    = 1  // Fixed so phones and servers derive the same output
+	HashLength = 32 // Bytes of Argon2id output checked for leading zero bits
+
+	MinMemory     = 1024       // KiB
+	MaxMemory     = 256 * 1024 // KiB, bounds what a verifier can be asked to spend
+	MaxIterations = 10
+)
+
+var (
+	ErrInvalidFormat      = errors.New("invalid argon2 PoW format")
+	ErrInvalidVersion     = errors.New("invalid argon2 PoW version")
+	ErrInvalidDifficulty  = errors.New("difficulty out of range")
+	ErrInvalidParams      = errors.New("argon2 parameters out of range")
+	ErrInvalidTimestamp   = errors.New("invalid timestamp")
+	ErrExpired            = errors.New("argon2 PoW has expired")
+	ErrSubjectMismatch    = errors.New("subject mismatch")
+	ErrSignatureInvalid   = errors.New("invalid Ed448 signature")
+	ErrSignatureMalformed = errors.New("malformed signature")
+	ErrInvalidNonce       = errors.New("invalid nonce")
+	ErrInvalidPoW         = errors.New("invalid proof-of-work")
+)
+
+type Argon2PoW struct {
+	Version    string
+	Difficulty int
+	Memory     uint32 // KiB
+	Iterations uint32
+	Timestamp  time.Time // Expiry
+	Subject    string
+	Ext        string // base64-encoded Ed448 signature
+	Nonce      string // base64url-encoded random nonce, used as Argon2id salt
+	Counter    string // base64url-encoded solution (client computes this)
+}
+
+// New creates a new Argon2id challenge and embeds the signature over all parameters in `Ext`.
+func New(subject string, difficulty int, memory, iterations uint32, ttl time.Duration) (*Argon2PoW, error) {
+	if difficulty <= 0 || difficulty > MaxDifficulty {
+		return nil, ErrInvalidDifficulty
+	}
+	if !validParams(memory, iterations) {
+		return nil, ErrInvalidParams
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("nonce generation failed: %w", err)
+	}
+
+	a := &Argon2PoW{
+		Version:    Version,
+		Difficulty: difficulty,
+		Memory:     memory,
+		Iterations: iterations,
+		Timestamp:  time.Now().Add(ttl).UTC().Truncate(time.Second),
+		Subject:    subject,
+		Nonce:      base64.RawURLEncoding.EncodeToString(nonce),
+	}
+
+	sig, err := ed448_api.SignWithContext(user_auth_global_config.Ed448HashcashPrivateKey(), []byte(a.header("")), sigctx.Argon2PoW)
+	if err != nil {
+		return nil, fmt.Errorf("signing failed: %w", err)
+	}
+
+	a.Ext = base64.RawURLEncoding.EncodeToString(sig[:])
+	return a, nil
+}
+
+func validParams(memory, iterations uint32) bool {
+	return memory >= MinMemory && memory <= MaxMemory && iterations >= 1 && iterations <= MaxIterations
+}
+
+// header joins all fields before the counter; ext is "" for the signed form
+func (a *Argon2PoW) header(ext string) string {
+	return strings.Join([]string{
+		a.Version,
+		strconv.Itoa(a.Difficulty),
+		strconv.FormatUint(uint64(a.Memory), 10),
+		strconv.FormatUint(uint64(a.Iterations), 10),
+		strconv.FormatInt(a.Timestamp.Unix(), 10),
+		a.Subject,
+		ext,
+		a.Nonce,
+	}, Separator)
+}
+
+// String returns the full token including the counter.
+func (a *Argon2PoW) String() string {
+	return a.header(a.Ext) + Separator + a.Counter
+}
+
+func (a *Argon2PoW) attempt(salt []byte, base string, counter string) ([]byte, error) {
+	return argon2_api.DeriveKeyArgon2id([]byte(base+Separator+counter), salt, a.Iterations, a.Memory, Threads, HashLength)
+}
+
+// Solve brute-forces the counter to satisfy the difficulty.
+func (a *Argon2PoW) Solve(maxBits int) error {
+	if a.Difficulty > maxBits || a.Difficulty > MaxDifficulty {
+		return ErrInvalidDifficulty
+	}
+	salt, err := base64.RawURLEncoding.DecodeString(a.Nonce)
+	if err != nil || len(salt) == 0 {
+		return ErrInvalidNonce
+	}
+
+	var counter uint32
+	buf := make([]byte, 4)
+	base := a.header(a.Ext)
+	for {
+		binary.LittleEndian.PutUint32(buf, counter)
+		a.Counter = base64.RawURLEncoding.EncodeToString(buf)
+
+		hash, err := a.attempt(salt, base, a.Counter)
+		if err != nil {
+			return err
+		}
+		if pow_impl.LeadingZeroBits(hash, a.Difficulty) {
+			return nil
+		}
+		counter++
+	}
+}
+
+// Verify checks the signature first (so unsigned parameters never reach Argon2id) and then the PoW.
+func (a *Argon2PoW) Verify(expectedSubject string) error {
+	if a.Version != Version {
+		return ErrInvalidVersion
+	}
+	if a.Difficulty < 0 || a.Difficulty > MaxDifficulty {
+		return ErrInvalidDifficulty
+	}
+	if !validParams(a.Memory, a.Iterations) {
+		return ErrInvalidParams
+	}
+	if a.Subject != expectedSubject {
+		return ErrSubjectMismatch
+	}
+	if time.Now().After(a.Timestamp) {
+		return ErrExpired
+	}
+
+	sigBytes, err := base64.RawURLEncoding.DecodeString(a.Ext)
+	if err != nil || len(sigBytes) != ed448_api.SignatureSize {
+		return ErrSignatureMalformed
+	}
+	if !ed448_api.VerifyWithContext(user_auth_global_config.Ed448HashcashPublicKey(), []byte(a.header("")), sigBytes, sigctx.Argon2PoW) {
+		return ErrSignatureInvalid
+	}
+
+	salt, err := base64.RawURLEncoding.DecodeString(a.Nonce)
+	if err != nil || len(salt) == 0 {
+		return ErrInvalidNonce
+	}
+	hash, err := a.attempt(salt, a.header(a.Ext), a.Counter)
+	if err != nil {
+		return err
+	}
+	if !pow_impl.LeadingZeroBits(hash, a.Difficulty) {
+		return ErrInvalidPoW
+	}
+
+	return nil
+}
+
+// Parse decodes a full token string into a struct.
+func Parse(s string) (*Argon2PoW, error) {
+	parts := strings.Split(s, Separator)
+	if len(parts) != 9 {
+		return nil, ErrInvalidFormat
+	}
+	if parts[0] != Version {
+		return nil, ErrInvalidVersion
+	}
+
+	bits, err := strconv.Atoi(parts[1])
+	if err != nil || bits < 0 || bits > MaxDifficulty {
+		return nil, ErrInvalidDifficulty
+	}
+
+	memory, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+	iterations, err := strconv.ParseUint(parts[3], 10, 32)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	tsUnix, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidTimestamp
+	}
+
+	return &Argon2PoW{
+		Version:    parts[0],
+		Difficulty: bits,
+		Memory:     uint32(memory),
+		Iterations: uint32(iterations),
+		Timestamp:  time.Unix(tsUnix, 0).UTC(),
+		Subject:    parts[5],
+		Ext:        parts[6],
+		Nonce:      parts[7],
+		Counter:    parts[8],
+	}, nil
+}
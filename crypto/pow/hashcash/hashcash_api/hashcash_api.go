@@ -2,6 +2,7 @@ package hashcash_api
 
 import (
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_impl"
+	"github.com/drlzh/mng-app-user-auth-prot/pow_store"
 	"time"
 )
 
@@ -49,3 +50,31 @@ func VerifyToken(token string, expectedSubject string) error {
 	}
 	return h.Verify(expectedSubject)
 }
+
+// VerifyAndSpendToken verifies the token like VerifyToken and then records one use of it
+// in seen, keyed on the token nonce plus scope (e.g. the OPAQUE subcommand).
+// Once the same (nonce, scope) has been spent maxUses times the token is rejected.
+func VerifyAndSpendToken(token, expectedSubject, scope string, seen pow_store.SeenNonceStore, maxUses int) error {
+	h, err := hashcash_impl.Parse(token)
+	if err != nil {
+		return err
+	}
+	if err := h.Verify(expectedSubject); err != nil {
+		return err
+	}
+	if maxUses < 1 {
+		maxUses = 1
+	}
+	// Timestamp is the expiry; past it Verify rejects the token anyway
+	return seen.MarkSeen(h.Nonce+hashcash_impl.Separator+scope, h.Timestamp, maxUses)
+}
+
+// TokenDifficulty returns the (signed) difficulty a token was issued with.
+// It does not verify the token.
+func TokenDifficulty(token string) (int, error) {
+	h, err := hashcash_impl.Parse(token)
+	if err != nil {
+		return 0, err
+	}
+	return h.Difficulty, nil
+}
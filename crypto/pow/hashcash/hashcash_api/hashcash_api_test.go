@@ -2,6 +2,7 @@ package hashcash_api
 
 import (
 	"encoding/base64"
+	"github.com/drlzh/mng-app-user-auth-prot/pow_store"
 	"github.com/stretchr/testify/require"
 	"strings"
 	"testing"
@@ -75,3 +76,28 @@ func TestHashcashTampering(t *testing.T) {
 	err = VerifyToken(tamperedPoWToken, subject)
 	require.Error(t, err, "expected PoW validation failure")
 }
+
+func TestHashcashReplay(t *testing.T) {
+	subject := "replay@example.com"
+
+	challenge, err := CreateChallenge(Config{
+		Subject:    subject,
+		Difficulty: 8,
+		TTL:        time.Minute,
+	})
+	require.NoError(t, err)
+
+	solvedToken, err := SolveChallenge(challenge.Token, 24)
+	require.NoError(t, err)
+
+	seen := pow_store.NewMemoryAdapter()
+
+	// One use per scope
+	require.NoError(t, VerifyAndSpendToken(solvedToken, subject, "STEP_ONE", seen, 1))
+	require.ErrorIs(t, VerifyAndSpendToken(solvedToken, subject, "STEP_ONE", seen, 1), pow_store.ErrNonceExhausted)
+	require.NoError(t, VerifyAndSpendToken(solvedToken, subject, "STEP_TWO", seen, 1))
+
+	// A bad token must not consume anything
+	require.Error(t, VerifyAndSpendToken(solvedToken, "someone-else", "STEP_THREE", seen, 1))
+	require.NoError(t, VerifyAndSpendToken(solvedToken, subject, "STEP_THREE", seen, 1))
+}
@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/pow_impl"
+	sigctx "github.com/drlzh/mng-app-user-auth-prot/signing_contexts"
 	"strconv"
 	"strings"
 	"time"
@@ -64,7 +66,7 @@ func New(subject string, difficulty int, ttl time.Duration) (*Hashcash, error) {
 	}
 
 	// Sign canonical header (no counter yet)
-	sig, err := ed448_api.Sign(user_auth_global_config.Ed448HashcashPrivateKey(), []byte(h.unsignedHeader()))
+	sig, err := ed448_api.SignWithContext(user_auth_global_config.Ed448HashcashPrivateKey(), []byte(h.unsignedHeader()), sigctx.Hashcash)
 	if err != nil {
 		return nil, fmt.Errorf("signing failed: %w", err)
 	}
@@ -121,7 +123,7 @@ func (h *Hashcash) Solve(maxBits int) error {
 		full := base + Separator + counterB64
 		hash := sha256.Sum256([]byte(full))
 
-		if leadingZeroBits(hash[:bytesToCheck], bits) {
+		if pow_impl.LeadingZeroBits(hash[:bytesToCheck], bits) {
 			return nil
 		}
 		counter++
@@ -150,7 +152,7 @@ func (h *Hashcash) Verify(expectedSubject string) error {
 	}
 	sig := ed448_api.Signature(sigBytes)
 
-	if !ed448_api.Verify(sig, []byte(h.unsignedHeader()), user_auth_global_config.Ed448HashcashPublicKey()) {
+	if !ed448_api.VerifyWithContext(user_auth_global_config.Ed448HashcashPublicKey(), []byte(h.unsignedHeader()), sig, sigctx.Hashcash) {
 		return ErrSignatureInvalid
 	}
 
@@ -160,7 +162,7 @@ func (h *Hashcash) Verify(expectedSubject string) error {
 	bits := h.Difficulty
 	bytesToCheck := (bits + 7) / 8
 
-	if !leadingZeroBits(hash[:bytesToCheck], bits) {
+	if !pow_impl.LeadingZeroBits(hash[:bytesToCheck], bits) {
 		return ErrInvalidPoW
 	}
 
@@ -197,20 +199,3 @@ func Parse(s string) (*Hashcash, error) {
 		Counter:    parts[6],
 	}, nil
 }
-
-// leadingZeroBits checks that the hash has the required number of leading 0 bits.
-func leadingZeroBits(hash []byte, bits int) bool {
-	full := bits / 8
-	rem := bits % 8
-
-	for i := 0; i < full; i++ {
-		if hash[i] != 0 {
-			return false
-		}
-	}
-	if rem == 0 {
-		return true
-	}
-	// top rem bits of next byte must be zero
-	return (hash[full] >> (8 - rem)) == 0
-}
@@ -0,0 +1,130 @@
+package pow_api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/argon2pow/argon2pow_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/argon2pow/argon2pow_impl"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_impl"
+	"github.com/drlzh/mng-app-user-auth-prot/pow_store"
+)
+
+// Schemes a server can hand out. The verifier only accepts tokens of its configured
+// scheme, so clients can't fall back to the GPU-friendly one.
+const (
+	SchemeHashcash = "hashcash" // SHA-256
+	SchemeArgon2id = "argon2id" // Memory-hard
+)
+
+// Config defines the creation parameters for a challenge of either scheme.
+// Argon2Memory and Argon2Iterations are ignored for hashcash.
+type Config struct {
+	Scheme           string
+	Subject          string
+	Difficulty       int
+	TTL              time.Duration
+	Argon2Memory     uint32 // KiB
+	Argon2Iterations uint32
+}
+
+// Challenge represents a PoW challenge to be solved by a client.
+type Challenge struct {
+	Scheme string
+	Token  string
+}
+
+// SubjectBindingSeparator joins the parts of a bound subject. It must not be either
+// scheme's token separator (hashcash_impl.Separator, argon2pow_impl.Separator) and must
+// not occur in base64url trace IDs.
+const SubjectBindingSeparator = "|"
+
+// BindSubject scopes a PoW subject to one PSP trace and one intended operation, for
+// either scheme. The subject is part of the signed header, so a token solved for one
+// trace/intent fails VerifyToken for any other.
+func BindSubject(subject, traceID, intent string) string {
+	return strings.Join([]string{subject, intent, traceID}, SubjectBindingSeparator)
+}
+
+// MaxDifficulty returns the highest difficulty the scheme accepts.
+func MaxDifficulty(scheme string) int {
+	if scheme == SchemeArgon2id {
+		return argon2pow_impl.MaxDifficulty
+	}
+	return hashcash_impl.MaxDifficulty
+}
+
+// CreateChallenge generates a new challenge token of cfg.Scheme (hashcash if empty).
+func CreateChallenge(cfg Config) (*Challenge, error) {
+	switch cfg.Scheme {
+	case "", SchemeHashcash:
+		chal, err := hashcash_api.CreateChallenge(hashcash_api.Config{
+			Subject:    cfg.Subject,
+			Difficulty: cfg.Difficulty,
+			TTL:        cfg.TTL,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Challenge{Scheme: SchemeHashcash, Token: chal.Token}, nil
+
+	case SchemeArgon2id:
+		chal, err := argon2pow_api.CreateChallenge(argon2pow_api.Config{
+			Subject:    cfg.Subject,
+			Difficulty: cfg.Difficulty,
+			TTL:        cfg.TTL,
+			Memory:     cfg.Argon2Memory,
+			Iterations: cfg.Argon2Iterations,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Challenge{Scheme: SchemeArgon2id, Token: chal.Token}, nil
+
+	default:
+		return nil, unknownScheme(cfg.Scheme)
+	}
+}
+
+// VerifyToken verifies both the signature and the PoW of a token of the given scheme.
+func VerifyToken(scheme, token, expectedSubject string) error {
+	switch scheme {
+	case "", SchemeHashcash:
+		return hashcash_api.VerifyToken(token, expectedSubject)
+	case SchemeArgon2id:
+		return argon2pow_api.VerifyToken(token, expectedSubject)
+	default:
+		return unknownScheme(scheme)
+	}
+}
+
+// VerifyAndSpendToken verifies the token and records one use of it per scope.
+func VerifyAndSpendToken(scheme, token, expectedSubject, scope string, seen pow_store.SeenNonceStore, maxUses int) error {
+	switch scheme {
+	case "", SchemeHashcash:
+		return hashcash_api.VerifyAndSpendToken(token, expectedSubject, scope, seen, maxUses)
+	case SchemeArgon2id:
+		return argon2pow_api.VerifyAndSpendToken(token, expectedSubject, scope, seen, maxUses)
+	default:
+		return unknownScheme(scheme)
+	}
+}
+
+// TokenDifficulty returns the (signed) difficulty a token was issued with.
+// It does not verify the token.
+func TokenDifficulty(scheme, token string) (int, error) {
+	switch scheme {
+	case "", SchemeHashcash:
+		return hashcash_api.TokenDifficulty(token)
+	case SchemeArgon2id:
+		return argon2pow_api.TokenDifficulty(token)
+	default:
+		return 0, unknownScheme(scheme)
+	}
+}
+
+func unknownScheme(scheme string) error {
+	return fmt.Errorf("unknown PoW scheme %q", scheme)
+}
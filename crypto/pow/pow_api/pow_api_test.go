@@ -0,0 +1,44 @@
+package pow_api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/argon2pow/argon2pow_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/pow/hashcash/hashcash_api"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSubject     = "TEST_SUBJECT"
+	testIntentLogin = "LOGIN"
+	testIntentReset = "RESET"
+)
+
+func TestBoundSubject(t *testing.T) {
+	solvers := map[string]func(token string) (string, error){
+		SchemeHashcash: func(token string) (string, error) { return hashcash_api.SolveChallenge(token, 24) },
+		SchemeArgon2id: func(token string) (string, error) { return argon2pow_api.SolveChallenge(token, 8) },
+	}
+	bound := BindSubject(testSubject, "trace-a", testIntentLogin)
+
+	for scheme, solve := range solvers {
+		t.Run(scheme, func(t *testing.T) {
+			challenge, err := CreateChallenge(Config{
+				Scheme:           scheme,
+				Subject:          bound,
+				Difficulty:       3,
+				TTL:              time.Minute,
+				Argon2Memory:     1024,
+				Argon2Iterations: 1,
+			})
+			require.NoError(t, err)
+			token, err := solve(challenge.Token)
+			require.NoError(t, err)
+
+			require.NoError(t, VerifyToken(scheme, token, bound))
+			require.Error(t, VerifyToken(scheme, token, BindSubject(testSubject, "trace-b", testIntentLogin)))
+			require.Error(t, VerifyToken(scheme, token, BindSubject(testSubject, "trace-a", testIntentReset)))
+		})
+	}
+}
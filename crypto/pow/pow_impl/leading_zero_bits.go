@@ -0,0 +1,19 @@
+// Package pow_impl holds the pieces the PoW schemes (hashcash, argon2pow) share.
+package pow_impl
+
+// LeadingZeroBits checks that the hash has the required number of leading 0 bits.
+func LeadingZeroBits(hash []byte, bits int) bool {
+	full := bits / 8
+	rem := bits % 8
+
+	for i := 0; i < full; i++ {
+		if hash[i] != 0 {
+			return false
+		}
+	}
+	if rem == 0 {
+		return true
+	}
+	// top rem bits of next byte must be zero
+	return (hash[full] >> (8 - rem)) == 0
+}
@@ -0,0 +1,44 @@
+package delegation_store
+
+import (
+	"errors"
+	"net/url"
+
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+var (
+	ErrDelegationNotFound = errors.New("delegated entitlement not found")
+	ErrDelegationExists   = errors.New("delegated entitlement already exists")
+)
+
+// DelegationStore keeps signed delegated entitlements so they can be looked up at
+// authorization time and revoked early. The store is not trusted with their content:
+// readers verify the signed record in Encoded before honouring it.
+type DelegationStore interface {
+	Put(rec StoredDelegation) error
+	Get(entitlementID string) (*StoredDelegation, error)
+
+	// ListForDelegate returns the unrevoked records for delegateKey that have not
+	// expired at nowUnix (records not yet in force are included).
+	ListForDelegate(delegateKey string, nowUnix int64) ([]StoredDelegation, error)
+
+	// Revoke stamps the record revoked; revoking twice keeps the first timestamp.
+	Revoke(entitlementID string, atUnix int64) error
+}
+
+// StoredDelegation is the value persisted per delegated entitlement.
+type StoredDelegation struct {
+	EntitlementID          string `json:"entitlement_id"`
+	IssuerKey              string `json:"issuer_key"`   // UserKey(issuer)
+	DelegateKey            string `json:"delegate_key"` // UserKey(delegate)
+	NotAfterUnixTimestamp  int64  `json:"not_after_unix_timestamp"`
+	RevokedAtUnixTimestamp int64  `json:"revoked_at_unix_timestamp,omitempty"` // 0 = in force
+	Encoded                []byte `json:"encoded"`                             // Signed entitlement, JSON
+}
+
+// UserKey is the index key of a UniqueUser.
+func UserKey(u uagc.UniqueUser) string {
+	encode := url.PathEscape
+	return encode(u.TenantID) + "|" + encode(u.UserGroupID) + "|" + encode(u.UserID) + "|" + encode(u.SubID)
+}
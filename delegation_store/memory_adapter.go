@@ -0,0 +1,61 @@
+package delegation_store
+
+import "sync"
+
+// MemoryAdapter is a process-local DelegationStore. Use PgAdapter when more than one
+// auth server instance sits behind the load balancer.
+type MemoryAdapter struct {
+	mu      sync.Mutex
+	records map[string]StoredDelegation
+}
+
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{records: make(map[string]StoredDelegation)}
+}
+
+func (a *MemoryAdapter) Put(rec StoredDelegation) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.records[rec.EntitlementID]; ok {
+		return ErrDelegationExists
+	}
+	a.records[rec.EntitlementID] = rec
+	return nil
+}
+
+func (a *MemoryAdapter) Get(entitlementID string) (*StoredDelegation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec, ok := a.records[entitlementID]
+	if !ok {
+		return nil, ErrDelegationNotFound
+	}
+	return &rec, nil
+}
+
+func (a *MemoryAdapter) ListForDelegate(delegateKey string, nowUnix int64) ([]StoredDelegation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var out []StoredDelegation
+	for _, rec := range a.records {
+		if rec.DelegateKey == delegateKey && rec.NotAfterUnixTimestamp > nowUnix && rec.RevokedAtUnixTimestamp == 0 {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+func (a *MemoryAdapter) Revoke(entitlementID string, atUnix int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec, ok := a.records[entitlementID]
+	if !ok {
+		return ErrDelegationNotFound
+	}
+	if rec.RevokedAtUnixTimestamp == 0 {
+		rec.RevokedAtUnixTimestamp = atUnix
+		a.records[entitlementID] = rec
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package delegation_store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+/*
+CREATE TABLE delegated_entitlement (
+    entitlement_id TEXT PRIMARY KEY,
+    issuer_key TEXT NOT NULL,
+    delegate_key TEXT NOT NULL,
+    not_after BIGINT NOT NULL,
+    revoked_at BIGINT NOT NULL DEFAULT 0,
+    encoded BYTEA NOT NULL
+);
+CREATE INDEX delegated_entitlement_delegate ON delegated_entitlement (delegate_key, not_after);
+*/
+
+type PgAdapter struct {
+	db    *sql.DB
+	table string
+}
+
+func NewPgAdapter(db *sql.DB) *PgAdapter {
+	return &PgAdapter{db: db, table: "delegated_entitlement"}
+}
+
+func (a *PgAdapter) Put(rec StoredDelegation) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (entitlement_id, issuer_key, delegate_key, not_after, revoked_at, encoded)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING
+	`, a.table)
+	res, err := a.db.ExecContext(context.Background(), query,
+		rec.EntitlementID, rec.IssuerKey, rec.DelegateKey, rec.NotAfterUnixTimestamp, rec.RevokedAtUnixTimestamp, rec.Encoded)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDelegationExists
+	}
+	return nil
+}
+
+func (a *PgAdapter) Get(entitlementID string) (*StoredDelegation, error) {
+	query := fmt.Sprintf(`
+		SELECT entitlement_id, issuer_key, delegate_key, not_after, revoked_at, encoded
+		FROM %s WHERE entitlement_id = $1
+	`, a.table)
+	var rec StoredDelegation
+	err := a.db.QueryRowContext(context.Background(), query, entitlementID).Scan(
+		&rec.EntitlementID, &rec.IssuerKey, &rec.DelegateKey, &rec.NotAfterUnixTimestamp, &rec.RevokedAtUnixTimestamp, &rec.Encoded)
+	if err == sql.ErrNoRows {
+		return nil, ErrDelegationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (a *PgAdapter) ListForDelegate(delegateKey string, nowUnix int64) ([]StoredDelegation, error) {
+	query := fmt.Sprintf(`
+		SELECT entitlement_id, issuer_key, delegate_key, not_after, revoked_at, encoded
+		FROM %s WHERE delegate_key = $1 AND not_after > $2 AND revoked_at = 0
+	`, a.table)
+	rows, err := a.db.QueryContext(context.Background(), query, delegateKey, nowUnix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StoredDelegation
+	for rows.Next() {
+		var rec StoredDelegation
+		if err := rows.Scan(&rec.EntitlementID, &rec.IssuerKey, &rec.DelegateKey, &rec.NotAfterUnixTimestamp, &rec.RevokedAtUnixTimestamp, &rec.Encoded); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (a *PgAdapter) Revoke(entitlementID string, atUnix int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s SET revoked_at = $2
+		WHERE entitlement_id = $1 AND revoked_at = 0
+	`, a.table)
+	res, err := a.db.ExecContext(context.Background(), query, entitlementID, atUnix)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	// Nothing updated: either already revoked or unknown
+	_, err = a.Get(entitlementID)
+	return err
+}
@@ -6,6 +6,7 @@ toolchain go1.24.4
 
 require (
 	github.com/biscuit-auth/biscuit-go/v2 v2.2.0
+	github.com/bytemare/crypto v0.4.3
 	github.com/bytemare/opaque v0.10.0
 	github.com/cloudflare/circl v1.6.1
 	github.com/lib/pq v1.10.9
@@ -18,7 +19,6 @@ require (
 	filippo.io/edwards25519 v1.0.0 // indirect
 	filippo.io/nistec v0.0.2 // indirect
 	github.com/alecthomas/participle/v2 v2.0.0 // indirect
-	github.com/bytemare/crypto v0.4.3 // indirect
 	github.com/bytemare/hash v0.1.5 // indirect
 	github.com/bytemare/hash2curve v0.1.3 // indirect
 	github.com/bytemare/ksf v0.4.0 // indirect
@@ -0,0 +1,103 @@
+package grant_store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+)
+
+const (
+	ghettoConsumedTable = "auth_grant_consumed"
+	ghettoNonceTable    = "auth_grant_consumed_nonce"
+	ghettoRevokedTable  = "auth_grant_revoked"
+)
+
+type GhettoAdapter struct {
+	db *ghetto_db.GhettoDB
+	mu sync.Mutex // Consume spans three tables, so it and Revoke/Release serialise here
+}
+
+func NewGhettoAdapter(db *ghetto_db.GhettoDB) *GhettoAdapter {
+	db.CreateTable(ghettoConsumedTable)
+	db.CreateTable(ghettoNonceTable)
+	db.CreateTable(ghettoRevokedTable)
+	return &GhettoAdapter{db: db}
+}
+
+// Consume checks revocation and writes both the GrantID and the Nonce row under a.mu,
+// so a concurrent Revoke is ordered either before or after it and no half-written
+// consumption is left behind.
+func (a *GhettoAdapter) Consume(grantID, nonce string, expiresAtUnix int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	revoked, err := a.IsRevoked(grantID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrGrantRevoked
+	}
+	consumed, err := a.IsConsumed(grantID, nonce)
+	if err != nil {
+		return err
+	}
+	if consumed {
+		return ErrGrantAlreadyConsumed
+	}
+
+	rec, err := json.Marshal(ConsumedGrantRecord{
+		GrantID:                 grantID,
+		Nonce:                   nonce,
+		ConsumedAtUnixTimestamp: time.Now().Unix(),
+		ExpiresAtUnixTimestamp:  expiresAtUnix,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal consumed grant record: %w", err)
+	}
+
+	if err := a.db.Insert(ghettoConsumedTable, grantID, rec); err != nil {
+		return err
+	}
+	if err := a.db.Insert(ghettoNonceTable, nonce, []byte(grantID)); err != nil {
+		_ = a.db.Delete(ghettoConsumedTable, grantID)
+		return err
+	}
+	return nil
+}
+
+func (a *GhettoAdapter) IsConsumed(grantID, nonce string) (bool, error) {
+	byID, err := a.db.Exists(ghettoConsumedTable, grantID)
+	if err != nil || byID {
+		return byID, err
+	}
+	return a.db.Exists(ghettoNonceTable, nonce)
+}
+
+// Release removes both rows Consume wrote; rows it did not write are left alone.
+func (a *GhettoAdapter) Release(grantID, nonce string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	owner, err := a.db.Get(ghettoNonceTable, nonce)
+	if err != nil || string(owner) != grantID {
+		return fmt.Errorf("auth grant %s is not consumed with this nonce", grantID)
+	}
+	if err := a.db.Delete(ghettoNonceTable, nonce); err != nil {
+		return err
+	}
+	return a.db.Delete(ghettoConsumedTable, grantID)
+}
+
+func (a *GhettoAdapter) Revoke(grantID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.db.Upsert(ghettoRevokedTable, grantID, []byte(fmt.Sprint(time.Now().Unix())))
+}
+
+func (a *GhettoAdapter) IsRevoked(grantID string) (bool, error) {
+	return a.db.Exists(ghettoRevokedTable, grantID)
+}
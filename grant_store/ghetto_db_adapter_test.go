@@ -0,0 +1,74 @@
+package grant_store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+)
+
+func TestGhettoAdapter_SingleUse(t *testing.T) {
+	ledger := NewGhettoAdapter(ghetto_db.New())
+
+	if err := ledger.Consume("grant-1", "nonce-1", 0); err != nil {
+		t.Fatalf("first Consume failed: %v", err)
+	}
+	if err := ledger.Consume("grant-1", "nonce-1", 0); !errors.Is(err, ErrGrantAlreadyConsumed) {
+		t.Errorf("expected ErrGrantAlreadyConsumed on replay, got %v", err)
+	}
+	if err := ledger.Consume("grant-2", "nonce-1", 0); !errors.Is(err, ErrGrantAlreadyConsumed) {
+		t.Errorf("expected ErrGrantAlreadyConsumed on nonce reuse, got %v", err)
+	}
+
+	consumed, err := ledger.IsConsumed("grant-1", "unused")
+	if err != nil || !consumed {
+		t.Errorf("expected grant-1 to be consumed, got %v (%v)", consumed, err)
+	}
+}
+
+func TestGhettoAdapter_Revoke(t *testing.T) {
+	ledger := NewGhettoAdapter(ghetto_db.New())
+
+	if err := ledger.Revoke("grant-1"); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	revoked, err := ledger.IsRevoked("grant-1")
+	if err != nil || !revoked {
+		t.Errorf("expected grant-1 to be revoked, got %v (%v)", revoked, err)
+	}
+	if err := ledger.Consume("grant-1", "nonce-1", 0); !errors.Is(err, ErrGrantRevoked) {
+		t.Errorf("expected ErrGrantRevoked, got %v", err)
+	}
+}
+
+func TestGhettoAdapter_Release(t *testing.T) {
+	ledger := NewGhettoAdapter(ghetto_db.New())
+
+	if err := ledger.Consume("grant-1", "nonce-1", 0); err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+	if err := ledger.Release("grant-1", "other-nonce"); err == nil {
+		t.Errorf("expected Release with the wrong nonce to fail")
+	}
+	if err := ledger.Release("grant-1", "nonce-1"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := ledger.Consume("grant-1", "nonce-1", 0); err != nil {
+		t.Errorf("expected a released grant to be consumable again, got %v", err)
+	}
+}
+
+func TestGhettoAdapter_NonceClashLeavesNoHalfWrite(t *testing.T) {
+	ledger := NewGhettoAdapter(ghetto_db.New())
+
+	if err := ledger.Consume("grant-1", "nonce-1", 0); err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+	if err := ledger.Consume("grant-2", "nonce-1", 0); !errors.Is(err, ErrGrantAlreadyConsumed) {
+		t.Fatalf("expected ErrGrantAlreadyConsumed on nonce reuse, got %v", err)
+	}
+	consumed, err := ledger.IsConsumed("grant-2", "unused")
+	if err != nil || consumed {
+		t.Errorf("expected grant-2 to stay unconsumed, got %v (%v)", consumed, err)
+	}
+}
@@ -0,0 +1,31 @@
+package grant_store
+
+import "errors"
+
+var (
+	ErrGrantAlreadyConsumed = errors.New("auth grant already consumed")
+	ErrGrantRevoked         = errors.New("auth grant revoked")
+)
+
+// AuthGrantLedger remembers which AuthGrants have been spent or revoked.
+// AuthGrants are otherwise stateless, so without it a grant is replayable until expiry.
+type AuthGrantLedger interface {
+	// Consume atomically marks GrantID and Nonce as spent.
+	// Returns ErrGrantAlreadyConsumed on reuse, ErrGrantRevoked if revoked.
+	Consume(grantID, nonce string, expiresAtUnix int64) error
+	IsConsumed(grantID, nonce string) (bool, error)
+	// Release undoes a Consume whose follow-up write failed, so the grant can be retried.
+	Release(grantID, nonce string) error
+
+	// Revocation by GrantID, independent of consumption
+	Revoke(grantID string) error
+	IsRevoked(grantID string) (bool, error)
+}
+
+// ConsumedGrantRecord is the value persisted per consumed GrantID.
+type ConsumedGrantRecord struct {
+	GrantID                 string `json:"grant_id"`
+	Nonce                   string `json:"nonce"`
+	ConsumedAtUnixTimestamp int64  `json:"consumed_at_unix_timestamp"`
+	ExpiresAtUnixTimestamp  int64  `json:"expires_at_unix_timestamp"` // Row may be purged after this
+}
@@ -0,0 +1,108 @@
+package grant_store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+/*
+CREATE TABLE auth_grant_consumed (
+    grant_id TEXT PRIMARY KEY,
+    nonce TEXT NOT NULL UNIQUE,
+    consumed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at BIGINT NOT NULL
+);
+
+CREATE TABLE auth_grant_revoked (
+    grant_id TEXT PRIMARY KEY,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+*/
+
+type PgAdapter struct {
+	db            *sql.DB
+	consumedTable string
+	revokedTable  string
+}
+
+func NewPgAdapter(db *sql.DB) *PgAdapter {
+	return &PgAdapter{
+		db:            db,
+		consumedTable: "auth_grant_consumed",
+		revokedTable:  "auth_grant_revoked",
+	}
+}
+
+// ─── Consumption ──────────────────────────────────────────────────────
+
+// Consume is a single statement: the revocation check and the insert see the same
+// snapshot, and the primary key / unique constraint make the insert atomic.
+func (a *PgAdapter) Consume(grantID, nonce string, expiresAtUnix int64) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (grant_id, nonce, expires_at)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM %s WHERE grant_id = $1)
+		ON CONFLICT DO NOTHING
+	`, a.consumedTable, a.revokedTable)
+	res, err := a.db.ExecContext(context.Background(), query, grantID, nonce, expiresAtUnix)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 1 {
+		return nil
+	}
+
+	revoked, err := a.IsRevoked(grantID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrGrantRevoked
+	}
+	return ErrGrantAlreadyConsumed
+}
+
+func (a *PgAdapter) IsConsumed(grantID, nonce string) (bool, error) {
+	query := fmt.Sprintf(`SELECT 1 FROM %s WHERE grant_id = $1 OR nonce = $2 LIMIT 1`, a.consumedTable)
+	var dummy int
+	err := a.db.QueryRowContext(context.Background(), query, grantID, nonce).Scan(&dummy)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (a *PgAdapter) Release(grantID, nonce string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE grant_id = $1 AND nonce = $2`, a.consumedTable)
+	_, err := a.db.ExecContext(context.Background(), query, grantID, nonce)
+	return err
+}
+
+// ─── Revocation ──────────────────────────────────────────────────────
+
+func (a *PgAdapter) Revoke(grantID string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (grant_id)
+		VALUES ($1)
+		ON CONFLICT (grant_id) DO NOTHING
+	`, a.revokedTable)
+	_, err := a.db.ExecContext(context.Background(), query, grantID)
+	return err
+}
+
+func (a *PgAdapter) IsRevoked(grantID string) (bool, error) {
+	query := fmt.Sprintf(`SELECT 1 FROM %s WHERE grant_id = $1`, a.revokedTable)
+	var dummy int
+	err := a.db.QueryRowContext(context.Background(), query, grantID).Scan(&dummy)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
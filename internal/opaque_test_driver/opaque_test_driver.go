@@ -0,0 +1,73 @@
+// Package opaque_test_driver plays the OPAQUE client in tests: it registers users and
+// finishes logins against the server the way the mobile client does.
+package opaque_test_driver
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/bytemare/opaque"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+// Registrar is the part of opaque_api.DefaultOpaqueService the driver registers against.
+// It is an interface so that opaque_api's own tests can use the driver without an import
+// cycle.
+type Registrar interface {
+	RegistrationStep1(user uagc.CoreUser, registrationRequestB64 string) (string, error)
+	RegistrationStep2(user uagc.CoreUser, registrationRecordB64 string, userGroups []uagc.UserGroupBinding) error
+}
+
+func B64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func Unb64(t testing.TB, s string) []byte {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func NewClient(t testing.TB) *opaque.Client {
+	client, err := opaque.DefaultConfiguration().Client()
+	require.NoError(t, err)
+	return client
+}
+
+// RegistrationRecord runs registration step one for user and returns the client's
+// finished record, base64url encoded, ready for step two.
+func RegistrationRecord(t testing.TB, svc Registrar, user uagc.CoreUser, password string) string {
+	client := NewClient(t)
+	regResp, err := svc.RegistrationStep1(user, B64(client.RegistrationInit([]byte(password)).Serialize()))
+	require.NoError(t, err)
+	resp, err := client.Deserialize.RegistrationResponse(Unb64(t, regResp))
+	require.NoError(t, err)
+	record, _ := client.RegistrationFinalize(resp, opaque.ClientRegistrationFinalizeOptions{
+		ClientIdentity: []byte(user.EncodeKey()),
+		ServerIdentity: uagc.OpaqueServerId(),
+	})
+	return B64(record.Serialize())
+}
+
+// Register registers user with password, bound to userGroupIDs.
+func Register(t testing.TB, svc Registrar, user uagc.CoreUser, password string, userGroupIDs ...string) {
+	var groups []uagc.UserGroupBinding
+	for _, id := range userGroupIDs {
+		groups = append(groups, uagc.UserGroupBinding{CoreUser: user, UserGroupID: id})
+	}
+	require.NoError(t, svc.RegistrationStep2(user, RegistrationRecord(t, svc, user, password), groups))
+}
+
+// LoginFinish answers the server's KE2 (base64url) with the client's serialized KE3. It
+// fails as a real client would when the password is wrong or the record is fake.
+func LoginFinish(t testing.TB, client *opaque.Client, user uagc.CoreUser, ke2B64 string) ([]byte, error) {
+	ke2, err := client.Deserialize.KE2(Unb64(t, ke2B64))
+	require.NoError(t, err)
+	ke3, _, err := client.LoginFinish(ke2, opaque.ClientLoginFinishOptions{
+		ClientIdentity: []byte(user.EncodeKey()),
+		ServerIdentity: uagc.OpaqueServerId(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ke3.Serialize(), nil
+}
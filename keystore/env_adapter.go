@@ -0,0 +1,94 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	EnvKeystoreFile       = "MNG_KEYSTORE_FILE"
+	EnvKeystorePassphrase = "MNG_KEYSTORE_PASSPHRASE"
+	EnvKeyPrefix          = "MNG_KEY_"
+)
+
+// EnvVarName returns the variable holding key name, e.g.
+// "ed448.auth_ticket.private" -> "MNG_KEY_ED448_AUTH_TICKET_PRIVATE".
+// The value is "<key id>:<standard base64 material>"; during a rotation it may list
+// several comma-separated keys, each id optionally suffixed with "/<state>".
+func EnvVarName(name string) string {
+	return EnvKeyPrefix + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+// LoadEnv builds a keystore from MNG_KEY_* variables. Names without a variable are skipped.
+func LoadEnv(names []string) (*MemoryAdapter, error) {
+	a := NewMemoryAdapter()
+	if err := overlayEnv(a, names); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadFromEnvironment is what deployments call at startup: it opens the sealed file
+// named by MNG_KEYSTORE_FILE (if set) and lets individual MNG_KEY_* variables override it.
+// The result is validated against RequiredKeys.
+func LoadFromEnvironment() (*MemoryAdapter, error) {
+	a := NewMemoryAdapter()
+
+	if path := os.Getenv(EnvKeystoreFile); path != "" {
+		passphrase := os.Getenv(EnvKeystorePassphrase)
+		if passphrase == "" {
+			return nil, fmt.Errorf("%s is set but %s is empty", EnvKeystoreFile, EnvKeystorePassphrase)
+		}
+		sealed, err := LoadSealedFile(path, []byte(passphrase))
+		if err != nil {
+			return nil, err
+		}
+		a = sealed
+	}
+
+	if err := overlayEnv(a, RequiredKeys); err != nil {
+		return nil, err
+	}
+	if err := Validate(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func overlayEnv(a *MemoryAdapter, names []string) error {
+	for _, name := range names {
+		value, ok := os.LookupEnv(EnvVarName(name))
+		if !ok {
+			continue
+		}
+		for _, entry := range strings.Split(value, ",") {
+			k, err := parseEnvKey(name, entry)
+			if err != nil {
+				return fmt.Errorf("%s: %w", EnvVarName(name), err)
+			}
+			a.Put(k)
+		}
+	}
+	return nil
+}
+
+func parseEnvKey(name, value string) (Key, error) {
+	id, encoded, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok || id == "" {
+		return Key{}, errors.New("expected <key id>[/<state>]:<base64 material>")
+	}
+	id, state, _ := strings.Cut(id, "/")
+	switch state {
+	case "", KeyStateActive, KeyStateVerifyOnly, KeyStateRetired:
+	default:
+		return Key{}, fmt.Errorf("unknown key state %q", state)
+	}
+	material, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid base64: %w", err)
+	}
+	return Key{Name: name, ID: id, State: state, Material: material}, nil
+}
@@ -0,0 +1,138 @@
+package keystore
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrKeyNotFound      = errors.New("key not found")
+	ErrKeyRetired       = errors.New("key retired")
+	ErrKeyOutsideWindow = errors.New("artifact issued outside the signing key's validity window")
+)
+
+// Key states for rotation. Each name has exactly one active key, which signs.
+// Verify-only keys still validate what they signed before the rotation;
+// retired keys validate nothing.
+const (
+	KeyStateActive     = "active"
+	KeyStateVerifyOnly = "verify_only"
+	KeyStateRetired    = "retired"
+)
+
+// Key names. Every name maps to raw key material in the format its API expects:
+// ed448_api private/public key bytes, crypto/ed25519 key bytes, rsa_api PEM, or raw OPAQUE bytes.
+const (
+	Ed448PersephonePrivate = "ed448.persephone.private"
+	Ed448PersephonePublic  = "ed448.persephone.public"
+	Ed448OpaquePrivate     = "ed448.opaque.private"
+	Ed448OpaquePublic      = "ed448.opaque.public"
+	Ed448HashcashPrivate   = "ed448.hashcash.private"
+	Ed448HashcashPublic    = "ed448.hashcash.public"
+	Ed448AuthTicketPrivate = "ed448.auth_ticket.private"
+	Ed448AuthTicketPublic  = "ed448.auth_ticket.public"
+	Ed448AuthGrantPrivate  = "ed448.auth_grant.private"
+	Ed448AuthGrantPublic   = "ed448.auth_grant.public"
+	Ed448HydratePrivate    = "ed448.hydrate.private"
+	Ed448HydratePublic     = "ed448.hydrate.public"
+	Ed448AuditPrivate      = "ed448.audit.private"
+	Ed448AuditPublic       = "ed448.audit.public"
+	Ed448DelegationPrivate = "ed448.delegation.private"
+	Ed448DelegationPublic  = "ed448.delegation.public"
+
+	Ed25519BiscuitPrivate = "ed25519.biscuit.private"
+	Ed25519BiscuitPublic  = "ed25519.biscuit.public"
+
+	RsaOpaqueEnvelopePrivate = "rsa.opaque_envelope.private"
+	RsaOpaqueEnvelopePublic  = "rsa.opaque_envelope.public"
+	RsaHydratePrivate        = "rsa.hydrate.private"
+	RsaHydratePublic         = "rsa.hydrate.public"
+
+	OpaqueServerID       = "opaque.server_id"
+	OpaqueServerPrivate  = "opaque.server.private"
+	OpaqueServerPublic   = "opaque.server.public"
+	OpaqueServerOprfSeed = "opaque.server.oprf_seed"
+)
+
+// RequiredKeys lists every name the auth server needs at startup.
+var RequiredKeys = []string{
+	Ed448PersephonePrivate, Ed448PersephonePublic,
+	Ed448OpaquePrivate, Ed448OpaquePublic,
+	Ed448HashcashPrivate, Ed448HashcashPublic,
+	Ed448AuthTicketPrivate, Ed448AuthTicketPublic,
+	Ed448AuthGrantPrivate, Ed448AuthGrantPublic,
+	Ed448HydratePrivate, Ed448HydratePublic,
+	Ed448AuditPrivate, Ed448AuditPublic,
+	Ed448DelegationPrivate, Ed448DelegationPublic,
+	Ed25519BiscuitPrivate, Ed25519BiscuitPublic,
+	RsaOpaqueEnvelopePrivate, RsaOpaqueEnvelopePublic,
+	RsaHydratePrivate, RsaHydratePublic,
+	OpaqueServerID, OpaqueServerPrivate, OpaqueServerPublic, OpaqueServerOprfSeed,
+}
+
+// Key is one piece of key material. ID identifies the concrete key (e.g. "FirstBlood")
+// so signed artifacts can name the key that produced them; a private key and its
+// public key share the same ID.
+//
+// NotBefore/NotAfter (unix seconds, 0 = open) bound when the key signs: an artifact
+// issued outside the window was not produced by this key. Rotate maintains them; they
+// are published to downstream verifiers alongside the public keys.
+type Key struct {
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	State     string `json:"state,omitempty"` // KeyState*, empty means active
+	NotBefore int64  `json:"not_before,omitempty"`
+	NotAfter  int64  `json:"not_after,omitempty"`
+	Material  []byte `json:"material"`
+}
+
+func (k Key) IsActive() bool {
+	return k.State == "" || k.State == KeyStateActive
+}
+
+// CoversIssuance reports whether an artifact issued at unix time issuedAt falls in the key's window.
+func (k Key) CoversIssuance(issuedAt int64) bool {
+	if k.NotBefore != 0 && issuedAt < k.NotBefore {
+		return false
+	}
+	if k.NotAfter != 0 && issuedAt > k.NotAfter {
+		return false
+	}
+	return true
+}
+
+// KeyStore hands out key material by name.
+type KeyStore interface {
+	// Get returns the active key for name, i.e. the one to sign with.
+	Get(name string) (Key, error)
+	// GetByID returns the key a signed artifact names. Retired keys fail with ErrKeyRetired.
+	GetByID(name, id string) (Key, error)
+	// List returns every key stored under name, in any state.
+	List(name string) []Key
+}
+
+// Validate checks that ks holds exactly one active key for every name in RequiredKeys.
+func Validate(ks KeyStore) error {
+	var missing, ambiguous []string
+	for _, name := range RequiredKeys {
+		active := 0
+		for _, k := range ks.List(name) {
+			if k.IsActive() && len(k.Material) > 0 {
+				active++
+			}
+		}
+		switch {
+		case active == 0:
+			missing = append(missing, name)
+		case active > 1:
+			ambiguous = append(ambiguous, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("keystore is missing active %v", missing)
+	}
+	if len(ambiguous) > 0 {
+		return fmt.Errorf("keystore has more than one active key for %v", ambiguous)
+	}
+	return nil
+}
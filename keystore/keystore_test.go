@@ -0,0 +1,88 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealedFileRoundTrip(t *testing.T) {
+	keys := []Key{
+		{Name: Ed448AuthTicketPrivate, ID: "k1", Material: []byte("ticket-secret")},
+		{Name: OpaqueServerID, ID: "k1", Material: []byte("server")},
+	}
+	data, err := SealKeys(keys, []byte("correct horse"))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.sealed")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	ks, err := LoadSealedFile(path, []byte("correct horse"))
+	require.NoError(t, err)
+	k, err := ks.Get(Ed448AuthTicketPrivate)
+	require.NoError(t, err)
+	require.Equal(t, "k1", k.ID)
+	require.Equal(t, []byte("ticket-secret"), k.Material)
+
+	_, err = LoadSealedFile(path, []byte("wrong"))
+	require.True(t, errors.Is(err, ErrSealedFileInvalid))
+}
+
+func TestEnvOverridesAndValidate(t *testing.T) {
+	t.Setenv(EnvVarName(Ed448HydratePublic), "Eleusis:"+base64.StdEncoding.EncodeToString([]byte("pub")))
+
+	ks, err := LoadEnv([]string{Ed448HydratePublic, Ed448HydratePrivate})
+	require.NoError(t, err)
+
+	k, err := ks.Get(Ed448HydratePublic)
+	require.NoError(t, err)
+	require.Equal(t, "Eleusis", k.ID)
+
+	_, err = ks.Get(Ed448HydratePrivate)
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+	require.Error(t, Validate(ks))
+
+	t.Setenv(EnvVarName(Ed448HydratePrivate), "no-separator")
+	_, err = LoadEnv([]string{Ed448HydratePrivate})
+	require.Error(t, err)
+}
+
+func TestRotation(t *testing.T) {
+	ks := NewMemoryAdapter(Key{Name: Ed448AuthTicketPublic, ID: "old", Material: []byte("old")})
+
+	ks.Rotate(Key{Name: Ed448AuthTicketPublic, ID: "new", Material: []byte("new")})
+
+	active, err := ks.Get(Ed448AuthTicketPublic)
+	require.NoError(t, err)
+	require.Equal(t, "new", active.ID)
+
+	old, err := ks.GetByID(Ed448AuthTicketPublic, "old")
+	require.NoError(t, err)
+	require.Equal(t, KeyStateVerifyOnly, old.State)
+	require.NotZero(t, old.NotAfter)
+	require.Equal(t, old.NotAfter, active.NotBefore)
+	require.True(t, old.CoversIssuance(old.NotAfter))
+	require.False(t, old.CoversIssuance(old.NotAfter+1))
+
+	require.NoError(t, ks.SetState(Ed448AuthTicketPublic, "old", KeyStateRetired))
+	_, err = ks.GetByID(Ed448AuthTicketPublic, "old")
+	require.True(t, errors.Is(err, ErrKeyRetired))
+
+	_, err = ks.GetByID(Ed448AuthTicketPublic, "unknown")
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestValidateRejectsTwoActiveKeys(t *testing.T) {
+	ks := NewMemoryAdapter()
+	for _, name := range RequiredKeys {
+		ks.Put(Key{Name: name, ID: "a", Material: []byte("x")})
+	}
+	require.NoError(t, Validate(ks))
+
+	ks.Put(Key{Name: Ed448AuthGrantPrivate, ID: "b", Material: []byte("y")})
+	require.Error(t, Validate(ks))
+}
@@ -0,0 +1,116 @@
+package keystore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryAdapter holds keys in process memory. The file and env loaders both
+// produce one; tests and the dev build fill it directly.
+type MemoryAdapter struct {
+	mu   sync.RWMutex
+	keys map[string][]Key
+}
+
+func NewMemoryAdapter(keys ...Key) *MemoryAdapter {
+	a := &MemoryAdapter{keys: make(map[string][]Key)}
+	for _, k := range keys {
+		a.Put(k)
+	}
+	return a
+}
+
+// Put adds k, replacing any key with the same name and ID.
+func (a *MemoryAdapter) Put(k Key) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, existing := range a.keys[k.Name] {
+		if existing.ID == k.ID {
+			a.keys[k.Name][i] = k
+			return
+		}
+	}
+	a.keys[k.Name] = append(a.keys[k.Name], k)
+}
+
+// Rotate makes k the active key for k.Name and demotes the previously active
+// key(s) to verify-only, so artifacts they signed keep validating. The demoted keys'
+// signing window closes now and k's opens now, unless the caller set them already.
+func (a *MemoryAdapter) Rotate(k Key) {
+	now := time.Now().Unix()
+
+	a.mu.Lock()
+	for i, existing := range a.keys[k.Name] {
+		if existing.IsActive() && existing.ID != k.ID {
+			a.keys[k.Name][i].State = KeyStateVerifyOnly
+			if existing.NotAfter == 0 {
+				a.keys[k.Name][i].NotAfter = now
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	k.State = KeyStateActive
+	if k.NotBefore == 0 {
+		k.NotBefore = now
+	}
+	a.Put(k)
+}
+
+// SetState changes the state of one key, e.g. to retire it once everything
+// it signed has expired.
+func (a *MemoryAdapter) SetState(name, id, state string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, existing := range a.keys[name] {
+		if existing.ID == id {
+			a.keys[name][i].State = state
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s/%s", ErrKeyNotFound, name, id)
+}
+
+func (a *MemoryAdapter) Get(name string) (Key, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, k := range a.keys[name] {
+		if k.IsActive() {
+			return k, nil
+		}
+	}
+	return Key{}, fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+}
+
+func (a *MemoryAdapter) GetByID(name, id string) (Key, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, k := range a.keys[name] {
+		if k.ID != id {
+			continue
+		}
+		if k.State == KeyStateRetired {
+			return Key{}, fmt.Errorf("%w: %s/%s", ErrKeyRetired, name, id)
+		}
+		return k, nil
+	}
+	return Key{}, fmt.Errorf("%w: %s/%s", ErrKeyNotFound, name, id)
+}
+
+func (a *MemoryAdapter) List(name string) []Key {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]Key(nil), a.keys[name]...)
+}
+
+// Keys returns a copy of all stored keys, e.g. for sealing them into a file.
+func (a *MemoryAdapter) Keys() []Key {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var out []Key
+	for _, keys := range a.keys {
+		out = append(out, keys...)
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/encryption/chacha_poly1305/chacha_poly1305_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/hashing/argon2/argon2_api"
+)
+
+const (
+	SealedFileVersion = "v1"
+	SealedFileKDF     = "argon2id"
+	SealedFileCipher  = "XChaCha20-Poly1305"
+	sealedSaltSize    = 16
+)
+
+var ErrSealedFileInvalid = errors.New("invalid sealed keystore file")
+
+// SealedKDFParams are stored in the clear so the sealing cost can be raised later
+// without breaking files sealed earlier.
+type SealedKDFParams struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt"`
+	Time      uint32 `json:"time"`
+	Memory    uint32 `json:"memory"` // KiB
+	Threads   uint8  `json:"threads"`
+}
+
+// SealedKeyFile is the on-disk format: a JSON []Key encrypted under a passphrase-derived key.
+// The header fields are bound to the ciphertext as AAD.
+type SealedKeyFile struct {
+	Version    string          `json:"version"`
+	Cipher     string          `json:"cipher"`
+	KDF        SealedKDFParams `json:"kdf"`
+	Nonce      []byte          `json:"nonce"`
+	Ciphertext []byte          `json:"ciphertext"`
+}
+
+// SealKeys encrypts keys under passphrase and returns the file contents.
+func SealKeys(keys []Key, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, sealedSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha_poly1305_api.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	file := SealedKeyFile{
+		Version: SealedFileVersion,
+		Cipher:  SealedFileCipher,
+		KDF: SealedKDFParams{
+			Algorithm: SealedFileKDF,
+			Salt:      salt,
+			Time:      argon2_api.DefaultArgon2idTime,
+			Memory:    argon2_api.DefaultArgon2idMemory,
+			Threads:   argon2_api.DefaultThreads,
+		},
+		Nonce: nonce,
+	}
+
+	sealingKey, err := deriveSealingKey(passphrase, file.KDF)
+	if err != nil {
+		return nil, err
+	}
+	aad, err := file.aad()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	file.Ciphertext, err = chacha_poly1305_api.Encrypt(sealingKey, nonce, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// OpenSealedKeys decrypts file contents produced by SealKeys.
+func OpenSealedKeys(data []byte, passphrase []byte) (*MemoryAdapter, error) {
+	var file SealedKeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSealedFileInvalid, err)
+	}
+	if file.Version != SealedFileVersion || file.Cipher != SealedFileCipher || file.KDF.Algorithm != SealedFileKDF {
+		return nil, fmt.Errorf("%w: unsupported version or algorithm", ErrSealedFileInvalid)
+	}
+
+	sealingKey, err := deriveSealingKey(passphrase, file.KDF)
+	if err != nil {
+		return nil, err
+	}
+	aad, err := file.aad()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := chacha_poly1305_api.Decrypt(sealingKey, file.Nonce, file.Ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong passphrase or corrupted file", ErrSealedFileInvalid)
+	}
+
+	var keys []Key
+	if err := json.Unmarshal(plaintext, &keys); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSealedFileInvalid, err)
+	}
+	return NewMemoryAdapter(keys...), nil
+}
+
+// LoadSealedFile reads and decrypts a sealed keystore file from disk.
+func LoadSealedFile(path string, passphrase []byte) (*MemoryAdapter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return OpenSealedKeys(data, passphrase)
+}
+
+func deriveSealingKey(passphrase []byte, p SealedKDFParams) ([]byte, error) {
+	return argon2_api.DeriveKeyArgon2id(passphrase, p.Salt, p.Time, p.Memory, p.Threads, chacha_poly1305_api.KeySize)
+}
+
+// aad covers everything but the ciphertext, so KDF parameters can't be swapped
+func (f SealedKeyFile) aad() ([]byte, error) {
+	header := f
+	header.Ciphertext = nil
+	return json.Marshal(header)
+}
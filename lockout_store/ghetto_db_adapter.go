@@ -0,0 +1,82 @@
+package lockout_store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+)
+
+const ghettoFailureTable = "opaque_login_failure"
+
+type GhettoAdapter struct {
+	mu sync.Mutex // GhettoDB has no read-modify-write, so RecordFailure serialises here
+	db *ghetto_db.GhettoDB
+}
+
+func NewGhettoAdapter(db *ghetto_db.GhettoDB) *GhettoAdapter {
+	db.CreateTable(ghettoFailureTable)
+	return &GhettoAdapter{db: db}
+}
+
+func (a *GhettoAdapter) RecordFailure(key string, nowUnix, windowSeconds int64) (*FailureRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rec, err := a.get(key)
+	if err != nil {
+		return nil, err
+	}
+	if nowUnix-rec.LastFailureAtUnixTimestamp > windowSeconds {
+		rec.Failures = 0
+	}
+	rec.Failures++
+	rec.LastFailureAtUnixTimestamp = nowUnix
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal failure record: %w", err)
+	}
+	if err := a.db.Upsert(ghettoFailureTable, key, data); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (a *GhettoAdapter) Get(key string) (*FailureRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.get(key)
+}
+
+func (a *GhettoAdapter) Reset(key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	exists, err := a.db.Exists(ghettoFailureTable, key)
+	if err != nil || !exists {
+		return err
+	}
+	return a.db.Delete(ghettoFailureTable, key)
+}
+
+func (a *GhettoAdapter) get(key string) (*FailureRecord, error) {
+	exists, err := a.db.Exists(ghettoFailureTable, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return &FailureRecord{Key: key}, nil
+	}
+
+	data, err := a.db.Get(ghettoFailureTable, key)
+	if err != nil {
+		return nil, err
+	}
+	var rec FailureRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("unmarshal failure record: %w", err)
+	}
+	return &rec, nil
+}
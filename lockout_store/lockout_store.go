@@ -0,0 +1,34 @@
+package lockout_store
+
+import (
+	"net/url"
+
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// LockoutStore counts failed OPAQUE logins per key (see UserKey). Backoff
+// and lockout are derived from the count and the time of the last failure, so the store
+// holds no policy (see auth_plugins/persephone/lockout).
+type LockoutStore interface {
+	// RecordFailure atomically adds a failure for key and returns the updated record.
+	// If the previous failure is more than windowSeconds old the count restarts at 1.
+	RecordFailure(key string, nowUnix, windowSeconds int64) (*FailureRecord, error)
+
+	// Get returns the record for key, or a zero record if it has none.
+	Get(key string) (*FailureRecord, error)
+
+	// Reset forgets every failure of key; resetting an unknown key is not an error.
+	Reset(key string) error
+}
+
+// FailureRecord is the value persisted per key.
+type FailureRecord struct {
+	Key                        string `json:"key"`
+	Failures                   int    `json:"failures"`
+	LastFailureAtUnixTimestamp int64  `json:"last_failure_at_unix_timestamp"`
+}
+
+// UserKey is the key of one CoreUser, whichever user group they log in as.
+func UserKey(u uagc.CoreUser) string {
+	return "user|" + url.PathEscape(u.TenantID) + "|" + url.PathEscape(u.UserID)
+}
@@ -0,0 +1,65 @@
+package lockout_store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+/*
+CREATE TABLE opaque_login_failure (
+    failure_key TEXT PRIMARY KEY,
+    failures INT NOT NULL,
+    last_failure_at BIGINT NOT NULL
+);
+*/
+
+type PgAdapter struct {
+	db    *sql.DB
+	table string
+}
+
+func NewPgAdapter(db *sql.DB) *PgAdapter {
+	return &PgAdapter{db: db, table: "opaque_login_failure"}
+}
+
+func (a *PgAdapter) RecordFailure(key string, nowUnix, windowSeconds int64) (*FailureRecord, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (failure_key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (failure_key) DO UPDATE SET
+			failures = CASE WHEN $2 - %[1]s.last_failure_at > $3 THEN 1 ELSE %[1]s.failures + 1 END,
+			last_failure_at = $2
+		RETURNING failures, last_failure_at
+	`, a.table)
+	rec := FailureRecord{Key: key}
+	err := a.db.QueryRowContext(context.Background(), query, key, nowUnix, windowSeconds).Scan(
+		&rec.Failures, &rec.LastFailureAtUnixTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (a *PgAdapter) Get(key string) (*FailureRecord, error) {
+	query := fmt.Sprintf(`
+		SELECT failures, last_failure_at FROM %s WHERE failure_key = $1
+	`, a.table)
+	rec := FailureRecord{Key: key}
+	err := a.db.QueryRowContext(context.Background(), query, key).Scan(&rec.Failures, &rec.LastFailureAtUnixTimestamp)
+	if err == sql.ErrNoRows {
+		return &rec, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (a *PgAdapter) Reset(key string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE failure_key = $1`, a.table)
+	_, err := a.db.ExecContext(context.Background(), query, key)
+	return err
+}
@@ -28,6 +28,13 @@ func (a *GhettoAdapter) SaveRaw(user user_auth_global_config.CoreUser, data []by
 
 // LoadRaw retrieves the full serialized OpaqueUserRecord.
 func (a *GhettoAdapter) LoadRaw(user user_auth_global_config.CoreUser) ([]byte, error) {
+	exists, err := a.db.Exists(a.tableName, user.EncodeKey())
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, user.EncodeKey())
+	}
 	return a.db.Get(a.tableName, user.EncodeKey())
 }
 
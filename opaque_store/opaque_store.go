@@ -1,9 +1,14 @@
 package opaque_store
 
 import (
+	"errors"
+
 	"github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
 )
 
+// ErrUserNotFound is wrapped by LoadRaw when no record is stored for the CoreUser.
+var ErrUserNotFound = errors.New("user not found")
+
 type OpaqueClientStore interface {
 	// Save and load full user records
 	SaveRaw(user user_auth_global_config.CoreUser, data []byte) error
@@ -47,7 +47,7 @@ func (a *PgAdapter) LoadRaw(user user_auth_global_config.CoreUser) ([]byte, erro
 	var data []byte
 	err := a.db.QueryRowContext(context.Background(), query, user.TenantID, user.UserID).Scan(&data)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found: %s|%s", user.TenantID, user.UserID)
+		return nil, fmt.Errorf("%w: %s|%s", ErrUserNotFound, user.TenantID, user.UserID)
 	}
 	return data, err
 }
@@ -0,0 +1,61 @@
+package policy
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvPolicyDir names a directory of <tenant_id>.datalog files, plus an optional
+// default.datalog replacing the built-in one.
+const EnvPolicyDir = "MNG_POLICY_DIR"
+
+const policyFileExt = ".datalog"
+
+//go:embed policies/default.datalog
+var embeddedDefault string
+
+// LoadDir builds an engine from every *.datalog file in dir; the file name is the tenant ID.
+// A tenant's file replaces the default policy for that tenant, it does not extend it.
+func LoadDir(dir string) (*Engine, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := map[string]string{DefaultTenant: embeddedDefault}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), policyFileExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sources[strings.TrimSuffix(entry.Name(), policyFileExt)] = string(data)
+	}
+	return NewEngine(sources)
+}
+
+// LoadFromEnvironment loads MNG_POLICY_DIR, or only the built-in default policy if unset.
+func LoadFromEnvironment() (*Engine, error) {
+	dir := os.Getenv(EnvPolicyDir)
+	if dir == "" {
+		return NewEngine(map[string]string{DefaultTenant: embeddedDefault})
+	}
+	e, err := LoadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", EnvPolicyDir, err)
+	}
+	return e, nil
+}
+
+func mustLoadEmbedded() *Engine {
+	e, err := NewEngine(map[string]string{DefaultTenant: embeddedDefault})
+	if err != nil {
+		panic(fmt.Sprintf("policy: built-in default policy: %v", err))
+	}
+	return e
+}
@@ -0,0 +1,292 @@
+// Package policy decides what a user group may do, per tenant, with the biscuit Datalog
+// authorizer. Policies are Datalog files (see policies/default.datalog) loaded at startup;
+// plugins ask Authorize(ticket, resource, operation).
+package policy
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/biscuit-auth/biscuit-go/v2"
+	"github.com/biscuit-auth/biscuit-go/v2/parser"
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+)
+
+// DefaultTenant names the policy used for tenants without their own file.
+const DefaultTenant = "default"
+
+var ErrDenied = errors.New("denied by policy")
+
+// Resources and operations the default policy grants. Tenant policies may add their own.
+const (
+	ResourceOwnProfile     = "own_profile"
+	ResourceStudentProfile = "student_profile"
+	ResourceBeltRank       = "belt_rank"
+	ResourceAttendance     = "attendance"
+	ResourceAuthGrant      = "auth_grant"
+	ResourceSession        = "session" // Other users' login sessions; one's own need no right
+	ResourceAccount        = "account" // Other users' accounts, e.g. lifting a login lockout
+
+	OperationRead   = "read"
+	OperationWrite  = "write"
+	OperationAssign = "assign"
+	OperationIssue  = "issue"
+	OperationRevoke = "revoke"
+	OperationUnlock = "unlock"
+)
+
+// Right is one right(resource, operation) a user derives under their tenant's policy.
+type Right struct {
+	Resource  string
+	Operation string
+}
+
+// DelegatedRights are rights one user lent another for a while (see
+// auth_plugins/persephone/delegation). They count only as long as Issuer still derives
+// each right from their own policy.
+type DelegatedRights struct {
+	Issuer uagc.UniqueUser
+	Rights []Right
+}
+
+// DelegationSource lists the delegations in force for a user at a given time.
+type DelegationSource interface {
+	ActiveDelegations(delegate uagc.UniqueUser, now time.Time) ([]DelegatedRights, error)
+}
+
+// finalPolicy runs after every tenant policy: whatever right() derives is allowed.
+var finalPolicy = parser.New().Must().Policy(`allow if resource($r), operation($op), right($r, $op)`, nil)
+
+var rightsQuery = parser.New().Must().Rule(`right($r, $op) <- right($r, $op)`, nil)
+
+// Engine holds the parsed policy of every tenant. It is immutable once built.
+type Engine struct {
+	tenants map[string]biscuit.ParsedAuthorizer
+	// The biscuit-go authorizer only runs against a token. Requests are evaluated against
+	// an empty one minted with a throwaway key; all facts come from the verified ticket.
+	empty *biscuit.Biscuit
+
+	delegations DelegationSource
+}
+
+// NewEngine parses Datalog sources keyed by tenant ID. DefaultTenant is required.
+func NewEngine(sources map[string]string) (*Engine, error) {
+	if _, ok := sources[DefaultTenant]; !ok {
+		return nil, fmt.Errorf("missing %s policy", DefaultTenant)
+	}
+
+	e := &Engine{tenants: make(map[string]biscuit.ParsedAuthorizer, len(sources))}
+	for tenant, code := range sources {
+		parsed, err := parsePolicy(code)
+		if err != nil {
+			return nil, fmt.Errorf("policy for tenant %s: %w", tenant, err)
+		}
+		e.tenants[tenant] = parsed
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	e.empty, err = biscuit.NewBuilder(priv).Build()
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// parsePolicy parses one tenant's file. biscuit-go v2.2.0 panics on `deny if` policies
+// (it reads the allow branch), so that panic is reported as an error; restrict with
+// `check if` instead, which must hold for every request.
+func parsePolicy(code string) (parsed biscuit.ParsedAuthorizer, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unsupported policy (deny if is not supported, use check if): %v", r)
+		}
+	}()
+	return parser.FromStringAuthorizer(code)
+}
+
+// Authorize verifies the ticket and evaluates (resource, operation) against the policy of
+// the ticket's tenant. It returns ErrDenied unless a policy allows the request.
+func (e *Engine) Authorize(ticket *at.AuthTicket, resource, operation string) error {
+	if err := auth_ticket.VerifyAuthTicket(ticket); err != nil {
+		return err
+	}
+	return e.AuthorizeUser(ticket.AuthenticatedUser, ticket.IsRehydrated, resource, operation)
+}
+
+// WithDelegations returns a copy of e that also honours rights delegated through src.
+func (e *Engine) WithDelegations(src DelegationSource) *Engine {
+	out := *e
+	out.delegations = src
+	return &out
+}
+
+// AuthorizeUser is Authorize for callers that already verified the ticket. When the
+// tenant policy alone denies the request, rights delegated to the user are added and the
+// policy is evaluated again, so its checks still apply to delegated rights.
+func (e *Engine) AuthorizeUser(user uagc.UniqueUser, rehydrated bool, resource, operation string) error {
+	err := e.evaluate(user, rehydrated, resource, operation, nil)
+	if !errors.Is(err, ErrDenied) || e.delegations == nil {
+		return err
+	}
+
+	delegated, derr := e.delegatedRight(user, Right{Resource: resource, Operation: operation})
+	if derr != nil {
+		return fmt.Errorf("%w: delegated rights unavailable: %v", ErrDenied, derr)
+	}
+	if !delegated {
+		return err
+	}
+	return e.evaluate(user, rehydrated, resource, operation, []Right{{Resource: resource, Operation: operation}})
+}
+
+// delegatedRight reports whether a delegation in force lends want to user, from an issuer
+// who still holds it under their own policy. Delegated rights are never re-delegated.
+func (e *Engine) delegatedRight(user uagc.UniqueUser, want Right) (bool, error) {
+	delegations, err := e.delegations.ActiveDelegations(user, time.Now())
+	if err != nil {
+		return false, err
+	}
+	for _, d := range delegations {
+		if d.Issuer.TenantID != user.TenantID || !containsRight(d.Rights, want) {
+			continue
+		}
+		held, err := e.RightsFor(d.Issuer, false)
+		if err != nil {
+			return false, err
+		}
+		if containsRight(held, want) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (e *Engine) evaluate(user uagc.UniqueUser, rehydrated bool, resource, operation string, extra []Right) error {
+	a, err := e.authorizer(user, rehydrated)
+	if err != nil {
+		return err
+	}
+	for _, r := range extra {
+		a.AddFact(fact("right", biscuit.String(r.Resource), biscuit.String(r.Operation)))
+	}
+	a.AddFact(fact("resource", biscuit.String(resource)))
+	a.AddFact(fact("operation", biscuit.String(operation)))
+	a.AddPolicy(finalPolicy)
+
+	if err := a.Authorize(); err != nil {
+		if errors.Is(err, biscuit.ErrPolicyDenied) || errors.Is(err, biscuit.ErrNoMatchingPolicy) {
+			return ErrDenied
+		}
+		return fmt.Errorf("%w: %v", ErrDenied, err)
+	}
+	return nil
+}
+
+// RightsFor lists every right the user derives from their tenant policy, e.g. to mint into
+// a Biscuit. Delegated rights are left out: a token could outlive an early revocation.
+func (e *Engine) RightsFor(user uagc.UniqueUser, rehydrated bool) ([]Right, error) {
+	a, err := e.authorizer(user, rehydrated)
+	if err != nil {
+		return nil, err
+	}
+	facts, err := a.Query(rightsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	rights := make([]Right, 0, len(facts))
+	for _, f := range facts {
+		res, ok1 := f.IDs[0].(biscuit.String)
+		op, ok2 := f.IDs[1].(biscuit.String)
+		if ok1 && ok2 {
+			rights = append(rights, Right{Resource: string(res), Operation: string(op)})
+		}
+	}
+	return rights, nil
+}
+
+// Tenants lists the tenants with their own policy, plus DefaultTenant.
+func (e *Engine) Tenants() []string {
+	out := make([]string, 0, len(e.tenants))
+	for t := range e.tenants {
+		out = append(out, t)
+	}
+	return out
+}
+
+func (e *Engine) authorizer(user uagc.UniqueUser, rehydrated bool) (biscuit.Authorizer, error) {
+	code, ok := e.tenants[user.TenantID]
+	if !ok {
+		code = e.tenants[DefaultTenant]
+	}
+
+	a, err := biscuit.NewVerifier(e.empty)
+	if err != nil {
+		return nil, err
+	}
+	a.AddFact(fact("tenant", biscuit.String(user.TenantID)))
+	a.AddFact(fact("user", biscuit.String(user.UserID)))
+	a.AddFact(fact("user_group", biscuit.String(user.UserGroupID)))
+	a.AddFact(fact("sub_id", biscuit.String(user.SubID)))
+	a.AddFact(fact("rehydrated", biscuit.Bool(rehydrated)))
+	a.AddAuthorizer(code)
+	return a, nil
+}
+
+func containsRight(rights []Right, want Right) bool {
+	for _, r := range rights {
+		if r == want {
+			return true
+		}
+	}
+	return false
+}
+
+func fact(name string, terms ...biscuit.Term) biscuit.Fact {
+	return biscuit.Fact{Predicate: biscuit.Predicate{Name: name, IDs: terms}}
+}
+
+// ─── Installed engine ───────────────────────────────────────────
+
+var (
+	mu     sync.RWMutex
+	active *Engine
+)
+
+// Install replaces the engine Authorize and Active use. cmd/api calls it at startup.
+func Install(e *Engine) {
+	mu.Lock()
+	defer mu.Unlock()
+	active = e
+}
+
+// Active returns the installed engine, falling back to the embedded default policy.
+func Active() *Engine {
+	mu.RLock()
+	e := active
+	mu.RUnlock()
+	if e != nil {
+		return e
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		active = mustLoadEmbedded()
+	}
+	return active
+}
+
+// Authorize evaluates the request against the installed engine.
+func Authorize(ticket *at.AuthTicket, resource, operation string) error {
+	return Active().Authorize(ticket, resource, operation)
+}
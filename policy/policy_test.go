@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket"
+	at "github.com/drlzh/mng-app-user-auth-prot/auth_plugins/persephone/auth_ticket/structs"
+	uagc "github.com/drlzh/mng-app-user-auth-prot/user_auth_global_config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	e := mustLoadEmbedded()
+
+	coach := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupCoach, UserID: "sensei"}
+	staff := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupStaff, UserID: "desk"}
+
+	require.NoError(t, e.AuthorizeUser(coach, false, "belt_rank", "assign"))
+	require.NoError(t, e.AuthorizeUser(staff, false, "student_profile", "read"))
+	require.ErrorIs(t, e.AuthorizeUser(staff, false, "belt_rank", "assign"), ErrDenied)
+	require.ErrorIs(t, e.AuthorizeUser(uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: "UNKNOWN"}, false, "own_profile", "read"), ErrDenied)
+
+	rights, err := e.RightsFor(staff, false)
+	require.NoError(t, err)
+	require.Contains(t, rights, Right{Resource: "attendance", Operation: "write"})
+	require.NotContains(t, rights, Right{Resource: "belt_rank", Operation: "assign"})
+}
+
+func TestTenantPolicyFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dojo-b.datalog"), []byte(`
+		group_right("USER_GROUP_STAFF", "belt_rank", "assign");
+		right($r, $op) <- user_group($g), group_right($g, $r, $op);
+		check if rehydrated(false);
+	`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o600))
+
+	e, err := LoadDir(dir)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{DefaultTenant, "dojo-b"}, e.Tenants())
+
+	staffB := uagc.UniqueUser{TenantID: "dojo-b", UserGroupID: uagc.UserGroupStaff, UserID: "desk"}
+	require.NoError(t, e.AuthorizeUser(staffB, false, "belt_rank", "assign"))
+	require.ErrorIs(t, e.AuthorizeUser(staffB, true, "belt_rank", "assign"), ErrDenied)
+	// dojo-b's file replaces the default, so rights it doesn't list are gone
+	require.ErrorIs(t, e.AuthorizeUser(staffB, false, "student_profile", "read"), ErrDenied)
+
+	// Another tenant's file never applies to dojo-a
+	staffA := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupStaff, UserID: "desk"}
+	require.ErrorIs(t, e.AuthorizeUser(staffA, false, "belt_rank", "assign"), ErrDenied)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.datalog"), []byte(`right(`), 0o600))
+	_, err = LoadDir(dir)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.datalog"), []byte(`deny if user("x");`), 0o600))
+	_, err = LoadDir(dir)
+	require.Error(t, err)
+}
+
+func TestAuthorizeVerifiesTicket(t *testing.T) {
+	user := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupCoach, UserID: "sensei"}
+	ticket, err := auth_ticket.CreateAuthTicket(user, at.AuthTicketPurposeLogin, "", false, nil)
+	require.NoError(t, err)
+	require.NoError(t, Authorize(ticket, "belt_rank", "assign"))
+
+	ticket.AuthenticatedUser.UserGroupID = uagc.UserGroupDeveloper
+	require.Error(t, Authorize(ticket, "belt_rank", "assign"))
+}
+
+type staticDelegations []DelegatedRights
+
+func (s staticDelegations) ActiveDelegations(uagc.UniqueUser, time.Time) ([]DelegatedRights, error) {
+	return s, nil
+}
+
+func TestDelegatedRightsNeedAHoldingIssuer(t *testing.T) {
+	coach := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupCoach, UserID: "sensei"}
+	staff := uagc.UniqueUser{TenantID: "dojo-a", UserGroupID: uagc.UserGroupStaff, UserID: "desk"}
+	assign := Right{Resource: ResourceBeltRank, Operation: OperationAssign}
+
+	e := mustLoadEmbedded().WithDelegations(staticDelegations{{Issuer: coach, Rights: []Right{assign}}})
+	require.NoError(t, e.AuthorizeUser(staff, false, ResourceBeltRank, OperationAssign))
+
+	// Rights lent by an issuer whose own policy lacks them count for nothing,
+	// e.g. after the issuer's group was changed
+	e = mustLoadEmbedded().WithDelegations(staticDelegations{{Issuer: staff, Rights: []Right{assign}}})
+	other := staff
+	other.UserID = "desk-2"
+	require.ErrorIs(t, e.AuthorizeUser(other, false, ResourceBeltRank, OperationAssign), ErrDenied)
+}
@@ -0,0 +1,63 @@
+package pow_store
+
+import (
+	"sync"
+	"time"
+)
+
+type seenEntry struct {
+	uses      int
+	expiresAt time.Time
+}
+
+// MemoryAdapter is a process-local SeenNonceStore. Use PgAdapter when more
+// than one auth server instance sits behind the load balancer.
+type MemoryAdapter struct {
+	mu        sync.Mutex
+	entries   map[string]*seenEntry
+	lastPurge time.Time
+}
+
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{
+		entries:   make(map[string]*seenEntry),
+		lastPurge: time.Now(),
+	}
+}
+
+func (a *MemoryAdapter) MarkSeen(key string, expiresAt time.Time, maxUses int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(a.lastPurge) > purgeInterval {
+		a.purgeLocked(now)
+	}
+
+	e, ok := a.entries[key]
+	if !ok {
+		e = &seenEntry{expiresAt: expiresAt}
+		a.entries[key] = e
+	}
+	if e.uses >= maxUses {
+		return ErrNonceExhausted
+	}
+	e.uses++
+	return nil
+}
+
+// Len reports the number of tracked nonces (expired ones may linger until the next purge).
+func (a *MemoryAdapter) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.entries)
+}
+
+func (a *MemoryAdapter) purgeLocked(now time.Time) {
+	for k, e := range a.entries {
+		if now.After(e.expiresAt) {
+			delete(a.entries, k)
+		}
+	}
+	a.lastPurge = now
+}
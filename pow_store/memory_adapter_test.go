@@ -0,0 +1,38 @@
+package pow_store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryAdapter_MaxUses(t *testing.T) {
+	store := NewMemoryAdapter()
+	exp := time.Now().Add(time.Minute)
+
+	if err := store.MarkSeen("n1", exp, 2); err != nil {
+		t.Fatalf("first use failed: %v", err)
+	}
+	if err := store.MarkSeen("n1", exp, 2); err != nil {
+		t.Fatalf("second use failed: %v", err)
+	}
+	if err := store.MarkSeen("n1", exp, 2); !errors.Is(err, ErrNonceExhausted) {
+		t.Errorf("expected ErrNonceExhausted, got %v", err)
+	}
+	if err := store.MarkSeen("n2", exp, 1); err != nil {
+		t.Errorf("independent nonce rejected: %v", err)
+	}
+}
+
+func TestMemoryAdapter_Purge(t *testing.T) {
+	store := NewMemoryAdapter()
+	_ = store.MarkSeen("old", time.Now().Add(-time.Second), 1)
+	_ = store.MarkSeen("fresh", time.Now().Add(time.Minute), 1)
+
+	store.lastPurge = time.Now().Add(-2 * purgeInterval)
+	_ = store.MarkSeen("trigger", time.Now().Add(time.Minute), 1)
+
+	if store.Len() != 2 {
+		t.Errorf("expected expired nonce to be purged, have %d entries", store.Len())
+	}
+}
@@ -0,0 +1,68 @@
+package pow_store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+/*
+CREATE TABLE pow_seen_nonce (
+    nonce_key TEXT PRIMARY KEY,
+    uses INT NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+*/
+
+type PgAdapter struct {
+	db        *sql.DB
+	tableName string
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+func NewPgAdapter(db *sql.DB) *PgAdapter {
+	return &PgAdapter{
+		db:        db,
+		tableName: "pow_seen_nonce",
+		lastPurge: time.Now(),
+	}
+}
+
+func (a *PgAdapter) MarkSeen(key string, expiresAt time.Time, maxUses int) error {
+	a.maybePurge()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (nonce_key, uses, expires_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (nonce_key) DO UPDATE SET uses = %s.uses + 1
+		RETURNING uses
+	`, a.tableName, a.tableName)
+	var uses int
+	if err := a.db.QueryRowContext(context.Background(), query, key, expiresAt.Unix()).Scan(&uses); err != nil {
+		return err
+	}
+	if uses > maxUses {
+		return ErrNonceExhausted
+	}
+	return nil
+}
+
+func (a *PgAdapter) maybePurge() {
+	a.mu.Lock()
+	now := time.Now()
+	if now.Sub(a.lastPurge) <= purgeInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastPurge = now
+	a.mu.Unlock()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < $1`, a.tableName)
+	_, _ = a.db.ExecContext(context.Background(), query, now.Unix())
+}
@@ -0,0 +1,20 @@
+package pow_store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNonceExhausted = errors.New("proof-of-work already used")
+
+// purgeInterval bounds how often adapters sweep expired nonces.
+const purgeInterval = time.Minute
+
+// SeenNonceStore counts how many times a solved PoW has been spent.
+// Entries only need to live until the PoW itself expires; after that the
+// PoW is rejected on its timestamp alone.
+type SeenNonceStore interface {
+	// MarkSeen records one more use of key and fails with ErrNonceExhausted
+	// once it has been used more than maxUses times.
+	MarkSeen(key string, expiresAt time.Time, maxUses int) error
+}
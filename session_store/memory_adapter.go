@@ -0,0 +1,101 @@
+package session_store
+
+import "sync"
+
+// MemoryAdapter is a process-local SessionStore. Sessions are forgotten on restart, which
+// signs everyone out; use PgAdapter in production.
+type MemoryAdapter struct {
+	mu       sync.Mutex
+	sessions map[string]StoredSession
+	refresh  map[string]StoredRefreshToken
+}
+
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{
+		sessions: make(map[string]StoredSession),
+		refresh:  make(map[string]StoredRefreshToken),
+	}
+}
+
+func (a *MemoryAdapter) Put(s StoredSession) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.sessions[s.SessionID]; ok {
+		return ErrSessionExists
+	}
+	s.UserGroupIDs = append([]string(nil), s.UserGroupIDs...)
+	a.sessions[s.SessionID] = s
+	return nil
+}
+
+func (a *MemoryAdapter) Get(sessionID string) (*StoredSession, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &s, nil
+}
+
+func (a *MemoryAdapter) ListForUser(tenantID, userID string, issuedSinceUnix int64) ([]StoredSession, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var out []StoredSession
+	for _, s := range a.sessions {
+		if s.TenantID == tenantID && s.UserID == userID && s.IssuedAtUnixTimestamp >= issuedSinceUnix {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (a *MemoryAdapter) Revoke(sessionID string, atUnix int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if s.RevokedAtUnixTimestamp == 0 {
+		s.RevokedAtUnixTimestamp = atUnix
+		a.sessions[sessionID] = s
+	}
+	return nil
+}
+
+func (a *MemoryAdapter) PutRefreshToken(rec StoredRefreshToken) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.refresh[rec.TokenHash]; ok {
+		return ErrRefreshTokenExists
+	}
+	a.refresh[rec.TokenHash] = rec
+	return nil
+}
+
+func (a *MemoryAdapter) GetRefreshToken(tokenHash string) (*StoredRefreshToken, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec, ok := a.refresh[tokenHash]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+	return &rec, nil
+}
+
+func (a *MemoryAdapter) ConsumeRefreshToken(tokenHash string, atUnix int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec, ok := a.refresh[tokenHash]
+	if !ok {
+		return ErrRefreshTokenNotFound
+	}
+	if rec.UsedAtUnixTimestamp != 0 {
+		return ErrRefreshTokenAlreadyUsed
+	}
+	rec.UsedAtUnixTimestamp = atUnix
+	a.refresh[tokenHash] = rec
+	return nil
+}
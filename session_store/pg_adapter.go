@@ -0,0 +1,186 @@
+package session_store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+/*
+CREATE TABLE auth_session (
+    session_id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    user_group_ids TEXT[] NOT NULL,
+    device_identifier TEXT NOT NULL DEFAULT '',
+    device_name TEXT NOT NULL DEFAULT '',
+    issued_at BIGINT NOT NULL,
+    revoked_at BIGINT NOT NULL DEFAULT 0
+);
+CREATE INDEX auth_session_user ON auth_session (tenant_id, user_id, issued_at);
+
+CREATE TABLE auth_session_refresh_token (
+    token_hash TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES auth_session (session_id) ON DELETE CASCADE,
+    generation INT NOT NULL,
+    issued_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    used_at BIGINT NOT NULL DEFAULT 0
+);
+*/
+
+type PgAdapter struct {
+	db           *sql.DB
+	table        string
+	refreshTable string
+}
+
+func NewPgAdapter(db *sql.DB) *PgAdapter {
+	return &PgAdapter{db: db, table: "auth_session", refreshTable: "auth_session_refresh_token"}
+}
+
+func (a *PgAdapter) Put(s StoredSession) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (session_id, tenant_id, user_id, user_group_ids, device_identifier, device_name, issued_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT DO NOTHING
+	`, a.table)
+	res, err := a.db.ExecContext(context.Background(), query,
+		s.SessionID, s.TenantID, s.UserID, pq.Array(s.UserGroupIDs), s.DeviceIdentifier, s.DeviceName,
+		s.IssuedAtUnixTimestamp, s.RevokedAtUnixTimestamp)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionExists
+	}
+	return nil
+}
+
+func (a *PgAdapter) Get(sessionID string) (*StoredSession, error) {
+	query := fmt.Sprintf(`
+		SELECT session_id, tenant_id, user_id, user_group_ids, device_identifier, device_name, issued_at, revoked_at
+		FROM %s WHERE session_id = $1
+	`, a.table)
+	s, err := scanSession(a.db.QueryRowContext(context.Background(), query, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (a *PgAdapter) ListForUser(tenantID, userID string, issuedSinceUnix int64) ([]StoredSession, error) {
+	query := fmt.Sprintf(`
+		SELECT session_id, tenant_id, user_id, user_group_ids, device_identifier, device_name, issued_at, revoked_at
+		FROM %s WHERE tenant_id = $1 AND user_id = $2 AND issued_at >= $3
+		ORDER BY issued_at
+	`, a.table)
+	rows, err := a.db.QueryContext(context.Background(), query, tenantID, userID, issuedSinceUnix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StoredSession
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+	return out, rows.Err()
+}
+
+func (a *PgAdapter) Revoke(sessionID string, atUnix int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s SET revoked_at = $2
+		WHERE session_id = $1 AND revoked_at = 0
+	`, a.table)
+	res, err := a.db.ExecContext(context.Background(), query, sessionID, atUnix)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	// Nothing updated: either already revoked or unknown
+	_, err = a.Get(sessionID)
+	return err
+}
+
+func (a *PgAdapter) PutRefreshToken(rec StoredRefreshToken) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (token_hash, session_id, generation, issued_at, expires_at, used_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING
+	`, a.refreshTable)
+	res, err := a.db.ExecContext(context.Background(), query,
+		rec.TokenHash, rec.SessionID, rec.Generation, rec.IssuedAtUnixTimestamp, rec.ExpiresAtUnixTimestamp, rec.UsedAtUnixTimestamp)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRefreshTokenExists
+	}
+	return nil
+}
+
+func (a *PgAdapter) GetRefreshToken(tokenHash string) (*StoredRefreshToken, error) {
+	query := fmt.Sprintf(`
+		SELECT token_hash, session_id, generation, issued_at, expires_at, used_at
+		FROM %s WHERE token_hash = $1
+	`, a.refreshTable)
+	var rec StoredRefreshToken
+	err := a.db.QueryRowContext(context.Background(), query, tokenHash).Scan(
+		&rec.TokenHash, &rec.SessionID, &rec.Generation, &rec.IssuedAtUnixTimestamp, &rec.ExpiresAtUnixTimestamp, &rec.UsedAtUnixTimestamp)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (a *PgAdapter) ConsumeRefreshToken(tokenHash string, atUnix int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s SET used_at = $2
+		WHERE token_hash = $1 AND used_at = 0
+	`, a.refreshTable)
+	res, err := a.db.ExecContext(context.Background(), query, tokenHash, atUnix)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	// Nothing updated: either already used or unknown
+	if _, err := a.GetRefreshToken(tokenHash); err != nil {
+		return err
+	}
+	return ErrRefreshTokenAlreadyUsed
+}
+
+func scanSession(row interface{ Scan(...any) error }) (*StoredSession, error) {
+	var s StoredSession
+	err := row.Scan(&s.SessionID, &s.TenantID, &s.UserID, pq.Array(&s.UserGroupIDs),
+		&s.DeviceIdentifier, &s.DeviceName, &s.IssuedAtUnixTimestamp, &s.RevokedAtUnixTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,56 @@
+package session_store
+
+import "errors"
+
+var (
+	ErrSessionNotFound         = errors.New("session not found")
+	ErrSessionExists           = errors.New("session already exists")
+	ErrRefreshTokenNotFound    = errors.New("refresh token not found")
+	ErrRefreshTokenExists      = errors.New("refresh token already exists")
+	ErrRefreshTokenAlreadyUsed = errors.New("refresh token already used")
+)
+
+// SessionStore remembers every OPAQUE login, and the rotating refresh tokens issued for
+// it, so its tickets and Hydrate states can be cut off server-side (see
+// auth_plugins/persephone/session).
+type SessionStore interface {
+	Put(s StoredSession) error
+	Get(sessionID string) (*StoredSession, error)
+
+	// ListForUser returns the sessions of one CoreUser issued at or after issuedSinceUnix,
+	// revoked ones included.
+	ListForUser(tenantID, userID string, issuedSinceUnix int64) ([]StoredSession, error)
+
+	// Revoke stamps the session revoked; revoking twice keeps the first timestamp.
+	Revoke(sessionID string, atUnix int64) error
+
+	PutRefreshToken(rec StoredRefreshToken) error
+	GetRefreshToken(tokenHash string) (*StoredRefreshToken, error)
+
+	// ConsumeRefreshToken marks the token used. It must be atomic: of two concurrent
+	// calls for the same token exactly one succeeds, the other gets ErrRefreshTokenAlreadyUsed.
+	ConsumeRefreshToken(tokenHash string, atUnix int64) error
+}
+
+// StoredSession is the value persisted per login.
+type StoredSession struct {
+	SessionID              string   `json:"session_id"`
+	TenantID               string   `json:"tenant_id"`
+	UserID                 string   `json:"user_id"`
+	UserGroupIDs           []string `json:"user_group_ids"` // Groups the login issued tickets for
+	DeviceIdentifier       string   `json:"device_identifier,omitempty"`
+	DeviceName             string   `json:"device_name,omitempty"` // As reported by the client, for display only
+	IssuedAtUnixTimestamp  int64    `json:"issued_at_unix_timestamp"`
+	RevokedAtUnixTimestamp int64    `json:"revoked_at_unix_timestamp,omitempty"` // 0 = active
+}
+
+// StoredRefreshToken is one generation of a session's rotating refresh token. Only the
+// token's hash is kept.
+type StoredRefreshToken struct {
+	TokenHash              string `json:"token_hash"`
+	SessionID              string `json:"session_id"`
+	Generation             int    `json:"generation"` // 1 for the token handed out at login
+	IssuedAtUnixTimestamp  int64  `json:"issued_at_unix_timestamp"`
+	ExpiresAtUnixTimestamp int64  `json:"expires_at_unix_timestamp"`
+	UsedAtUnixTimestamp    int64  `json:"used_at_unix_timestamp,omitempty"` // 0 = unused
+}
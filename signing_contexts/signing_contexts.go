@@ -0,0 +1,28 @@
+// Package signing_contexts holds the Ed448 signing contexts (RFC 8032 "ctx"), one per
+// signed artifact type, for use with ed448_api.SignWithContext / VerifyWithContext. A
+// signature made for one type never verifies as another, even if a key ends up shared
+// between them. The package has no dependencies so that offline verifiers can share it.
+// Changing a value invalidates every outstanding artifact of that type.
+package signing_contexts
+
+const (
+	AuthTicket = "mng-auth/v1/auth-ticket"
+	AuthGrant  = "mng-auth/v1/auth-grant"
+	TraceID    = "mng-auth/v1/psp-trace-id"
+
+	Hashcash  = "mng-auth/v1/pow/hashcash"
+	Argon2PoW = "mng-auth/v1/pow/argon2id"
+
+	OpaqueState       = "mng-auth/v1/opaque/server-state"
+	OpaqueEnvelopeKey = "mng-auth/v1/opaque/envelope-key"
+
+	HydrateState       = "mng-auth/v1/hydrate/rehydrated-ticket"
+	HydrateEnvelopeKey = "mng-auth/v1/hydrate/envelope-key"
+
+	DelegatedEntitlement = "mng-auth/v1/delegated-entitlement"
+	AuditLogEntry        = "mng-auth/v1/audit-log/entry"
+
+	HestiaAuthorityNode = "mng-auth/v1/hestia/authority-node"
+	HestiaTAProposal    = "mng-auth/v1/hestia/ta-proposal"
+	HestiaTAVote        = "mng-auth/v1/hestia/ta-vote"
+)
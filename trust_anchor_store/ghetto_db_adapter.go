@@ -0,0 +1,71 @@
+package trust_anchor_store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+)
+
+const ghettoJournalTable = "trust_anchor_journal"
+
+type GhettoAdapter struct {
+	db *ghetto_db.GhettoDB
+	mu sync.Mutex // Append checks the next Seq and writes it in one step
+}
+
+func NewGhettoAdapter(db *ghetto_db.GhettoDB) *GhettoAdapter {
+	db.CreateTable(ghettoJournalTable)
+	return &GhettoAdapter{db: db}
+}
+
+func (a *GhettoAdapter) Append(rec StoredRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	keys, err := a.db.ListKeys(ghettoJournalTable)
+	if err != nil {
+		return err
+	}
+	if rec.Seq != int64(len(keys))+1 {
+		return ErrSeqConflict
+	}
+
+	val, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal trust anchor record: %w", err)
+	}
+	return a.db.Insert(ghettoJournalTable, seqKey(rec.Seq), val)
+}
+
+func (a *GhettoAdapter) ListSince(seq int64) ([]StoredRecord, error) {
+	keys, err := a.db.ListKeys(ghettoJournalTable)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	var out []StoredRecord
+	for _, k := range keys {
+		if k <= seqKey(seq) {
+			continue
+		}
+		val, err := a.db.Get(ghettoJournalTable, k)
+		if err != nil {
+			return nil, err
+		}
+		var rec StoredRecord
+		if err := json.Unmarshal(val, &rec); err != nil {
+			return nil, fmt.Errorf("unmarshal trust anchor record %s: %w", k, err)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// seqKey zero-pads seq so keys sort in append order.
+func seqKey(seq int64) string {
+	return fmt.Sprintf("%020d", seq)
+}
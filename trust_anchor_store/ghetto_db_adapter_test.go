@@ -0,0 +1,53 @@
+package trust_anchor_store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/drlzh/mng-app-user-auth-prot/utils/ghetto_db"
+)
+
+func TestGhettoAdapter_ListKeepsAppendOrder(t *testing.T) {
+	store := NewGhettoAdapter(ghetto_db.New())
+
+	for i := 0; i < 12; i++ {
+		if err := store.Append(StoredRecord{Seq: int64(i + 1), Kind: RecordVote, Encoded: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatalf("Append %d failed: %v", i, err)
+		}
+	}
+
+	records, err := store.ListSince(0)
+	if err != nil {
+		t.Fatalf("ListSince failed: %v", err)
+	}
+	if len(records) != 12 {
+		t.Fatalf("expected 12 records, got %d", len(records))
+	}
+	for i, rec := range records {
+		if rec.Seq != int64(i+1) || string(rec.Encoded) != fmt.Sprint(i) {
+			t.Errorf("record %d out of order: seq %d, encoded %q", i, rec.Seq, rec.Encoded)
+		}
+	}
+
+	records, err = store.ListSince(10)
+	if err != nil {
+		t.Fatalf("ListSince failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Seq != 11 {
+		t.Errorf("expected records 11 and 12, got %+v", records)
+	}
+}
+
+func TestGhettoAdapter_AppendRejectsStaleSeq(t *testing.T) {
+	store := NewGhettoAdapter(ghetto_db.New())
+
+	if err := store.Append(StoredRecord{Seq: 1, Kind: RecordProposal}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	for _, seq := range []int64{1, 3} {
+		if err := store.Append(StoredRecord{Seq: seq, Kind: RecordVote}); !errors.Is(err, ErrSeqConflict) {
+			t.Errorf("Append at seq %d: expected ErrSeqConflict, got %v", seq, err)
+		}
+	}
+}
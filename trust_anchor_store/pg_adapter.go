@@ -0,0 +1,74 @@
+package trust_anchor_store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+/*
+CREATE TABLE trust_anchor_journal (
+    seq BIGINT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    accepted_at BIGINT NOT NULL,
+    encoded BYTEA NOT NULL
+);
+*/
+
+type PgAdapter struct {
+	db    *sql.DB
+	table string
+}
+
+func NewPgAdapter(db *sql.DB) *PgAdapter {
+	return &PgAdapter{db: db, table: "trust_anchor_journal"}
+}
+
+// Append is a single statement: the WHERE clause keeps the journal gapless, and the
+// primary key turns a concurrent append of the same seq into a no-op for the loser.
+func (a *PgAdapter) Append(rec StoredRecord) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (seq, kind, accepted_at, encoded)
+		SELECT $1, $2, $3, $4
+		WHERE (SELECT COALESCE(MAX(seq), 0) FROM %s) = $1 - 1
+		ON CONFLICT DO NOTHING
+	`, a.table, a.table)
+	res, err := a.db.ExecContext(context.Background(), query, rec.Seq, rec.Kind, rec.AcceptedAtUnixTimestamp, rec.Encoded)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrSeqConflict
+	}
+	return nil
+}
+
+func (a *PgAdapter) ListSince(seq int64) ([]StoredRecord, error) {
+	query := fmt.Sprintf(`
+		SELECT seq, kind, accepted_at, encoded
+		FROM %s
+		WHERE seq > $1
+		ORDER BY seq
+	`, a.table)
+	rows, err := a.db.QueryContext(context.Background(), query, seq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StoredRecord
+	for rows.Next() {
+		var rec StoredRecord
+		if err := rows.Scan(&rec.Seq, &rec.Kind, &rec.AcceptedAtUnixTimestamp, &rec.Encoded); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,32 @@
+package trust_anchor_store
+
+import "errors"
+
+const (
+	RecordProposal = "proposal"
+	RecordVote     = "vote"
+)
+
+// ErrSeqConflict means another writer appended first: the record was checked against a
+// journal that is no longer the latest.
+var ErrSeqConflict = errors.New("trust anchor journal has moved on")
+
+// TrustAnchorStore is the append-only journal of trust anchor records a Registry accepted.
+// Replaying it in order from the same genesis set rebuilds every admission and veto.
+type TrustAnchorStore interface {
+	// Append stores rec as record rec.Seq, which must be one past the last stored record.
+	// Otherwise it stores nothing and returns ErrSeqConflict.
+	Append(rec StoredRecord) error
+
+	// ListSince returns every record after seq in the order it was appended; seq 0 lists
+	// the whole journal.
+	ListSince(seq int64) ([]StoredRecord, error)
+}
+
+// StoredRecord is one accepted proposal or vote.
+type StoredRecord struct {
+	Seq                     int64  `json:"seq"`
+	Kind                    string `json:"kind"`                       // Record*
+	AcceptedAtUnixTimestamp int64  `json:"accepted_at_unix_timestamp"` // Registry clock when accepted
+	Encoded                 []byte `json:"encoded"`                    // Signed proposal or vote, JSON
+}
@@ -1,10 +1,19 @@
+//go:build !production
+
 package user_auth_global_config
 
 import (
+	"crypto/ed25519"
+
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
 	"github.com/drlzh/mng-app-user-auth-prot/crypto/encryption/rsa/rsa_api"
+	"github.com/drlzh/mng-app-user-auth-prot/keystore"
 )
 
+// Development keys, compiled in for local runs and tests only.
+// Production builds (-tags production) carry none of this and must install a
+// keystore loaded with keystore.LoadFromEnvironment before serving.
+
 var (
 	ed448PersephonePrivateKey = ed448_api.PrivateKey{
 		0xCE, 0xDF, 0xAA, 0x90, 0x11, 0xA3, 0xAC, 0x62,
@@ -1479,84 +1488,120 @@ var (
 		0xD1, 0xDD, 0x9F, 0x58, 0x9E, 0xAA, 0xA6, 0x2B,
 		0x80,
 	}
-)
-
-func Ed448HashcashPrivateKey() ed448_api.PrivateKey {
-	return ed448HashcashPrivateKey
-}
-
-func Ed448HashcashPublicKey() ed448_api.PublicKey {
-	return ed448HashcashPublicKey
-}
-
-func Ed448PersephonePrivateKey() ed448_api.PrivateKey {
-	return ed448PersephonePrivateKey
-}
-
-func Ed448PersephonePublicKey() ed448_api.PublicKey {
-	return ed448PersephonePublicKey
-}
-
-func Ed448OpaquePrivateKey() ed448_api.PrivateKey {
-	return ed448OpaquePrivateKey
-}
-
-func Ed448OpaquePublicKey() ed448_api.PublicKey {
-	return ed448OpaquePublicKey
-}
-
-func OpaqueServerId() []byte {
-	return opaqueServerId
-}
-
-func OpaqueServerPrivateKey() []byte {
-	return opaqueServerPrivateKey
-}
-
-func OpaqueServerPublicKey() []byte {
-	return opaqueServerPublicKey
-}
 
-func OpaqueServerSecretOprfSeed() []byte {
-	return opaqueServerSecretOprfSeed
-}
-
-func RsaOpaqueEnvelopePrivateKey() rsa_api.PrivateKey {
-	return rsaOpaqueEnvelopePrivateKey
-}
-
-func RsaOpaqueEnvelopePublicKey() rsa_api.PublicKey {
-	return rsaOpaqueEnvelopePublicKey
-}
-
-func Ed448AuthTicketPrivateKey() ed448_api.PrivateKey {
-	return ed448AuthTicketPrivateKey
-}
+	// Audit log signing keypair, aka Mnemosyne (she remembers everything)
+	ed448AuditPrivateKey = ed448_api.PrivateKey{
+		0xB9, 0x89, 0xCB, 0x59, 0x93, 0x27, 0x62, 0x6F,
+		0xE4, 0x96, 0xC9, 0xCE, 0xE2, 0x70, 0xA4, 0xA3,
+		0x71, 0xBA, 0x5C, 0xA5, 0x34, 0x81, 0x4C, 0x94,
+		0x80, 0x4F, 0x15, 0xED, 0x97, 0x8F, 0x3D, 0x10,
+		0x3D, 0xBB, 0x65, 0x33, 0xD6, 0xCC, 0x29, 0x73,
+		0xB5, 0x8F, 0xFA, 0x63, 0xDD, 0x50, 0x30, 0xBF,
+		0xB5, 0x4A, 0xA3, 0xC6, 0x2C, 0xDA, 0x71, 0xAC,
+		0x0E, 0x21, 0x1A, 0x11, 0xD1, 0xE4, 0x3B, 0x5C,
+		0xB1, 0x09, 0x82, 0x8C, 0x0B, 0x47, 0x5E, 0x19,
+		0xC9, 0x77, 0x81, 0xE6, 0xAA, 0x3F, 0xD7, 0x98,
+		0x95, 0x08, 0x0C, 0x1F, 0xFA, 0xD7, 0x7A, 0x5E,
+		0x65, 0xA5, 0xCF, 0xD3, 0xF9, 0x8C, 0x1D, 0x2A,
+		0xD9, 0xC7, 0x17, 0x97, 0xF6, 0xD5, 0x59, 0xCA,
+		0x15, 0x37, 0x3B, 0xDA, 0x82, 0xD1, 0x42, 0xFF,
+		0xAA, 0x00,
+	}
 
-func Ed448AuthTicketPublicKey() ed448_api.PublicKey {
-	return ed448AuthTicketPublicKey
-}
+	ed448AuditPublicKey = ed448_api.PublicKey{
+		0x21, 0x1A, 0x11, 0xD1, 0xE4, 0x3B, 0x5C, 0xB1,
+		0x09, 0x82, 0x8C, 0x0B, 0x47, 0x5E, 0x19, 0xC9,
+		0x77, 0x81, 0xE6, 0xAA, 0x3F, 0xD7, 0x98, 0x95,
+		0x08, 0x0C, 0x1F, 0xFA, 0xD7, 0x7A, 0x5E, 0x65,
+		0xA5, 0xCF, 0xD3, 0xF9, 0x8C, 0x1D, 0x2A, 0xD9,
+		0xC7, 0x17, 0x97, 0xF6, 0xD5, 0x59, 0xCA, 0x15,
+		0x37, 0x3B, 0xDA, 0x82, 0xD1, 0x42, 0xFF, 0xAA,
+		0x00,
+	}
 
-func RsaHydratePrivateKey() rsa_api.PrivateKey {
-	return rsaHydratePrivateKey
-}
+	// Delegated entitlement signing keypair, aka Hermes (acts on another's behalf)
+	ed448DelegationPrivateKey = ed448_api.PrivateKey{
+		0x1F, 0xED, 0x93, 0xE1, 0x48, 0x2F, 0x55, 0x1F,
+		0x87, 0xE6, 0xCF, 0x39, 0x40, 0x6C, 0x53, 0x18,
+		0x34, 0x9E, 0xC1, 0xE3, 0x00, 0x45, 0xD2, 0xE0,
+		0xFB, 0xB3, 0x9F, 0x8F, 0xF9, 0xBD, 0x29, 0x66,
+		0x1C, 0xAE, 0xD9, 0x3F, 0x38, 0x65, 0xD2, 0x01,
+		0x43, 0x39, 0x9F, 0x98, 0xBA, 0x0D, 0x87, 0xB9,
+		0xBB, 0x00, 0xBF, 0xCA, 0x2D, 0x21, 0x86, 0x6A,
+		0xE6, 0xDF, 0x7F, 0x7F, 0xCB, 0x89, 0x3D, 0x78,
+		0x92, 0xB6, 0xB5, 0xE8, 0x8A, 0xCB, 0x47, 0x1D,
+		0x74, 0xFA, 0x42, 0xC1, 0x38, 0xE3, 0xF5, 0x70,
+		0x96, 0x1B, 0xF9, 0x66, 0x31, 0xF1, 0x60, 0x13,
+		0x25, 0x89, 0x41, 0xED, 0x0D, 0xF3, 0x8C, 0xAD,
+		0x93, 0x04, 0xB2, 0xCA, 0xD9, 0x87, 0x86, 0xDD,
+		0x47, 0x48, 0x23, 0xA8, 0xD2, 0x58, 0x35, 0xCD,
+		0xDE, 0x80,
+	}
 
-func RsaHydratePublicKey() rsa_api.PublicKey {
-	return rsaHydratePublicKey
-}
+	ed448DelegationPublicKey = ed448_api.PublicKey{
+		0xDF, 0x7F, 0x7F, 0xCB, 0x89, 0x3D, 0x78, 0x92,
+		0xB6, 0xB5, 0xE8, 0x8A, 0xCB, 0x47, 0x1D, 0x74,
+		0xFA, 0x42, 0xC1, 0x38, 0xE3, 0xF5, 0x70, 0x96,
+		0x1B, 0xF9, 0x66, 0x31, 0xF1, 0x60, 0x13, 0x25,
+		0x89, 0x41, 0xED, 0x0D, 0xF3, 0x8C, 0xAD, 0x93,
+		0x04, 0xB2, 0xCA, 0xD9, 0x87, 0x86, 0xDD, 0x47,
+		0x48, 0x23, 0xA8, 0xD2, 0x58, 0x35, 0xCD, 0xDE,
+		0x80,
+	}
 
-func Ed448HydratePrivateKey() ed448_api.PrivateKey {
-	return ed448HydratePrivateKey
-}
+	// Biscuit root keypair, Ed25519 since that is all biscuit-go v2 supports, aka Kore
+	ed25519BiscuitPrivateKey = ed25519.PrivateKey{
+		0xC3, 0xB5, 0x6F, 0xA3, 0xC0, 0x59, 0x37, 0x1B,
+		0xD5, 0x96, 0x48, 0xAB, 0xE7, 0xBD, 0x4D, 0x63,
+		0xBE, 0x40, 0x4F, 0xA4, 0x92, 0xBD, 0xE3, 0xC3,
+		0x59, 0xF9, 0x23, 0x9A, 0x69, 0xE2, 0x1D, 0xDA,
+		0xD6, 0x87, 0xD2, 0x65, 0x46, 0x1A, 0xE6, 0xBF,
+		0xB8, 0xE8, 0x16, 0x03, 0x2A, 0x03, 0xF9, 0x8F,
+		0x19, 0x10, 0x68, 0x5B, 0x9D, 0x98, 0x5A, 0xEE,
+		0x45, 0x1E, 0x47, 0x05, 0x6D, 0x48, 0x71, 0xB4,
+	}
 
-func Ed448HydratePublicKey() ed448_api.PublicKey {
-	return ed448HydratePublicKey
-}
+	ed25519BiscuitPublicKey = ed25519.PublicKey{
+		0xD6, 0x87, 0xD2, 0x65, 0x46, 0x1A, 0xE6, 0xBF,
+		0xB8, 0xE8, 0x16, 0x03, 0x2A, 0x03, 0xF9, 0x8F,
+		0x19, 0x10, 0x68, 0x5B, 0x9D, 0x98, 0x5A, 0xEE,
+		0x45, 0x1E, 0x47, 0x05, 0x6D, 0x48, 0x71, 0xB4,
+	}
+)
 
-func Ed448AuthGrantPrivateKey() ed448_api.PrivateKey {
-	return ed448AuthGrantPrivateKey
+func init() {
+	if err := SetKeyStore(devKeyStore()); err != nil {
+		panic(err)
+	}
 }
 
-func Ed448AuthGrantPublicKey() ed448_api.PublicKey {
-	return ed448AuthGrantPublicKey
+func devKeyStore() keystore.KeyStore {
+	return keystore.NewMemoryAdapter(
+		keystore.Key{Name: keystore.Ed448PersephonePrivate, ID: "dev", Material: ed448PersephonePrivateKey},
+		keystore.Key{Name: keystore.Ed448PersephonePublic, ID: "dev", Material: ed448PersephonePublicKey},
+		keystore.Key{Name: keystore.Ed448OpaquePrivate, ID: "dev", Material: ed448OpaquePrivateKey},
+		keystore.Key{Name: keystore.Ed448OpaquePublic, ID: "dev", Material: ed448OpaquePublicKey},
+		keystore.Key{Name: keystore.Ed448HashcashPrivate, ID: "dev", Material: ed448HashcashPrivateKey},
+		keystore.Key{Name: keystore.Ed448HashcashPublic, ID: "dev", Material: ed448HashcashPublicKey},
+		keystore.Key{Name: keystore.Ed448AuthTicketPrivate, ID: "FirstBlood", Material: ed448AuthTicketPrivateKey},
+		keystore.Key{Name: keystore.Ed448AuthTicketPublic, ID: "FirstBlood", Material: ed448AuthTicketPublicKey},
+		keystore.Key{Name: keystore.Ed448AuthGrantPrivate, ID: "Artemis", Material: ed448AuthGrantPrivateKey},
+		keystore.Key{Name: keystore.Ed448AuthGrantPublic, ID: "Artemis", Material: ed448AuthGrantPublicKey},
+		keystore.Key{Name: keystore.Ed448HydratePrivate, ID: "Eleusis", Material: ed448HydratePrivateKey},
+		keystore.Key{Name: keystore.Ed448HydratePublic, ID: "Eleusis", Material: ed448HydratePublicKey},
+		keystore.Key{Name: keystore.Ed448AuditPrivate, ID: "Mnemosyne", Material: ed448AuditPrivateKey},
+		keystore.Key{Name: keystore.Ed448AuditPublic, ID: "Mnemosyne", Material: ed448AuditPublicKey},
+		keystore.Key{Name: keystore.Ed448DelegationPrivate, ID: "Hermes", Material: ed448DelegationPrivateKey},
+		keystore.Key{Name: keystore.Ed448DelegationPublic, ID: "Hermes", Material: ed448DelegationPublicKey},
+		keystore.Key{Name: keystore.RsaOpaqueEnvelopePrivate, ID: "dev", Material: rsaOpaqueEnvelopePrivateKey},
+		keystore.Key{Name: keystore.RsaOpaqueEnvelopePublic, ID: "dev", Material: rsaOpaqueEnvelopePublicKey},
+		keystore.Key{Name: keystore.RsaHydratePrivate, ID: "Hydroxide", Material: rsaHydratePrivateKey},
+		keystore.Key{Name: keystore.RsaHydratePublic, ID: "Hydroxide", Material: rsaHydratePublicKey},
+		keystore.Key{Name: keystore.Ed25519BiscuitPrivate, ID: "Kore", Material: ed25519BiscuitPrivateKey},
+		keystore.Key{Name: keystore.Ed25519BiscuitPublic, ID: "Kore", Material: ed25519BiscuitPublicKey},
+		keystore.Key{Name: keystore.OpaqueServerID, ID: "dev", Material: opaqueServerId},
+		keystore.Key{Name: keystore.OpaqueServerPrivate, ID: "dev", Material: opaqueServerPrivateKey},
+		keystore.Key{Name: keystore.OpaqueServerPublic, ID: "dev", Material: opaqueServerPublicKey},
+		keystore.Key{Name: keystore.OpaqueServerOprfSeed, ID: "dev", Material: opaqueServerSecretOprfSeed},
+	)
 }
@@ -0,0 +1,243 @@
+package user_auth_global_config
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/auth/ed448/ed448_api"
+	"github.com/drlzh/mng-app-user-auth-prot/crypto/encryption/rsa/rsa_api"
+	"github.com/drlzh/mng-app-user-auth-prot/keystore"
+)
+
+// activeKeyStore is installed once at startup (ROOT_KEYS.go in dev builds,
+// cmd/api in production) and only read afterwards.
+var activeKeyStore keystore.KeyStore
+
+// SetKeyStore installs the keystore every key accessor below reads from.
+// It fails unless the keystore holds all keystore.RequiredKeys.
+func SetKeyStore(ks keystore.KeyStore) error {
+	if err := keystore.Validate(ks); err != nil {
+		return err
+	}
+	activeKeyStore = ks
+	return nil
+}
+
+// ActiveKeyStore returns the installed keystore, e.g. to look up key IDs.
+func ActiveKeyStore() keystore.KeyStore {
+	return activeKeyStore
+}
+
+// keyMaterial returns the active key for name. It panics on a missing key: SetKeyStore
+// validated every required name, so this only fires when no keystore was installed at all.
+func keyMaterial(name string) []byte {
+	return signingKey(name).Material
+}
+
+// signingKey returns the active key for name; its ID goes into the signed artifact.
+func signingKey(name string) keystore.Key {
+	if activeKeyStore == nil {
+		panic("user_auth_global_config: no keystore installed, call SetKeyStore at startup")
+	}
+	k, err := activeKeyStore.Get(name)
+	if err != nil {
+		panic(fmt.Sprintf("user_auth_global_config: %v", err))
+	}
+	return k
+}
+
+// verificationKey resolves the key an artifact claims to be signed with.
+// Active and verify-only keys resolve, retired or unknown IDs don't.
+func verificationKey(name, id string) ([]byte, error) {
+	if activeKeyStore == nil {
+		return nil, errors.New("no keystore installed")
+	}
+	k, err := activeKeyStore.GetByID(name, id)
+	if err != nil {
+		return nil, err
+	}
+	return k.Material, nil
+}
+
+// verificationKeyAt is verificationKey for artifacts that carry their issuance time: the
+// key must also have been valid then, as auth_verifier checks downstream.
+func verificationKeyAt(name, id string, issuedAt int64) ([]byte, error) {
+	if activeKeyStore == nil {
+		return nil, errors.New("no keystore installed")
+	}
+	k, err := activeKeyStore.GetByID(name, id)
+	if err != nil {
+		return nil, err
+	}
+	if !k.CoversIssuance(issuedAt) {
+		return nil, keystore.ErrKeyOutsideWindow
+	}
+	return k.Material, nil
+}
+
+// archivedKeyAt resolves keys for records kept indefinitely, such as the audit log:
+// retiring a key stops it from vouching for new artifacts, but not for what it signed
+// within its window.
+func archivedKeyAt(name, id string, issuedAt int64) ([]byte, error) {
+	if activeKeyStore == nil {
+		return nil, errors.New("no keystore installed")
+	}
+	for _, k := range activeKeyStore.List(name) {
+		if k.ID != id {
+			continue
+		}
+		if !k.CoversIssuance(issuedAt) {
+			return nil, keystore.ErrKeyOutsideWindow
+		}
+		return k.Material, nil
+	}
+	return nil, fmt.Errorf("%w: %s/%s", keystore.ErrKeyNotFound, name, id)
+}
+
+// ─── Rotating signing keys ──────────────────────────────────────
+
+func Ed448AuthTicketSigningKey() (string, ed448_api.PrivateKey) {
+	k := signingKey(keystore.Ed448AuthTicketPrivate)
+	return k.ID, ed448_api.PrivateKey(k.Material)
+}
+
+func Ed448AuthTicketVerificationKey(id string, issuedAt int64) (ed448_api.PublicKey, error) {
+	pub, err := verificationKeyAt(keystore.Ed448AuthTicketPublic, id, issuedAt)
+	return ed448_api.PublicKey(pub), err
+}
+
+func Ed448AuthGrantSigningKey() (string, ed448_api.PrivateKey) {
+	k := signingKey(keystore.Ed448AuthGrantPrivate)
+	return k.ID, ed448_api.PrivateKey(k.Material)
+}
+
+func Ed448AuthGrantVerificationKey(id string, issuedAt int64) (ed448_api.PublicKey, error) {
+	pub, err := verificationKeyAt(keystore.Ed448AuthGrantPublic, id, issuedAt)
+	return ed448_api.PublicKey(pub), err
+}
+
+func Ed448HydrateSigningKey() (string, ed448_api.PrivateKey) {
+	k := signingKey(keystore.Ed448HydratePrivate)
+	return k.ID, ed448_api.PrivateKey(k.Material)
+}
+
+func Ed448HydrateVerificationKey(id string) (ed448_api.PublicKey, error) {
+	pub, err := verificationKey(keystore.Ed448HydratePublic, id)
+	return ed448_api.PublicKey(pub), err
+}
+
+func Ed448AuditSigningKey() (string, ed448_api.PrivateKey) {
+	k := signingKey(keystore.Ed448AuditPrivate)
+	return k.ID, ed448_api.PrivateKey(k.Material)
+}
+
+// Ed448AuditVerificationKey resolves retired audit keys too, for entries written at
+// writtenAt inside their window; see archivedKeyAt.
+func Ed448AuditVerificationKey(id string, writtenAt int64) (ed448_api.PublicKey, error) {
+	pub, err := archivedKeyAt(keystore.Ed448AuditPublic, id, writtenAt)
+	return ed448_api.PublicKey(pub), err
+}
+
+func Ed448DelegationSigningKey() (string, ed448_api.PrivateKey) {
+	k := signingKey(keystore.Ed448DelegationPrivate)
+	return k.ID, ed448_api.PrivateKey(k.Material)
+}
+
+func Ed448DelegationVerificationKey(id string, issuedAt int64) (ed448_api.PublicKey, error) {
+	pub, err := verificationKeyAt(keystore.Ed448DelegationPublic, id, issuedAt)
+	return ed448_api.PublicKey(pub), err
+}
+
+// BiscuitRootSigningKey returns the Ed25519 root key Biscuit tokens are minted with.
+func BiscuitRootSigningKey() (string, ed25519.PrivateKey) {
+	k := signingKey(keystore.Ed25519BiscuitPrivate)
+	return k.ID, ed25519.PrivateKey(k.Material)
+}
+
+func BiscuitRootVerificationKey(id string) (ed25519.PublicKey, error) {
+	pub, err := verificationKey(keystore.Ed25519BiscuitPublic, id)
+	return ed25519.PublicKey(pub), err
+}
+
+// ─── Current keys ───────────────────────────────────────────────
+
+func Ed448HashcashPrivateKey() ed448_api.PrivateKey {
+	return ed448_api.PrivateKey(keyMaterial(keystore.Ed448HashcashPrivate))
+}
+
+func Ed448HashcashPublicKey() ed448_api.PublicKey {
+	return ed448_api.PublicKey(keyMaterial(keystore.Ed448HashcashPublic))
+}
+
+func Ed448PersephonePrivateKey() ed448_api.PrivateKey {
+	return ed448_api.PrivateKey(keyMaterial(keystore.Ed448PersephonePrivate))
+}
+
+func Ed448PersephonePublicKey() ed448_api.PublicKey {
+	return ed448_api.PublicKey(keyMaterial(keystore.Ed448PersephonePublic))
+}
+
+func Ed448OpaquePrivateKey() ed448_api.PrivateKey {
+	return ed448_api.PrivateKey(keyMaterial(keystore.Ed448OpaquePrivate))
+}
+
+func Ed448OpaquePublicKey() ed448_api.PublicKey {
+	return ed448_api.PublicKey(keyMaterial(keystore.Ed448OpaquePublic))
+}
+
+func OpaqueServerId() []byte {
+	return keyMaterial(keystore.OpaqueServerID)
+}
+
+func OpaqueServerPrivateKey() []byte {
+	return keyMaterial(keystore.OpaqueServerPrivate)
+}
+
+func OpaqueServerPublicKey() []byte {
+	return keyMaterial(keystore.OpaqueServerPublic)
+}
+
+func OpaqueServerSecretOprfSeed() []byte {
+	return keyMaterial(keystore.OpaqueServerOprfSeed)
+}
+
+func RsaOpaqueEnvelopePrivateKey() rsa_api.PrivateKey {
+	return keyMaterial(keystore.RsaOpaqueEnvelopePrivate)
+}
+
+func RsaOpaqueEnvelopePublicKey() rsa_api.PublicKey {
+	return keyMaterial(keystore.RsaOpaqueEnvelopePublic)
+}
+
+func Ed448AuthTicketPrivateKey() ed448_api.PrivateKey {
+	return ed448_api.PrivateKey(keyMaterial(keystore.Ed448AuthTicketPrivate))
+}
+
+func Ed448AuthTicketPublicKey() ed448_api.PublicKey {
+	return ed448_api.PublicKey(keyMaterial(keystore.Ed448AuthTicketPublic))
+}
+
+func RsaHydratePrivateKey() rsa_api.PrivateKey {
+	return keyMaterial(keystore.RsaHydratePrivate)
+}
+
+func RsaHydratePublicKey() rsa_api.PublicKey {
+	return keyMaterial(keystore.RsaHydratePublic)
+}
+
+func Ed448HydratePrivateKey() ed448_api.PrivateKey {
+	return ed448_api.PrivateKey(keyMaterial(keystore.Ed448HydratePrivate))
+}
+
+func Ed448HydratePublicKey() ed448_api.PublicKey {
+	return ed448_api.PublicKey(keyMaterial(keystore.Ed448HydratePublic))
+}
+
+func Ed448AuthGrantPrivateKey() ed448_api.PrivateKey {
+	return ed448_api.PrivateKey(keyMaterial(keystore.Ed448AuthGrantPrivate))
+}
+
+func Ed448AuthGrantPublicKey() ed448_api.PublicKey {
+	return ed448_api.PublicKey(keyMaterial(keystore.Ed448AuthGrantPublic))
+}
@@ -2,65 +2,27 @@ package user_auth_global_config
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"net/url"
-	"strings"
-)
-
-type UniqueUser struct {
-	TenantID    string `json:"tenant_id"`        // Which dojo?
-	UserGroupID string `json:"user_group_id"`    // Role or others (if we ever need to group a few users to test groups, this will be useful)
-	UserID      string `json:"user_id"`          // Currently equiv. to username
-	SubID       string `json:"sub_id,omitempty"` // Reserved for future use if T+UG is not enough
-}
 
-type CoreUser struct {
-	TenantID string `json:"tenant_id"`
-	UserID   string `json:"user_id"`
-}
+	"github.com/drlzh/mng-app-user-auth-prot/user_identity"
+)
 
-type UserGroupBinding struct {
-	CoreUser    CoreUser `json:"core_user"`
-	UserGroupID string   `json:"user_group_id"`    // e.g. "student", "coach"
-	SubID       string   `json:"sub_id,omitempty"` // Optional disambiguator
-}
+// The identity types live in user_identity so that offline verifiers can use them
+// without this package's key material.
+type (
+	UniqueUser       = user_identity.UniqueUser
+	CoreUser         = user_identity.CoreUser
+	UserGroupBinding = user_identity.UserGroupBinding
+)
 
 type OpaqueUserRecord struct {
 	OpaqueRecord []byte             `json:"opaque_record"` // OPAQUE client record
 	UserGroups   []UserGroupBinding `json:"user_groups"`   // Assigned roles
 }
 
-// EncodeKey returns a safe DB key like "dojo-a|akira"
-func (u CoreUser) EncodeKey() string {
-	encode := url.PathEscape
-	return encode(u.TenantID) + "|" + encode(u.UserID)
-}
-
 // DecodeKey parses a DB key into a CoreUser.
 func DecodeKey(s string) (CoreUser, error) {
-	parts := strings.Split(s, "|")
-	if len(parts) != 2 {
-		return CoreUser{}, errors.New("invalid CoreUser key format")
-	}
-	decode := url.PathUnescape
-	tid, err1 := decode(parts[0])
-	uid, err2 := decode(parts[1])
-	if err1 != nil || err2 != nil {
-		return CoreUser{}, errors.New("failed to decode CoreUser key")
-	}
-	return CoreUser{TenantID: tid, UserID: uid}, nil
-}
-
-// BelongsTo verifies this role matches a CoreUser identity.
-func (r UserGroupBinding) BelongsTo(user CoreUser) bool {
-	return r.CoreUser.TenantID == user.TenantID && r.CoreUser.UserID == user.UserID
-}
-
-// EncodeKey generates a safe composite key if needed.
-func (r UserGroupBinding) EncodeKey() string {
-	encode := url.PathEscape
-	return encode(r.CoreUser.TenantID) + "|" + encode(r.CoreUser.UserID) + "|" + encode(r.UserGroupID) + "|" + encode(r.SubID)
+	return user_identity.DecodeKey(s)
 }
 
 // AddRoles merges roles into the record with deduplication.
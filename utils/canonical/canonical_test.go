@@ -0,0 +1,50 @@
+package canonical
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalizeJSONVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/jcs_vectors.json")
+	require.NoError(t, err)
+
+	var vectors []struct {
+		Name     string `json:"name"`
+		Input    string `json:"input"`
+		Expected string `json:"expected"`
+	}
+	require.NoError(t, json.Unmarshal(data, &vectors))
+	require.NotEmpty(t, vectors)
+
+	for _, v := range vectors {
+		got, err := CanonicalizeJSON([]byte(v.Input))
+		require.NoError(t, err, v.Name)
+		require.Equal(t, v.Expected, string(got), v.Name)
+	}
+}
+
+func TestCanonicalizeJSONRejectsTrailingData(t *testing.T) {
+	_, err := CanonicalizeJSON([]byte(`{"a":1} {"b":2}`))
+	require.Error(t, err)
+}
+
+func TestEncoder(t *testing.T) {
+	got, err := NewEncoder("T").String("ab").Int64(-2).Bool(true).JSON(nil).Bytes()
+	require.NoError(t, err)
+
+	want := "00000010" + hex.EncodeToString([]byte(Magic)) +
+		"00000001" + "54" +
+		"00000002" + "6162" +
+		"fffffffffffffffe" +
+		"01" +
+		"00000004" + hex.EncodeToString([]byte("null"))
+	require.Equal(t, want, hex.EncodeToString(got))
+
+	_, err = NewEncoder("T").JSON(json.RawMessage(`{`)).Bytes()
+	require.Error(t, err)
+}
@@ -0,0 +1,175 @@
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// CanonicalizeJSON returns the RFC 8785 (JCS) form of raw: no insignificant whitespace,
+// object members sorted by UTF-16 code units, ECMAScript number formatting and minimal
+// string escaping. This is what JavaScript's JSON.stringify produces for the same value
+// once keys are sorted, so clients can reproduce it without a library.
+func CanonicalizeJSON(raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("canonical: invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, errors.New("canonical: trailing data after JSON value")
+	}
+
+	var buf bytes.Buffer
+	if err := writeJCS(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJCS(buf *bytes.Buffer, v any) error {
+	switch t := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case json.Number:
+		s, err := formatNumber(t)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case string:
+		writeString(buf, t)
+	case []any:
+		buf.WriteByte('[')
+		for i, elem := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJCS(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeString(buf, k)
+			buf.WriteByte(':')
+			if err := writeJCS(buf, t[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("canonical: unexpected JSON type %T", v)
+	}
+	return nil
+}
+
+// formatNumber implements ECMAScript Number.prototype.toString for finite doubles.
+func formatNumber(n json.Number) (string, error) {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("canonical: number %s is not a finite IEEE 754 double", n)
+	}
+	if f == 0 {
+		return "0", nil // Also covers -0
+	}
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	// Shortest round-trip digits and decimal exponent: f = 0.d1d2...dk * 10^point
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, expPart, _ := strings.Cut(e, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	exp, _ := strconv.Atoi(expPart)
+	k := len(digits)
+	point := exp + 1
+
+	switch {
+	case k <= point && point <= 21:
+		return sign + digits + strings.Repeat("0", point-k), nil
+	case 0 < point && point <= 21:
+		return sign + digits[:point] + "." + digits[point:], nil
+	case -6 < point && point <= 0:
+		return sign + "0." + strings.Repeat("0", -point) + digits, nil
+	}
+
+	expSign := "+"
+	if point-1 < 0 {
+		expSign = "-"
+	}
+	expAbs := point - 1
+	if expAbs < 0 {
+		expAbs = -expAbs
+	}
+	if k == 1 {
+		return sign + digits + "e" + expSign + strconv.Itoa(expAbs), nil
+	}
+	return sign + digits[:1] + "." + digits[1:] + "e" + expSign + strconv.Itoa(expAbs), nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				var b [utf8.UTFMax]byte
+				buf.Write(b[:utf8.EncodeRune(b[:], r)])
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16 orders strings by UTF-16 code units, as RFC 8785 requires.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
@@ -0,0 +1,36 @@
+package canonical
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// VectorFile is the layout of the testdata/canonical_vectors.json files shipped for
+// client implementations. Each vector's object is the artifact as sent on the wire,
+// including a signature over canonical_hex made with SigningContext and the Ed448 key
+// derived from TestSeedHex (whose public key is PublicKeyHex).
+type VectorFile struct {
+	Description    string   `json:"description"`
+	SigningContext string   `json:"signing_context"`
+	TestSeedHex    string   `json:"test_seed_hex"`
+	PublicKeyHex   string   `json:"public_key_hex"`
+	Vectors        []Vector `json:"vectors"`
+}
+
+type Vector struct {
+	Name         string          `json:"name"`
+	Object       json.RawMessage `json:"object"`
+	CanonicalHex string          `json:"canonical_hex"`
+}
+
+func ReadVectorFile(path string) (*VectorFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f VectorFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}